@@ -0,0 +1,116 @@
+package awssigner_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+	awssigner "github.com/jwx-go/crypto-signer/v2/aws"
+)
+
+func TestGrantTokens(t *testing.T) {
+	client, kid := newTestKey(t, types.KeySpecEccNistP256)
+	tokens := []string{`grant-token-1`, `grant-token-2`}
+
+	sv := awssigner.NewECDSA(client).
+		WithKeyID(kid).
+		WithGrantTokens(tokens)
+
+	if _, err := sv.GetPublicKey(); err != nil {
+		t.Fatalf("failed to get public key: %s", err)
+	}
+	if !slices.Equal(client.lastGrantTokens, tokens) {
+		t.Fatalf("expected grant tokens %v, got %v", tokens, client.lastGrantTokens)
+	}
+
+	client.lastGrantTokens = nil
+	digest := sha256.Sum256([]byte("obla-di-obla-da"))
+	if _, err := sv.WithAlgorithm(types.SigningAlgorithmSpecEcdsaSha256).Sign(rand.Reader, digest[:], nil); err != nil {
+		t.Fatalf("failed to sign: %s", err)
+	}
+	if !slices.Equal(client.lastGrantTokens, tokens) {
+		t.Fatalf("expected grant tokens %v, got %v", tokens, client.lastGrantTokens)
+	}
+}
+
+type checkAccesser interface {
+	CheckAccess(context.Context) error
+}
+
+func TestCheckAccess(t *testing.T) {
+	testcases := []struct {
+		Name   string
+		Spec   types.KeySpec
+		Signer func(awssigner.Client, string) checkAccesser
+	}{
+		{
+			Name: "RSA",
+			Spec: types.KeySpecRsa2048,
+			Signer: func(client awssigner.Client, kid string) checkAccesser {
+				return awssigner.NewRSA(client).WithKeyID(kid)
+			},
+		},
+		{
+			Name: "ECDSA",
+			Spec: types.KeySpecEccNistP384,
+			Signer: func(client awssigner.Client, kid string) checkAccesser {
+				return awssigner.NewECDSA(client).WithKeyID(kid)
+			},
+		},
+		{
+			Name: "EdDSA",
+			Spec: types.KeySpecEccNistEdwards25519,
+			Signer: func(client awssigner.Client, kid string) checkAccesser {
+				return awssigner.NewEdDSA(client).WithKeyID(kid)
+			},
+		},
+		{
+			Name: "MLDSA",
+			Spec: types.KeySpecMlDsa65,
+			Signer: func(client awssigner.Client, kid string) checkAccesser {
+				return awssigner.NewMLDSA(client).WithKeyID(kid)
+			},
+		},
+		{
+			Name: "SM2",
+			Spec: types.KeySpecSm2,
+			Signer: func(client awssigner.Client, kid string) checkAccesser {
+				return awssigner.NewSM2(client).WithKeyID(kid)
+			},
+		},
+		{
+			Name: "Signer",
+			Spec: types.KeySpecEccNistP521,
+			Signer: func(client awssigner.Client, kid string) checkAccesser {
+				return awssigner.New(client).WithKeyID(kid)
+			},
+		},
+	}
+
+	ctx := context.Background()
+	for _, tc := range testcases {
+		t.Run(tc.Name, func(t *testing.T) {
+			client, kid := newTestKey(t, tc.Spec)
+			sv := tc.Signer(client, kid)
+
+			if err := sv.CheckAccess(ctx); err != nil {
+				t.Fatalf("CheckAccess failed: %s", err)
+			}
+
+			if _, err := client.DisableKey(ctx, &kms.DisableKeyInput{KeyId: aws.String(kid)}); err != nil {
+				t.Fatalf("failed to disable key: %s", err)
+			}
+			err := sv.CheckAccess(ctx)
+			var disabled *types.DisabledException
+			if !errors.As(err, &disabled) {
+				t.Fatalf("expected DisabledException, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,182 @@
+package awssigner
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+)
+
+// rsaSigningAlgorithm derives the RSA signing algorithm from opts.
+// *rsa.PSSOptions selects RSASSA-PSS, anything else selects RSASSA-PKCS1-v1_5.
+func rsaSigningAlgorithm(opts crypto.SignerOpts) (types.SigningAlgorithmSpec, error) {
+	hash := opts.HashFunc()
+	if pss, ok := opts.(*rsa.PSSOptions); ok {
+		// AWS KMS always uses a salt as long as the digest
+		switch pss.SaltLength {
+		case rsa.PSSSaltLengthEqualsHash, hash.Size():
+		default:
+			return "", fmt.Errorf(`unsupported PSS salt length %d: AWS KMS only supports salt lengths equal to the hash length (%d)`, pss.SaltLength, hash.Size())
+		}
+
+		switch hash {
+		case crypto.SHA256:
+			return types.SigningAlgorithmSpecRsassaPssSha256, nil
+		case crypto.SHA384:
+			return types.SigningAlgorithmSpecRsassaPssSha384, nil
+		case crypto.SHA512:
+			return types.SigningAlgorithmSpecRsassaPssSha512, nil
+		}
+		return "", fmt.Errorf(`unsupported hash function for RSASSA-PSS: %s`, hash)
+	}
+
+	switch hash {
+	case crypto.SHA256:
+		return types.SigningAlgorithmSpecRsassaPkcs1V15Sha256, nil
+	case crypto.SHA384:
+		return types.SigningAlgorithmSpecRsassaPkcs1V15Sha384, nil
+	case crypto.SHA512:
+		return types.SigningAlgorithmSpecRsassaPkcs1V15Sha512, nil
+	}
+	return "", fmt.Errorf(`unsupported hash function for RSASSA-PKCS1-v1_5: %s`, hash)
+}
+
+// ecdsaSigningAlgorithm derives the ECDSA signing algorithm from opts.
+func ecdsaSigningAlgorithm(opts crypto.SignerOpts) (types.SigningAlgorithmSpec, error) {
+	switch hash := opts.HashFunc(); hash {
+	case crypto.SHA256:
+		return types.SigningAlgorithmSpecEcdsaSha256, nil
+	case crypto.SHA384:
+		return types.SigningAlgorithmSpecEcdsaSha384, nil
+	case crypto.SHA512:
+		return types.SigningAlgorithmSpecEcdsaSha512, nil
+	default:
+		return "", fmt.Errorf(`unsupported hash function for ECDSA: %s`, hash)
+	}
+}
+
+// eddsaSigningAlgorithm derives the Ed25519 signing algorithm and the
+// message type from opts, following the conventions of ed25519.PrivateKey:
+// a zero hash function selects Ed25519, while crypto.SHA512 selects
+// Ed25519ph, in which case message must be a SHA-512 digest.
+func eddsaSigningAlgorithm(message []byte, opts crypto.SignerOpts) (types.SigningAlgorithmSpec, types.MessageType, error) {
+	alg, mt, err := eddsaSelectSigningAlgorithm(opts)
+	if err != nil {
+		return "", "", err
+	}
+
+	if mt == types.MessageTypeDigest && len(message) != crypto.SHA512.Size() {
+		return "", "", fmt.Errorf(`expected a SHA-512 digest of length %d, got %d`, crypto.SHA512.Size(), len(message))
+	}
+	return alg, mt, nil
+}
+
+// eddsaSelectSigningAlgorithm works like eddsaSigningAlgorithm, but does
+// not check the length of the digest.
+func eddsaSelectSigningAlgorithm(opts crypto.SignerOpts) (types.SigningAlgorithmSpec, types.MessageType, error) {
+	if edopts, ok := opts.(*ed25519.Options); ok && edopts.Context != "" {
+		return "", "", fmt.Errorf(`Ed25519 contexts are not supported by AWS KMS`)
+	}
+
+	var hash crypto.Hash
+	if opts != nil {
+		hash = opts.HashFunc()
+	}
+
+	switch hash {
+	case crypto.Hash(0):
+		return types.SigningAlgorithmSpecEd25519Sha512, types.MessageTypeRaw, nil
+	case crypto.SHA512:
+		return types.SigningAlgorithmSpecEd25519PhSha512, types.MessageTypeDigest, nil
+	default:
+		return "", "", fmt.Errorf(`expected opts.HashFunc() to be zero or SHA-512, got %s`, hash)
+	}
+}
+
+// signingAlgorithmHash returns the hash function used by the given
+// signing algorithm, or zero if it is not a digest based algorithm
+// that we know of.
+func signingAlgorithmHash(alg types.SigningAlgorithmSpec) crypto.Hash {
+	switch alg {
+	case types.SigningAlgorithmSpecRsassaPkcs1V15Sha256, types.SigningAlgorithmSpecRsassaPssSha256, types.SigningAlgorithmSpecEcdsaSha256:
+		return crypto.SHA256
+	case types.SigningAlgorithmSpecRsassaPkcs1V15Sha384, types.SigningAlgorithmSpecRsassaPssSha384, types.SigningAlgorithmSpecEcdsaSha384:
+		return crypto.SHA384
+	case types.SigningAlgorithmSpecRsassaPkcs1V15Sha512, types.SigningAlgorithmSpecRsassaPssSha512, types.SigningAlgorithmSpecEcdsaSha512:
+		return crypto.SHA512
+	default:
+		return crypto.Hash(0)
+	}
+}
+
+// chooseSigningAlgorithm picks the signing algorithm for a digest.
+//
+// If opts specifies a hash function, the algorithm is derived from opts
+// using derive, and it must agree with the configured algorithm, if any.
+// Otherwise the configured algorithm is used as is.
+//
+// In both cases the length of the digest is checked against the
+// hash function used by the algorithm.
+func chooseSigningAlgorithm(configured types.SigningAlgorithmSpec, derive func(crypto.SignerOpts) (types.SigningAlgorithmSpec, error), digest []byte, opts crypto.SignerOpts) (types.SigningAlgorithmSpec, error) {
+	alg, err := selectSigningAlgorithm(configured, derive, opts)
+	if err != nil {
+		return "", err
+	}
+
+	if hash := signingAlgorithmHash(alg); hash != crypto.Hash(0) && len(digest) != hash.Size() {
+		return "", fmt.Errorf(`invalid digest length for %q: expected %d, got %d`, alg, hash.Size(), len(digest))
+	}
+	return alg, nil
+}
+
+// selectSigningAlgorithm works like chooseSigningAlgorithm, but does not
+// check the length of the digest. It is used when signing whole messages.
+func selectSigningAlgorithm(configured types.SigningAlgorithmSpec, derive func(crypto.SignerOpts) (types.SigningAlgorithmSpec, error), opts crypto.SignerOpts) (types.SigningAlgorithmSpec, error) {
+	alg := configured
+	if opts != nil && opts.HashFunc() != crypto.Hash(0) {
+		derived, err := derive(opts)
+		if err != nil {
+			return "", err
+		}
+		if configured != "" && configured != derived {
+			return "", fmt.Errorf(`signing algorithm %q was explicitly configured, but opts requires %q`, configured, derived)
+		}
+		alg = derived
+	}
+
+	if alg == "" {
+		return "", fmt.Errorf(`either the types.SigningAlgorithmSpec or opts.HashFunc() must be specified`)
+	}
+	return alg, nil
+}
+
+// rsaEncryptionAlgorithm derives the RSA encryption algorithm from opts.
+// Only *rsa.OAEPOptions with SHA-1 or SHA-256 are supported by AWS KMS.
+// If opts is nil, an empty algorithm is returned.
+func rsaEncryptionAlgorithm(opts crypto.DecrypterOpts) (types.EncryptionAlgorithmSpec, error) {
+	switch opts := opts.(type) {
+	case nil:
+		return "", nil
+	case *rsa.OAEPOptions:
+		if len(opts.Label) > 0 {
+			return "", fmt.Errorf(`OAEP labels are not supported by AWS KMS`)
+		}
+		if opts.MGFHash != crypto.Hash(0) && opts.MGFHash != opts.Hash {
+			return "", fmt.Errorf(`AWS KMS requires the MGF1 hash (%s) to be the same as the OAEP hash (%s)`, opts.MGFHash, opts.Hash)
+		}
+
+		switch opts.Hash {
+		case crypto.SHA1:
+			return types.EncryptionAlgorithmSpecRsaesOaepSha1, nil
+		case crypto.SHA256:
+			return types.EncryptionAlgorithmSpecRsaesOaepSha256, nil
+		}
+		return "", fmt.Errorf(`unsupported hash function for RSAES-OAEP: %s`, opts.Hash)
+	case *rsa.PKCS1v15DecryptOptions:
+		return "", fmt.Errorf(`RSAES-PKCS1-v1_5 is not supported by AWS KMS`)
+	default:
+		return "", fmt.Errorf(`unsupported decrypter options type %T`, opts)
+	}
+}
@@ -0,0 +1,100 @@
+package awssigner
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+)
+
+// DefaultAliasRefreshInterval is the amount of time that an alias is
+// considered to point to the same key, unless specified otherwise using
+// WithAliasRefreshInterval().
+const DefaultAliasRefreshInterval = 5 * time.Minute
+
+// aliasCacheKey is the key under which the result of resolving an alias
+// (or a key ID) is stored in the Cache. It is a distinct type so that
+// it does not collide with the items that are stored under the key ARN.
+type aliasCacheKey string
+
+// resolvedKey is the result of resolving an alias to a key ARN
+type resolvedKey struct {
+	arn        string
+	resolvedAt time.Time
+}
+
+// isAlias returns true if kid is an alias name ("alias/...") or an alias
+// ARN ("arn:aws:kms:...:alias/...")
+func isAlias(kid string) bool {
+	if strings.HasPrefix(kid, `alias/`) {
+		return true
+	}
+	return strings.HasPrefix(kid, `arn:`) && strings.Contains(kid, `:alias/`)
+}
+
+// isKeyARN returns true if kid is a key ARN ("arn:aws:kms:...:key/...")
+func isKeyARN(kid string) bool {
+	return strings.HasPrefix(kid, `arn:`) && strings.Contains(kid, `:key/`)
+}
+
+// resolveKeyID returns the key ID to use in requests to KMS, and as the
+// key for items stored in the cache.
+//
+// If a cache is provided and kid is an alias, the alias is resolved to
+// the key ARN, so that the cached items always belong to the key that the
+// alias currently points to. Without a cache, nothing can become stale,
+// and aliases are passed to KMS as they are.
+func resolveKeyID(ctx context.Context, client Client, kid string, grantTokens []string, cache Cache, interval time.Duration) (string, error) {
+	if cache == nil || !isAlias(kid) {
+		return kid, nil
+	}
+	return resolveKeyARN(ctx, client, kid, grantTokens, cache, interval)
+}
+
+// resolveKeyARN returns the ARN of the key identified by kid, which may be
+// a key ID, a key ARN, an alias name, or an alias ARN. Unless kid is
+// already a key ARN, KMS DescribeKey is called to find out the key ARN.
+//
+// If a cache is provided, the result is reused until interval has
+// elapsed. If interval is 0, DefaultAliasRefreshInterval is used.
+func resolveKeyARN(ctx context.Context, client Client, kid string, grantTokens []string, cache Cache, interval time.Duration) (string, error) {
+	if isKeyARN(kid) {
+		return kid, nil
+	}
+
+	if interval <= 0 {
+		interval = DefaultAliasRefreshInterval
+	}
+
+	if cache != nil {
+		v, ok := cache.Get(aliasCacheKey(kid))
+		if ok {
+			if resolved, ok := v.(*resolvedKey); ok && time.Since(resolved.resolvedAt) < interval {
+				return resolved.arn, nil
+			}
+		}
+	}
+
+	output, err := client.DescribeKey(ctx, &kms.DescribeKeyInput{
+		KeyId:       aws.String(kid),
+		GrantTokens: grantTokens,
+	})
+	if err != nil {
+		return "", fmt.Errorf(`failed to describe key via KMS: %w`, classifyError(err))
+	}
+	if output.KeyMetadata == nil || aws.ToString(output.KeyMetadata.Arn) == "" {
+		return "", fmt.Errorf(`KMS returned no ARN for key %q`, kid)
+	}
+
+	arn := aws.ToString(output.KeyMetadata.Arn)
+	if cache != nil {
+		cache.Set(aliasCacheKey(kid), &resolvedKey{
+			arn:        arn,
+			resolvedAt: time.Now(),
+		})
+	}
+	return arn, nil
+}
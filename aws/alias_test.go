@@ -0,0 +1,142 @@
+package awssigner_test
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+	awssigner "github.com/jwx-go/crypto-signer/v2/aws"
+	"github.com/jwx-go/crypto-signer/v2/aws/kmstest"
+)
+
+func TestAlias(t *testing.T) {
+	ctx := context.Background()
+	client := kmstest.New()
+	const alias = `alias/jwt-signing`
+
+	var arns []string
+	for range 2 {
+		output, err := client.CreateKey(ctx, &kms.CreateKeyInput{
+			KeySpec:  types.KeySpecEccNistP256,
+			KeyUsage: types.KeyUsageTypeSignVerify,
+		})
+		if err != nil {
+			t.Fatalf("failed to create key: %s", err)
+		}
+		arns = append(arns, aws.ToString(output.KeyMetadata.Arn))
+	}
+	if _, err := client.CreateAlias(ctx, &kms.CreateAliasInput{AliasName: aws.String(alias), TargetKeyId: aws.String(arns[0])}); err != nil {
+		t.Fatalf("failed to create alias: %s", err)
+	}
+
+	publicKey := func(t *testing.T, arn string) crypto.PublicKey {
+		t.Helper()
+		pubkey, err := awssigner.NewECDSA(client).WithKeyID(arn).GetPublicKey()
+		if err != nil {
+			t.Fatalf("failed to get public key: %s", err)
+		}
+		return pubkey
+	}
+
+	checkKey := func(t *testing.T, sv *awssigner.ECDSA, arn string) {
+		t.Helper()
+		resolved, err := sv.KeyARN()
+		if err != nil {
+			t.Fatalf("failed to resolve key ARN: %s", err)
+		}
+		if resolved != arn {
+			t.Fatalf("expected key ARN %q, got %q", arn, resolved)
+		}
+
+		pubkey, err := sv.GetPublicKey()
+		if err != nil {
+			t.Fatalf("failed to get public key: %s", err)
+		}
+		if !publicKey(t, arn).(*ecdsa.PublicKey).Equal(pubkey) {
+			t.Fatalf("public key does not belong to %q", arn)
+		}
+
+		digest := sha256.Sum256([]byte("obla-di-obla-da"))
+		signed, err := sv.Sign(rand.Reader, digest[:], crypto.SHA256)
+		if err != nil {
+			t.Fatalf("failed to sign: %s", err)
+		}
+		if !ecdsa.VerifyASN1(pubkey.(*ecdsa.PublicKey), digest[:], signed) {
+			t.Fatalf("failed to verify signature")
+		}
+	}
+
+	cache := NewDumbCache()
+	sv := awssigner.NewECDSA(client).
+		WithKeyID(alias).
+		WithCache(cache).
+		WithAliasRefreshInterval(time.Hour)
+	checkKey(t, sv, arns[0])
+
+	// rotate the key by re-pointing the alias
+	if _, err := client.UpdateAlias(ctx, &kms.UpdateAliasInput{AliasName: aws.String(alias), TargetKeyId: aws.String(arns[1])}); err != nil {
+		t.Fatalf("failed to update alias: %s", err)
+	}
+
+	t.Run("before refresh", func(t *testing.T) {
+		checkKey(t, sv, arns[0])
+	})
+	t.Run("after refresh", func(t *testing.T) {
+		checkKey(t, sv.WithAliasRefreshInterval(time.Nanosecond), arns[1])
+	})
+	t.Run("without cache", func(t *testing.T) {
+		checkKey(t, awssigner.NewECDSA(client).WithKeyID(alias), arns[1])
+	})
+	t.Run("RSA", func(t *testing.T) {
+		output, err := client.CreateKey(ctx, &kms.CreateKeyInput{
+			KeySpec:  types.KeySpecRsa2048,
+			KeyUsage: types.KeyUsageTypeSignVerify,
+		})
+		if err != nil {
+			t.Fatalf("failed to create key: %s", err)
+		}
+		const rsaAlias = `alias/jwt-signing-rsa`
+		if _, err := client.CreateAlias(ctx, &kms.CreateAliasInput{AliasName: aws.String(rsaAlias), TargetKeyId: output.KeyMetadata.Arn}); err != nil {
+			t.Fatalf("failed to create alias: %s", err)
+		}
+
+		sv := awssigner.NewRSA(client).
+			WithKeyID(rsaAlias).
+			WithCache(NewDumbCache()).
+			WithAliasRefreshInterval(time.Hour)
+		if resolved, err := sv.KeyARN(); err != nil || resolved != aws.ToString(output.KeyMetadata.Arn) {
+			t.Fatalf("expected key ARN %q, got %q (%v)", aws.ToString(output.KeyMetadata.Arn), resolved, err)
+		}
+
+		// the cached ARN is used until the alias is refreshed
+		if _, err := client.UpdateAlias(ctx, &kms.UpdateAliasInput{AliasName: aws.String(rsaAlias), TargetKeyId: aws.String(arns[0])}); err != nil {
+			t.Fatalf("failed to update alias: %s", err)
+		}
+		if resolved, err := sv.KeyARN(); err != nil || resolved != aws.ToString(output.KeyMetadata.Arn) {
+			t.Fatalf("expected cached key ARN %q, got %q (%v)", aws.ToString(output.KeyMetadata.Arn), resolved, err)
+		}
+		if resolved, err := sv.WithAliasRefreshInterval(time.Nanosecond).KeyARN(); err != nil || resolved != arns[0] {
+			t.Fatalf("expected key ARN %q, got %q (%v)", arns[0], resolved, err)
+		}
+	})
+	t.Run("key ID", func(t *testing.T) {
+		output, err := client.DescribeKey(ctx, &kms.DescribeKeyInput{KeyId: aws.String(arns[0])})
+		if err != nil {
+			t.Fatalf("failed to describe key: %s", err)
+		}
+		resolved, err := awssigner.NewRSA(client).WithKeyID(aws.ToString(output.KeyMetadata.KeyId)).KeyARN()
+		if err != nil {
+			t.Fatalf("failed to resolve key ARN: %s", err)
+		}
+		if resolved != arns[0] {
+			t.Fatalf("expected key ARN %q, got %q", arns[0], resolved)
+		}
+	})
+}
@@ -16,6 +16,8 @@ import (
 )
 
 var _ crypto.Signer = &awssigner.RSA{}
+var _ crypto.Signer = &awssigner.ECDSA{}
+var _ crypto.Signer = &awssigner.EdDSA{}
 
 func ExampleRSA() {
 	kid := os.Getenv(`AWS_KMS_KEY_ID_RSA`)
@@ -114,3 +116,42 @@ func ExampleECDSA() {
 	}
 	//OUTPUT:
 }
+
+func ExampleEdDSA() {
+	kid := os.Getenv(`AWS_KMS_KEY_ID_EDDSA`)
+	if kid == "" {
+		// Don't run unless we're given the Key ID
+		return
+	}
+	// Make sure to set AWS_* environment variable, if you
+	// need to configure them.
+	awscfg, err := config.LoadDefaultConfig(
+		context.Background(),
+	)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	payload := []byte("obla-di-obla-da")
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	sv := awssigner.NewEdDSA(kms.NewFromConfig(awscfg)).
+		WithKeyID(kid).
+		WithCache(NewDumbCache())
+
+	signed, err := jws.Sign(payload, jws.WithKey(jwa.EdDSA, sv.WithContext(ctx)))
+	if err != nil {
+		panic(err.Error())
+	}
+
+	verified, err := jws.Verify(signed, jws.WithKey(jwa.EdDSA, sv.WithContext(ctx)))
+	if err != nil {
+		panic(err.Error())
+	}
+
+	if bytes.Compare(payload, verified) != 0 {
+		panic("payload and verified does not match")
+	}
+	//OUTPUT:
+}
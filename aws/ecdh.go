@@ -0,0 +1,144 @@
+package awssigner
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/x509"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+)
+
+// ECDH performs Elliptic Curve Diffie-Hellman key agreement using AWS KMS
+// keys with the KEY_AGREEMENT key usage, and the ECC_NIST_P256,
+// ECC_NIST_P384, or ECC_NIST_P521 key specs. The private key never leaves
+// KMS: the peer's public key is sent to KMS, and the shared secret is
+// returned.
+//
+// The ECDH method has the same signature as that of *ecdh.PrivateKey, so
+// that ECDH can be used wherever only the key agreement is required. See
+// the jwxadapter package for using it to decrypt ECDH-ES JWE messages.
+type ECDH struct {
+	aliasRefresh time.Duration
+	cache        Cache
+	client       Client
+	ctx          context.Context
+	grantTokens  []string
+	kid          string
+}
+
+// NewECDH creates a new ECDH object. This object isnot complete by itself -- it
+// needs to be setup with a key ID, and a context.Context object to use while
+// the AWS SDK makes network requests.
+//
+// The key agreement algorithm is always ECDH, so there is no need to
+// specify it.
+func NewECDH(client Client) *ECDH {
+	return &ECDH{
+		client: client,
+	}
+}
+
+func (sv *ECDH) getContext() context.Context {
+	ctx := sv.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return ctx
+}
+
+func (sv *ECDH) key() kmsKey {
+	return kmsKey{
+		aliasRefresh: sv.aliasRefresh,
+		cache:        sv.cache,
+		client:       sv.client,
+		grantTokens:  sv.grantTokens,
+		kid:          sv.kid,
+	}
+}
+
+// ECDH performs an ECDH exchange with the given remote public key, which
+// must be on the same curve as the KMS key, and returns the shared secret.
+// As with *ecdh.PrivateKey, the shared secret is the x-coordinate of the
+// resulting point, without any key derivation applied.
+func (sv *ECDH) ECDH(remote *ecdh.PublicKey) ([]byte, error) {
+	if sv.kid == "" {
+		return nil, fmt.Errorf(`aws.ECDH.ECDH() requires the key ID`)
+	}
+	if remote == nil {
+		return nil, fmt.Errorf(`aws.ECDH.ECDH() requires the remote public key`)
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(remote)
+	if err != nil {
+		return nil, fmt.Errorf(`aws.ECDH.ECDH() failed to marshal remote public key: %w`, err)
+	}
+
+	// sv.ctx is NOT required, but we will use context.Background here
+	// which means there will not be a (clean) way to interrupt this
+	// operation
+	ctx := sv.getContext()
+
+	kid, err := sv.key().resolve(ctx)
+	if err != nil {
+		return nil, fmt.Errorf(`aws.ECDH.ECDH() %w`, err)
+	}
+
+	return kmsDeriveSharedSecret(ctx, sv.client, kid, sv.grantTokens, der)
+}
+
+// KeyARN returns the ARN of the key, resolving the alias (if any) in the
+// same way as RSA.KeyARN().
+//
+// As with RSADecrypter.KeyARN(), publish it as the "kid" of the public
+// key, so that the "kid" header of ECDH-ES JWE messages tells which key
+// is required to decrypt them.
+func (sv *ECDH) KeyARN() (string, error) {
+	if sv.kid == "" {
+		return "", fmt.Errorf(`aws.ECDH.KeyARN() requires the key ID`)
+	}
+
+	// sv.ctx is NOT required, but we will use context.Background here
+	// which means there will not be a (clean) way to interrupt this
+	// operation
+	ctx := sv.getContext()
+
+	return sv.key().arn(ctx)
+}
+
+// Public returns the corresponding public key, as an *ecdsa.PublicKey.
+//
+// Because this method does not allow for an error to be returned,
+// the return value from this function cannot describe what kind of error
+// occurred.
+func (sv *ECDH) Public() crypto.PublicKey {
+	pubkey, _ := sv.GetPublicKey()
+	return pubkey
+}
+
+// This method is an escape hatch for those cases where the user needs
+// to debug what went wrong during the GetPublicKey operation.
+func (sv *ECDH) GetPublicKey() (crypto.PublicKey, error) {
+	if sv.kid == "" {
+		return nil, fmt.Errorf(`aws.ECDH.GetPublicKey() requires the key ID`)
+	}
+
+	// sv.ctx is NOT required, but we will use context.Background here
+	// which means there will not be a (clean) way to interrupt this
+	// operation
+	ctx := sv.getContext()
+
+	info, err := sv.key().info(ctx, types.KeyUsageTypeKeyAgreement)
+	if err != nil {
+		return nil, fmt.Errorf(`aws.ECDH.GetPublicKey() %w`, err)
+	}
+
+	pubkey, ok := info.publicKey.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf(`aws.ECDH.GetPublicKey() expected *ecdsa.PublicKey, got %T`, info.publicKey)
+	}
+	return pubkey, nil
+}
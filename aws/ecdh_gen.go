@@ -0,0 +1,83 @@
+package awssigner
+
+import (
+	"context"
+	"time"
+)
+
+// WithAliasRefreshInterval specifies how long an alias is considered to
+// point to the same key. This only has an effect when a Cache is provided:
+// aliases are then resolved to the key ARN using KMS DescribeKey, and the
+// cached items are stored under the key ARN, so that they do not become
+// stale when the alias is updated to point to a different key.
+//
+// If it is not specified, DefaultAliasRefreshInterval is used.
+func (cs *ECDH) WithAliasRefreshInterval(v time.Duration) *ECDH {
+	return &ECDH{
+		client:       cs.client,
+		aliasRefresh: v,
+		cache:        cs.cache,
+		ctx:          cs.ctx,
+		grantTokens:  cs.grantTokens,
+		kid:          cs.kid,
+	}
+}
+
+// WithCache specifies the cache storage for frequently used items.
+// Currently only the public key is cached.
+//
+// If it is not specified, nothing will be cached.
+//
+// Since it would be rather easy for the key in AWS KMS and the cache
+// to be out of sync, make sure to either purge the cache periodically
+// or use a cache with some sort of auto-eviction mechanism.
+func (cs *ECDH) WithCache(v Cache) *ECDH {
+	return &ECDH{
+		client:       cs.client,
+		aliasRefresh: cs.aliasRefresh,
+		cache:        v,
+		ctx:          cs.ctx,
+		grantTokens:  cs.grantTokens,
+		kid:          cs.kid,
+	}
+}
+
+// WithContext associates a new context.Context with the object, which will be used for ECDH() and Public()
+func (cs *ECDH) WithContext(v context.Context) *ECDH {
+	return &ECDH{
+		client:       cs.client,
+		aliasRefresh: cs.aliasRefresh,
+		cache:        cs.cache,
+		ctx:          v,
+		grantTokens:  cs.grantTokens,
+		kid:          cs.kid,
+	}
+}
+
+// WithGrantTokens specifies the grant tokens to send along with each request
+// to KMS. Grant tokens allow you to use the permissions granted by a
+// grant before the grant has achieved eventual consistency.
+//
+// See https://docs.aws.amazon.com/kms/latest/developerguide/grant-manage.html#using-grant-token
+func (cs *ECDH) WithGrantTokens(v []string) *ECDH {
+	return &ECDH{
+		client:       cs.client,
+		aliasRefresh: cs.aliasRefresh,
+		cache:        cs.cache,
+		ctx:          cs.ctx,
+		grantTokens:  v,
+		kid:          cs.kid,
+	}
+}
+
+// WithKeyID associates a new string with the object, which will be used for ECDH() and Public()
+func (cs *ECDH) WithKeyID(v string) *ECDH {
+	return &ECDH{
+		client:       cs.client,
+		aliasRefresh: cs.aliasRefresh,
+		cache:        cs.cache,
+		ctx:          cs.ctx,
+		grantTokens:  cs.grantTokens,
+		kid:          v,
+	}
+}
@@ -0,0 +1,73 @@
+package awssigner_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+	awssigner "github.com/jwx-go/crypto-signer/v2/aws"
+	"github.com/jwx-go/crypto-signer/v2/aws/kmstest"
+)
+
+func TestECDH(t *testing.T) {
+	client := kmstest.New()
+	output, err := client.CreateKey(context.Background(), &kms.CreateKeyInput{
+		KeySpec:  types.KeySpecEccNistP256,
+		KeyUsage: types.KeyUsageTypeKeyAgreement,
+	})
+	if err != nil {
+		t.Fatalf("failed to create key: %s", err)
+	}
+
+	kd := awssigner.NewECDH(client).
+		WithKeyID(aws.ToString(output.KeyMetadata.KeyId)).
+		WithCache(NewDumbCache())
+
+	pubkey, ok := kd.Public().(*ecdsa.PublicKey)
+	if !ok {
+		t.Fatalf("expected *ecdsa.PublicKey, got %T", kd.Public())
+	}
+	remote, err := pubkey.ECDH()
+	if err != nil {
+		t.Fatalf("failed to convert public key: %s", err)
+	}
+
+	peer, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %s", err)
+	}
+
+	secret, err := kd.ECDH(peer.PublicKey())
+	if err != nil {
+		t.Fatalf("failed to derive shared secret: %s", err)
+	}
+	expected, err := peer.ECDH(remote)
+	if err != nil {
+		t.Fatalf("failed to derive shared secret: %s", err)
+	}
+	if !bytes.Equal(expected, secret) {
+		t.Fatalf("shared secrets do not match")
+	}
+
+	t.Run("curve mismatch", func(t *testing.T) {
+		peer, err := ecdh.P384().GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatalf("failed to generate key: %s", err)
+		}
+		if _, err := kd.ECDH(peer.PublicKey()); err == nil {
+			t.Fatalf("key agreement with a key on a different curve should fail")
+		}
+	})
+	t.Run("wrong key usage", func(t *testing.T) {
+		client, kid := newTestKey(t, types.KeySpecEccNistP256)
+		if _, err := awssigner.NewECDH(client).WithKeyID(kid).ECDH(peer.PublicKey()); err == nil {
+			t.Fatalf("key agreement with a SIGN_VERIFY key should fail")
+		}
+	})
+}
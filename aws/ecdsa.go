@@ -1,15 +1,14 @@
 package awssigner
 
 import (
+	"bytes"
 	"context"
 	"crypto"
 	"crypto/ecdsa"
-	"crypto/x509"
 	"fmt"
 	"io"
+	"time"
 
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/service/kms"
 	"github.com/aws/aws-sdk-go-v2/service/kms/types"
 )
 
@@ -21,22 +20,41 @@ type Cache interface {
 	Set(interface{}, interface{})
 }
 
+// ECDSA is a crypto.Signer for AWS KMS keys with the ECC_NIST_P256,
+// ECC_NIST_P384, ECC_NIST_P521, or ECC_SECG_P256K1 key specs.
+//
+// For ECC_SECG_P256K1 keys, use types.SigningAlgorithmSpecEcdsaSha256
+// to generate signatures that can be used with jwa.ES256K.
 type ECDSA struct {
-	alg    types.SigningAlgorithmSpec
-	client *kms.Client
-	cache  Cache
-	ctx    context.Context
-	kid    string
+	alg          types.SigningAlgorithmSpec
+	aliasRefresh time.Duration
+	checkState   bool
+	client       Client
+	cache        Cache
+	ctx          context.Context
+	grantTokens  []string
+	kid          string
+	limiter      *Limiter
+	memo         *keyMemo
+	mt           types.MessageType
+	mismatchHook func(string, error)
+	selfVerify   bool
+	encoding     SignatureEncoding
+	lowS         bool
 }
 
 // NewECDSA creates a new ECDSA object. This object isnot complete by itself -- it
-// needs to be setup with the algorithm name to use (see
-// https://pkg.go.dev/github.com/aws/aws-sdk-go-v2/service/kms/types#SigningAlgorithmSpec),
-// a key ID, and a context.Context object to use while the AWS SDK makes network
-// requests.
-func NewECDSA(client *kms.Client) *ECDSA {
+// needs to be setup with a key ID, and a context.Context object to use while
+// the AWS SDK makes network requests.
+//
+// The algorithm name to use (see
+// https://pkg.go.dev/github.com/aws/aws-sdk-go-v2/service/kms/types#SigningAlgorithmSpec)
+// is derived from the crypto.SignerOpts passed to Sign(), but it can also
+// be fixed using WithAlgorithm().
+func NewECDSA(client Client) *ECDSA {
 	return &ECDSA{
 		client: client,
+		memo:   &keyMemo{},
 	}
 }
 
@@ -48,32 +66,198 @@ func (sv *ECDSA) getContext() context.Context {
 	return ctx
 }
 
+func (sv *ECDSA) key() kmsKey {
+	return kmsKey{
+		aliasRefresh: sv.aliasRefresh,
+		cache:        sv.cache,
+		client:       sv.client,
+		grantTokens:  sv.grantTokens,
+		kid:          sv.kid,
+		memo:         sv.memo,
+	}
+}
+
 // Sign generates a signature from the given digest.
+//
+// The signing algorithm is derived from opts.HashFunc(). If the
+// algorithm was also explicitly specified via WithAlgorithm(), the two
+// must agree. If opts does not specify a hash function, the algorithm
+// specified via WithAlgorithm() is used.
 func (sv *ECDSA) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
-	if sv.alg == "" {
-		return nil, fmt.Errorf(`aws.ECDSA.Sign() requires the types.SigningAlgorithmSpec`)
-	}
 	if sv.kid == "" {
 		return nil, fmt.Errorf(`aws.ECDSA.Sign() requires the key ID`)
 	}
 
+	alg, err := chooseSigningAlgorithm(sv.alg, ecdsaSigningAlgorithm, digest, opts)
+	if err != nil {
+		return nil, fmt.Errorf(`aws.ECDSA.Sign() failed to determine signing algorithm: %w`, err)
+	}
+
 	// sv.ctx is NOT required, but we will use context.Background here
 	// which means there will not be a (clean) way to interrupt this
 	// operation
 	ctx := sv.getContext()
 
-	input := kms.SignInput{
-		KeyId:            aws.String(sv.kid),
-		Message:          digest,
-		MessageType:      types.MessageTypeDigest,
-		SigningAlgorithm: sv.alg,
+	kid, err := sv.key().resolve(ctx)
+	if err != nil {
+		return nil, fmt.Errorf(`aws.ECDSA.Sign() %w`, err)
 	}
-	signed, err := sv.client.Sign(ctx, &input)
+
+	if sv.checkState {
+		if err := kmsCheckKeyState(ctx, sv.client, kid, sv.grantTokens, types.KeyUsageTypeSignVerify); err != nil {
+			return nil, fmt.Errorf(`aws.ECDSA.Sign() refused to use the key: %w`, err)
+		}
+	}
+
+	signed, err := kmsSignLimited(ctx, sv.limiter, sv.client, kid, sv.grantTokens, digest, types.MessageTypeDigest, alg)
 	if err != nil {
-		return nil, fmt.Errorf(`failed to sign via KMS: %w`, err)
+		return nil, err
 	}
+	if sv.selfVerify {
+		if err := sv.key().selfVerify(ctx, kid, sv.mismatchHook, digest, types.MessageTypeDigest, alg, signed); err != nil {
+			return nil, fmt.Errorf(`aws.ECDSA.Sign() %w`, err)
+		}
+	}
+	signed, err = sv.encodeSignature(signed)
+	if err != nil {
+		return nil, fmt.Errorf(`aws.ECDSA.Sign() %w`, err)
+	}
+	return signed, nil
+}
 
-	return signed.Signature, nil
+// SignMessage generates a signature from the given message, and
+// implements crypto.MessageSigner.
+//
+// The signing algorithm is chosen in the same way as Sign(). Messages up
+// to 4096 bytes are sent to KMS as is, and larger messages are hashed
+// locally, unless specified otherwise using WithMessageType().
+func (sv *ECDSA) SignMessage(_ io.Reader, message []byte, opts crypto.SignerOpts) ([]byte, error) {
+	return sv.SignStream(bytes.NewReader(message), opts)
+}
+
+// SignStream works like SignMessage(), but reads the message from r.
+// Messages that are hashed locally are hashed as they are read, so they
+// are never held in memory as a whole.
+func (sv *ECDSA) SignStream(r io.Reader, opts crypto.SignerOpts) ([]byte, error) {
+	if sv.kid == "" {
+		return nil, fmt.Errorf(`aws.ECDSA.SignStream() requires the key ID`)
+	}
+
+	alg, err := selectSigningAlgorithm(sv.alg, ecdsaSigningAlgorithm, opts)
+	if err != nil {
+		return nil, fmt.Errorf(`aws.ECDSA.SignStream() failed to determine signing algorithm: %w`, err)
+	}
+
+	message, mt, err := messageForSigning(r, signingAlgorithmHash(alg), sv.mt)
+	if err != nil {
+		return nil, fmt.Errorf(`aws.ECDSA.SignStream() %w`, err)
+	}
+
+	// sv.ctx is NOT required, but we will use context.Background here
+	// which means there will not be a (clean) way to interrupt this
+	// operation
+	ctx := sv.getContext()
+
+	kid, err := sv.key().resolve(ctx)
+	if err != nil {
+		return nil, fmt.Errorf(`aws.ECDSA.SignStream() %w`, err)
+	}
+
+	if sv.checkState {
+		if err := kmsCheckKeyState(ctx, sv.client, kid, sv.grantTokens, types.KeyUsageTypeSignVerify); err != nil {
+			return nil, fmt.Errorf(`aws.ECDSA.SignStream() refused to use the key: %w`, err)
+		}
+	}
+
+	signed, err := kmsSignLimited(ctx, sv.limiter, sv.client, kid, sv.grantTokens, message, mt, alg)
+	if err != nil {
+		return nil, err
+	}
+	if sv.selfVerify {
+		if err := sv.key().selfVerify(ctx, kid, sv.mismatchHook, message, mt, alg, signed); err != nil {
+			return nil, fmt.Errorf(`aws.ECDSA.SignStream() %w`, err)
+		}
+	}
+	signed, err = sv.encodeSignature(signed)
+	if err != nil {
+		return nil, fmt.Errorf(`aws.ECDSA.SignStream() %w`, err)
+	}
+	return signed, nil
+}
+
+// encodeSignature re-encodes an ECDSA signature returned by KMS, as
+// specified via WithSignatureEncoding() and WithLowS(). Other signatures
+// are returned as they are.
+func (sv *ECDSA) encodeSignature(signature []byte) ([]byte, error) {
+	if sv.encoding == SignatureEncodingDER && !sv.lowS {
+		return signature, nil
+	}
+
+	key, err := sv.GetPublicKey()
+	if err != nil {
+		return nil, fmt.Errorf(`failed to retrieve public key to encode signature: %w`, err)
+	}
+	pubkey, ok := key.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf(`expected *ecdsa.PublicKey, got %T`, key)
+	}
+
+	return encodeECDSASignature(pubkey, signature, sv.encoding, sv.lowS)
+}
+
+// CheckAccess makes sure that the key can be used for signing, by calling
+// the KMS Sign API with DryRun enabled. Use it at startup to find out
+// about missing permissions or disabled keys before the first signature
+// is actually required.
+func (sv *ECDSA) CheckAccess(ctx context.Context) error {
+	if sv.kid == "" {
+		return fmt.Errorf(`aws.ECDSA.CheckAccess() requires the key ID`)
+	}
+
+	alg := sv.alg
+	if alg == "" {
+		// the signing algorithm for ECDSA keys depends on the curve
+		key, err := sv.WithContext(ctx).GetPublicKey()
+		if err != nil {
+			return fmt.Errorf(`aws.ECDSA.CheckAccess() failed to retrieve public key: %w`, err)
+		}
+		pubkey, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return fmt.Errorf(`aws.ECDSA.CheckAccess() expected *ecdsa.PublicKey, got %T`, key)
+		}
+		switch pubkey.Curve.Params().BitSize {
+		case 256:
+			alg = types.SigningAlgorithmSpecEcdsaSha256
+		case 384:
+			alg = types.SigningAlgorithmSpecEcdsaSha384
+		default:
+			alg = types.SigningAlgorithmSpecEcdsaSha512
+		}
+	}
+
+	if err := sv.key().checkSign(ctx, alg); err != nil {
+		return fmt.Errorf(`aws.ECDSA.CheckAccess() %w`, err)
+	}
+	return nil
+}
+
+// KeyARN returns the ARN of the key, resolving the alias (if any) in the
+// same way as RSA.KeyARN().
+//
+// As it changes whenever the alias is pointed to a new key, it is a good
+// fit for the "kid" header of ES256/ES384/ES512/ES256K JWS messages and
+// the "kid" of the public key published in a JWKS.
+func (sv *ECDSA) KeyARN() (string, error) {
+	if sv.kid == "" {
+		return "", fmt.Errorf(`aws.ECDSA.KeyARN() requires the key ID`)
+	}
+
+	// sv.ctx is NOT required, but we will use context.Background here
+	// which means there will not be a (clean) way to interrupt this
+	// operation
+	ctx := sv.getContext()
+
+	return sv.key().arn(ctx)
 }
 
 // Public returns the corresponding public key.
@@ -93,40 +277,19 @@ func (sv *ECDSA) GetPublicKey() (crypto.PublicKey, error) {
 		return nil, fmt.Errorf(`aws.ECDSA.Sign() requires the key ID`)
 	}
 
-	if cache := sv.cache; cache != nil {
-		v, ok := cache.Get(sv.kid)
-		if ok {
-			if pubkey, ok := v.(*ecdsa.PublicKey); ok {
-				return pubkey, nil
-			}
-		}
-	}
-
 	// sv.ctx is NOT required, but we will use context.Background here
 	// which means there will not be a (clean) way to interrupt this
 	// operation
 	ctx := sv.getContext()
 
-	input := kms.GetPublicKeyInput{
-		KeyId: aws.String(sv.kid),
-	}
-	output, err := sv.client.GetPublicKey(ctx, &input)
+	info, err := sv.key().info(ctx, types.KeyUsageTypeSignVerify)
 	if err != nil {
-		return nil, fmt.Errorf(`failed to get public key from KMS: %w`, err)
+		return nil, fmt.Errorf(`aws.ECDSA.GetPublicKey() %w`, err)
 	}
 
-	if output.KeyUsage != types.KeyUsageTypeSignVerify {
-		return nil, fmt.Errorf(`invalid key usage. expected SIGN_VERIFY, got %q`, output.KeyUsage)
+	pubkey, ok := info.publicKey.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf(`aws.ECDSA.GetPublicKey() expected *ecdsa.PublicKey, got %T`, info.publicKey)
 	}
-
-	key, err := x509.ParsePKIXPublicKey(output.PublicKey)
-	if err != nil {
-		return nil, fmt.Errorf(`failed to parse key: %w`, err)
-	}
-
-	if cache := sv.cache; cache != nil {
-		cache.Set(sv.kid, key)
-	}
-
-	return key, nil
+	return pubkey, nil
 }
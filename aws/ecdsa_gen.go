@@ -2,57 +2,342 @@ package awssigner
 
 import (
 	"context"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/service/kms/types"
 )
 
-// WithAlgorithm associates a new types.SigningAlgorithmSpec with the object, which will be used for Sign() and Public()
+// WithAlgorithm associates a new types.SigningAlgorithmSpec with the object, which will be used for Sign().
+//
+// If it is not specified, the algorithm is derived from the crypto.SignerOpts
+// passed to Sign(). If it is specified, the crypto.SignerOpts passed to Sign()
+// must agree with it.
 func (cs *ECDSA) WithAlgorithm(v types.SigningAlgorithmSpec) *ECDSA {
 	return &ECDSA{
-		client: cs.client,
-		alg:    v,
-		cache:  cs.cache,
-		ctx:    cs.ctx,
-		kid:    cs.kid,
+		client:       cs.client,
+		memo:         cs.memo,
+		alg:          v,
+		aliasRefresh: cs.aliasRefresh,
+		cache:        cs.cache,
+		checkState:   cs.checkState,
+		ctx:          cs.ctx,
+		encoding:     cs.encoding,
+		grantTokens:  cs.grantTokens,
+		kid:          cs.kid,
+		limiter:      cs.limiter,
+		lowS:         cs.lowS,
+		mismatchHook: cs.mismatchHook,
+		mt:           cs.mt,
+		selfVerify:   cs.selfVerify,
+	}
+}
+
+// WithAliasRefreshInterval specifies how long an alias is considered to
+// point to the same key. This only has an effect when a Cache is provided:
+// aliases are then resolved to the key ARN using KMS DescribeKey, and the
+// cached items are stored under the key ARN, so that they do not become
+// stale when the alias is updated to point to a different key.
+//
+// If it is not specified, DefaultAliasRefreshInterval is used.
+func (cs *ECDSA) WithAliasRefreshInterval(v time.Duration) *ECDSA {
+	return &ECDSA{
+		client:       cs.client,
+		memo:         cs.memo,
+		alg:          cs.alg,
+		aliasRefresh: v,
+		cache:        cs.cache,
+		checkState:   cs.checkState,
+		ctx:          cs.ctx,
+		encoding:     cs.encoding,
+		grantTokens:  cs.grantTokens,
+		kid:          cs.kid,
+		limiter:      cs.limiter,
+		lowS:         cs.lowS,
+		mismatchHook: cs.mismatchHook,
+		mt:           cs.mt,
+		selfVerify:   cs.selfVerify,
 	}
 }
 
 // WithCache specifies the cache storage for frequently used items.
-// Currently only the public key is cached.
+// Currently only the public key is cached, so that it can be shared
+// with other objects.
 //
-// If it is not specified, nothing will be cached.
+// If it is not specified, the public key is only remembered by this
+// object, and by the objects derived from it.
 //
 // Since it would be rather easy for the key in AWS KMS and the cache
 // to be out of sync, make sure to either purge the cache periodically
 // or use a cache with some sort of auto-eviction mechanism.
 func (cs *ECDSA) WithCache(v Cache) *ECDSA {
 	return &ECDSA{
-		client: cs.client,
-		alg:    cs.alg,
-		cache:  v,
-		ctx:    cs.ctx,
-		kid:    cs.kid,
+		client:       cs.client,
+		memo:         cs.memo,
+		alg:          cs.alg,
+		aliasRefresh: cs.aliasRefresh,
+		cache:        v,
+		checkState:   cs.checkState,
+		ctx:          cs.ctx,
+		encoding:     cs.encoding,
+		grantTokens:  cs.grantTokens,
+		kid:          cs.kid,
+		limiter:      cs.limiter,
+		lowS:         cs.lowS,
+		mismatchHook: cs.mismatchHook,
+		mt:           cs.mt,
+		selfVerify:   cs.selfVerify,
+	}
+}
+
+// WithKeyStateCheck specifies whether the state of the key should be checked
+// using KMS DescribeKey before each signature. If enabled, Sign() refuses to
+// use keys that are not enabled, or that do not have the SIGN_VERIFY key
+// usage, and returns an error wrapping ErrKeyDisabled, ErrKeyPendingDeletion,
+// or ErrWrongKeyUsage.
+//
+// This costs an extra request to KMS per signature, and is disabled by default.
+func (cs *ECDSA) WithKeyStateCheck(v bool) *ECDSA {
+	return &ECDSA{
+		client:       cs.client,
+		memo:         cs.memo,
+		alg:          cs.alg,
+		aliasRefresh: cs.aliasRefresh,
+		cache:        cs.cache,
+		checkState:   v,
+		ctx:          cs.ctx,
+		encoding:     cs.encoding,
+		grantTokens:  cs.grantTokens,
+		kid:          cs.kid,
+		limiter:      cs.limiter,
+		lowS:         cs.lowS,
+		mismatchHook: cs.mismatchHook,
+		mt:           cs.mt,
+		selfVerify:   cs.selfVerify,
 	}
 }
 
 // WithContext associates a new context.Context with the object, which will be used for Sign() and Public()
 func (cs *ECDSA) WithContext(v context.Context) *ECDSA {
 	return &ECDSA{
-		client: cs.client,
-		alg:    cs.alg,
-		cache:  cs.cache,
-		ctx:    v,
-		kid:    cs.kid,
+		client:       cs.client,
+		memo:         cs.memo,
+		alg:          cs.alg,
+		aliasRefresh: cs.aliasRefresh,
+		cache:        cs.cache,
+		checkState:   cs.checkState,
+		ctx:          v,
+		encoding:     cs.encoding,
+		grantTokens:  cs.grantTokens,
+		kid:          cs.kid,
+		limiter:      cs.limiter,
+		lowS:         cs.lowS,
+		mismatchHook: cs.mismatchHook,
+		mt:           cs.mt,
+		selfVerify:   cs.selfVerify,
+	}
+}
+
+// WithSignatureEncoding specifies how ECDSA signatures are encoded. By
+// default (SignatureEncodingDER), the ASN.1 DER encoded signature returned
+// by KMS is used as is. SignatureEncodingRS produces the fixed size r||s
+// encoding, padded to the size of the curve.
+//
+// Do not use SignatureEncodingRS with jwx, which expects crypto.Signer
+// implementations to return ASN.1 DER.
+func (cs *ECDSA) WithSignatureEncoding(v SignatureEncoding) *ECDSA {
+	return &ECDSA{
+		client:       cs.client,
+		memo:         cs.memo,
+		alg:          cs.alg,
+		aliasRefresh: cs.aliasRefresh,
+		cache:        cs.cache,
+		checkState:   cs.checkState,
+		ctx:          cs.ctx,
+		encoding:     v,
+		grantTokens:  cs.grantTokens,
+		kid:          cs.kid,
+		limiter:      cs.limiter,
+		lowS:         cs.lowS,
+		mismatchHook: cs.mismatchHook,
+		mt:           cs.mt,
+		selfVerify:   cs.selfVerify,
+	}
+}
+
+// WithGrantTokens specifies the grant tokens to send along with each request
+// to KMS. Grant tokens allow you to use the permissions granted by a
+// grant before the grant has achieved eventual consistency.
+//
+// See https://docs.aws.amazon.com/kms/latest/developerguide/grant-manage.html#using-grant-token
+func (cs *ECDSA) WithGrantTokens(v []string) *ECDSA {
+	return &ECDSA{
+		client:       cs.client,
+		memo:         cs.memo,
+		alg:          cs.alg,
+		aliasRefresh: cs.aliasRefresh,
+		cache:        cs.cache,
+		checkState:   cs.checkState,
+		ctx:          cs.ctx,
+		encoding:     cs.encoding,
+		grantTokens:  v,
+		kid:          cs.kid,
+		limiter:      cs.limiter,
+		lowS:         cs.lowS,
+		mismatchHook: cs.mismatchHook,
+		mt:           cs.mt,
+		selfVerify:   cs.selfVerify,
 	}
 }
 
 // WithKeyID associates a new string with the object, which will be used for Sign() and Public()
 func (cs *ECDSA) WithKeyID(v string) *ECDSA {
 	return &ECDSA{
-		client: cs.client,
-		alg:    cs.alg,
-		cache:  cs.cache,
-		ctx:    cs.ctx,
-		kid:    v,
+		client:       cs.client,
+		memo:         cs.memo,
+		alg:          cs.alg,
+		aliasRefresh: cs.aliasRefresh,
+		cache:        cs.cache,
+		checkState:   cs.checkState,
+		ctx:          cs.ctx,
+		encoding:     cs.encoding,
+		grantTokens:  cs.grantTokens,
+		kid:          v,
+		limiter:      cs.limiter,
+		lowS:         cs.lowS,
+		mismatchHook: cs.mismatchHook,
+		mt:           cs.mt,
+		selfVerify:   cs.selfVerify,
+	}
+}
+
+// WithLimiter specifies the Limiter that limits the rate of Sign() requests
+// sent to KMS for the key, and retries requests that KMS throttles.
+//
+// If it is not specified, requests are sent as soon as Sign() is called,
+// and throttling errors are returned as they are.
+func (cs *ECDSA) WithLimiter(v *Limiter) *ECDSA {
+	return &ECDSA{
+		client:       cs.client,
+		memo:         cs.memo,
+		alg:          cs.alg,
+		aliasRefresh: cs.aliasRefresh,
+		cache:        cs.cache,
+		checkState:   cs.checkState,
+		ctx:          cs.ctx,
+		encoding:     cs.encoding,
+		grantTokens:  cs.grantTokens,
+		kid:          cs.kid,
+		limiter:      v,
+		lowS:         cs.lowS,
+		mismatchHook: cs.mismatchHook,
+		mt:           cs.mt,
+		selfVerify:   cs.selfVerify,
+	}
+}
+
+// WithLowS specifies whether ECDSA signatures are normalized to low-S
+// form, where s is at most half the order of the curve. Some verifiers
+// (e.g. for secp256k1 signatures) reject signatures that are not.
+func (cs *ECDSA) WithLowS(v bool) *ECDSA {
+	return &ECDSA{
+		client:       cs.client,
+		memo:         cs.memo,
+		alg:          cs.alg,
+		aliasRefresh: cs.aliasRefresh,
+		cache:        cs.cache,
+		checkState:   cs.checkState,
+		ctx:          cs.ctx,
+		encoding:     cs.encoding,
+		grantTokens:  cs.grantTokens,
+		kid:          cs.kid,
+		limiter:      cs.limiter,
+		lowS:         v,
+		mismatchHook: cs.mismatchHook,
+		mt:           cs.mt,
+		selfVerify:   cs.selfVerify,
+	}
+}
+
+// WithMismatchHook specifies a function that is called with the key ID
+// and the error whenever self-verification (see WithSelfVerify()) fails.
+// Use it to alert on signatures that do not match the public key.
+func (cs *ECDSA) WithMismatchHook(v func(string, error)) *ECDSA {
+	return &ECDSA{
+		client:       cs.client,
+		memo:         cs.memo,
+		alg:          cs.alg,
+		aliasRefresh: cs.aliasRefresh,
+		cache:        cs.cache,
+		checkState:   cs.checkState,
+		ctx:          cs.ctx,
+		encoding:     cs.encoding,
+		grantTokens:  cs.grantTokens,
+		kid:          cs.kid,
+		limiter:      cs.limiter,
+		lowS:         cs.lowS,
+		mismatchHook: v,
+		mt:           cs.mt,
+		selfVerify:   cs.selfVerify,
+	}
+}
+
+// WithMessageType specifies the message type to use in SignMessage() and
+// SignStream(). If types.MessageTypeRaw is specified, the message is sent
+// to KMS as is, and messages larger than 4096 bytes are rejected. If
+// types.MessageTypeDigest is specified, the message is always hashed
+// locally. By default, messages up to 4096 bytes are sent as is, and
+// larger messages are hashed locally.
+//
+// Sign() always sends a digest, regardless of this setting.
+func (cs *ECDSA) WithMessageType(v types.MessageType) *ECDSA {
+	return &ECDSA{
+		client:       cs.client,
+		memo:         cs.memo,
+		alg:          cs.alg,
+		aliasRefresh: cs.aliasRefresh,
+		cache:        cs.cache,
+		checkState:   cs.checkState,
+		ctx:          cs.ctx,
+		encoding:     cs.encoding,
+		grantTokens:  cs.grantTokens,
+		kid:          cs.kid,
+		limiter:      cs.limiter,
+		lowS:         cs.lowS,
+		mismatchHook: cs.mismatchHook,
+		mt:           v,
+		selfVerify:   cs.selfVerify,
+	}
+}
+
+// WithSelfVerify specifies whether each signature returned by KMS is
+// verified locally against the public key before it is returned. When
+// the verification fails, the signature is discarded, an error wrapping
+// ErrSignatureMismatch is returned, and the function specified via
+// WithMismatchHook() is called.
+//
+// This guards against corrupted responses, and against keys that no
+// longer match the public key that verifiers use. The public key is
+// retrieved from KMS only once per key: without a Cache, an alias is
+// pinned to the key that it pointed to when the public key was first
+// retrieved, so signatures made after the alias was re-pointed fail
+// the verification. With a Cache, the alias is followed instead (see
+// WithAliasRefreshInterval()).
+func (cs *ECDSA) WithSelfVerify(v bool) *ECDSA {
+	return &ECDSA{
+		client:       cs.client,
+		memo:         cs.memo,
+		alg:          cs.alg,
+		aliasRefresh: cs.aliasRefresh,
+		cache:        cs.cache,
+		checkState:   cs.checkState,
+		ctx:          cs.ctx,
+		encoding:     cs.encoding,
+		grantTokens:  cs.grantTokens,
+		kid:          cs.kid,
+		limiter:      cs.limiter,
+		lowS:         cs.lowS,
+		mismatchHook: cs.mismatchHook,
+		mt:           cs.mt,
+		selfVerify:   v,
 	}
 }
@@ -0,0 +1,189 @@
+package awssigner
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/sha512"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+)
+
+// EdDSA is a crypto.Signer for AWS KMS keys with the ECC_NIST_EDWARDS25519
+// key spec.
+//
+// Unlike RSA and ECDSA, Ed25519 signs the full message instead of a digest,
+// so the `digest` argument to Sign() is expected to contain the raw payload,
+// as is the case with ed25519.PrivateKey.
+type EdDSA struct {
+	aliasRefresh time.Duration
+	checkState   bool
+	client       Client
+	cache        Cache
+	ctx          context.Context
+	grantTokens  []string
+	kid          string
+	limiter      *Limiter
+	memo         *keyMemo
+	mismatchHook func(string, error)
+	selfVerify   bool
+}
+
+// NewEdDSA creates a new EdDSA object. This object isnot complete by itself -- it
+// needs to be setup with a key ID, and a context.Context object to use while
+// the AWS SDK makes network requests.
+//
+// The signing algorithm is always ED25519_SHA_512 (or ED25519_PH_SHA_512
+// when a pre-hashed message is requested via crypto.SignerOpts), so there
+// is no need to specify it.
+func NewEdDSA(client Client) *EdDSA {
+	return &EdDSA{
+		client: client,
+		memo:   &keyMemo{},
+	}
+}
+
+func (sv *EdDSA) getContext() context.Context {
+	ctx := sv.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return ctx
+}
+
+func (sv *EdDSA) key() kmsKey {
+	return kmsKey{
+		aliasRefresh: sv.aliasRefresh,
+		cache:        sv.cache,
+		client:       sv.client,
+		grantTokens:  sv.grantTokens,
+		kid:          sv.kid,
+		memo:         sv.memo,
+	}
+}
+
+// Sign generates a signature for the given message.
+//
+// As with ed25519.PrivateKey, opts.HashFunc() must return zero to sign
+// the message as is (Ed25519), or crypto.SHA512 to sign a SHA-512 digest
+// of the message (Ed25519ph). Contexts specified via ed25519.Options are
+// not supported by AWS KMS.
+func (sv *EdDSA) Sign(_ io.Reader, message []byte, opts crypto.SignerOpts) ([]byte, error) {
+	if sv.kid == "" {
+		return nil, fmt.Errorf(`aws.EdDSA.Sign() requires the key ID`)
+	}
+
+	alg, mt, err := eddsaSigningAlgorithm(message, opts)
+	if err != nil {
+		return nil, fmt.Errorf(`aws.EdDSA.Sign() failed to determine signing algorithm: %w`, err)
+	}
+
+	// sv.ctx is NOT required, but we will use context.Background here
+	// which means there will not be a (clean) way to interrupt this
+	// operation
+	ctx := sv.getContext()
+
+	kid, err := sv.key().resolve(ctx)
+	if err != nil {
+		return nil, fmt.Errorf(`aws.EdDSA.Sign() %w`, err)
+	}
+
+	if sv.checkState {
+		if err := kmsCheckKeyState(ctx, sv.client, kid, sv.grantTokens, types.KeyUsageTypeSignVerify); err != nil {
+			return nil, fmt.Errorf(`aws.EdDSA.Sign() refused to use the key: %w`, err)
+		}
+	}
+
+	signed, err := kmsSignLimited(ctx, sv.limiter, sv.client, kid, sv.grantTokens, message, mt, alg)
+	if err != nil {
+		return nil, err
+	}
+	if sv.selfVerify {
+		if err := sv.key().selfVerify(ctx, kid, sv.mismatchHook, message, mt, alg, signed); err != nil {
+			return nil, fmt.Errorf(`aws.EdDSA.Sign() %w`, err)
+		}
+	}
+	return signed, nil
+}
+
+// SignMessage generates a signature from the given message, and
+// implements crypto.MessageSigner.
+//
+// If opts.HashFunc() is zero, the message is signed as is (Ed25519), in
+// which case it must not be larger than 4096 bytes. If it is crypto.SHA512,
+// the SHA-512 digest of the message is computed locally and then signed
+// (Ed25519ph).
+func (sv *EdDSA) SignMessage(rand io.Reader, message []byte, opts crypto.SignerOpts) ([]byte, error) {
+	if opts != nil && opts.HashFunc() == crypto.SHA512 {
+		digest := sha512.Sum512(message)
+		return sv.Sign(rand, digest[:], opts)
+	}
+	return sv.Sign(rand, message, opts)
+}
+
+// CheckAccess makes sure that the key can be used for signing, by calling
+// the KMS Sign API with DryRun enabled. Use it at startup to find out
+// about missing permissions or disabled keys before the first signature
+// is actually required.
+func (sv *EdDSA) CheckAccess(ctx context.Context) error {
+	if sv.kid == "" {
+		return fmt.Errorf(`aws.EdDSA.CheckAccess() requires the key ID`)
+	}
+	if err := sv.key().checkSign(ctx, types.SigningAlgorithmSpecEd25519Sha512); err != nil {
+		return fmt.Errorf(`aws.EdDSA.CheckAccess() %w`, err)
+	}
+	return nil
+}
+
+// KeyARN returns the ARN of the key, resolving the alias (if any) in the
+// same way as RSA.KeyARN(). Use it as the "kid" header of EdDSA JWS
+// messages.
+func (sv *EdDSA) KeyARN() (string, error) {
+	if sv.kid == "" {
+		return "", fmt.Errorf(`aws.EdDSA.KeyARN() requires the key ID`)
+	}
+
+	// sv.ctx is NOT required, but we will use context.Background here
+	// which means there will not be a (clean) way to interrupt this
+	// operation
+	ctx := sv.getContext()
+
+	return sv.key().arn(ctx)
+}
+
+// Public returns the corresponding public key.
+//
+// Because the crypto.Signer API does not allow for an error to be returned,
+// the return value from this function cannot describe what kind of error
+// occurred.
+func (sv *EdDSA) Public() crypto.PublicKey {
+	pubkey, _ := sv.GetPublicKey()
+	return pubkey
+}
+
+// This method is an escape hatch for those cases where the user needs
+// to debug what went wrong during the GetPublicKey operation.
+func (sv *EdDSA) GetPublicKey() (crypto.PublicKey, error) {
+	if sv.kid == "" {
+		return nil, fmt.Errorf(`aws.EdDSA.GetPublicKey() requires the key ID`)
+	}
+
+	// sv.ctx is NOT required, but we will use context.Background here
+	// which means there will not be a (clean) way to interrupt this
+	// operation
+	ctx := sv.getContext()
+
+	info, err := sv.key().info(ctx, types.KeyUsageTypeSignVerify)
+	if err != nil {
+		return nil, fmt.Errorf(`aws.EdDSA.GetPublicKey() %w`, err)
+	}
+
+	pubkey, ok := info.publicKey.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf(`aws.EdDSA.GetPublicKey() expected ed25519.PublicKey, got %T`, info.publicKey)
+	}
+	return pubkey, nil
+}
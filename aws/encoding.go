@@ -0,0 +1,31 @@
+package awssigner
+
+import (
+	"crypto/ecdsa"
+
+	"github.com/jwx-go/crypto-signer/v2/internal/ecdsaenc"
+)
+
+// SignatureEncoding specifies how ECDSA signatures are encoded. Use it
+// with WithSignatureEncoding().
+type SignatureEncoding int
+
+const (
+	// SignatureEncodingDER encodes signatures as an ASN.1 DER SEQUENCE of
+	// r and s, as returned by KMS. This is the default, and the encoding
+	// that crypto.Signer implementations are expected to use (jwx
+	// requires it).
+	SignatureEncodingDER SignatureEncoding = iota
+
+	// SignatureEncodingRS encodes signatures as the concatenation of r
+	// and s, each left-padded with zeros to the size of the curve (e.g.
+	// 64 bytes for P-256, and 132 bytes for P-521). This is the encoding
+	// used by JOSE, COSE, and IEEE P1363.
+	SignatureEncodingRS
+)
+
+// encodeECDSASignature re-encodes a DER encoded ECDSA signature returned
+// by KMS, optionally normalizing it to low-S (see ecdsaenc.Encode)
+func encodeECDSASignature(pubkey *ecdsa.PublicKey, signature []byte, encoding SignatureEncoding, lowS bool) ([]byte, error) {
+	return ecdsaenc.Encode(pubkey, signature, ecdsaenc.Encoding(encoding), lowS)
+}
@@ -0,0 +1,59 @@
+package awssigner_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"math/big"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+	awssigner "github.com/jwx-go/crypto-signer/v2/aws"
+)
+
+// The encoding itself is tested in internal/ecdsaenc. These tests make
+// sure that the options are applied to the signatures returned by KMS.
+func TestSignatureEncoding(t *testing.T) {
+	digest := sha256.Sum256([]byte("obla-di-obla-da"))
+
+	t.Run("ECDSA", func(t *testing.T) {
+		client, kid := newTestKey(t, types.KeySpecEccSecgP256k1)
+		sv := awssigner.NewECDSA(client).
+			WithKeyID(kid).
+			WithSignatureEncoding(awssigner.SignatureEncodingRS).
+			WithLowS(true)
+		pubkey := sv.Public().(*ecdsa.PublicKey)
+
+		signed, err := sv.Sign(rand.Reader, digest[:], crypto.SHA256)
+		if err != nil {
+			t.Fatalf("failed to sign: %s", err)
+		}
+		if len(signed) != 64 {
+			t.Fatalf("expected a 64 byte signature, got %d bytes", len(signed))
+		}
+		r := new(big.Int).SetBytes(signed[:32])
+		s := new(big.Int).SetBytes(signed[32:])
+		if s.Cmp(new(big.Int).Rsh(pubkey.Curve.Params().N, 1)) > 0 {
+			t.Fatalf("expected a low-S signature")
+		}
+		if !ecdsa.Verify(pubkey, digest[:], r, s) {
+			t.Fatalf("failed to verify signature")
+		}
+	})
+	t.Run("Signer with RSA key", func(t *testing.T) {
+		client, kid := newTestKey(t, types.KeySpecRsa2048)
+		sv := awssigner.New(client).
+			WithKeyID(kid).
+			WithSignatureEncoding(awssigner.SignatureEncodingRS).
+			WithLowS(true)
+		signed, err := sv.Sign(rand.Reader, digest[:], crypto.SHA256)
+		if err != nil {
+			t.Fatalf("failed to sign: %s", err)
+		}
+		if err := rsa.VerifyPKCS1v15(sv.Public().(*rsa.PublicKey), crypto.SHA256, digest[:], signed); err != nil {
+			t.Fatalf("expected RSA signatures to be left alone: %s", err)
+		}
+	})
+}
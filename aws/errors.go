@@ -0,0 +1,105 @@
+package awssigner
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+	"github.com/aws/smithy-go"
+)
+
+// The errors returned by the objects in this package wrap one of these
+// errors when KMS reports the corresponding condition, so that they can
+// be tested for using errors.Is:
+//
+//	if errors.Is(err, awssigner.ErrKeyDisabled) {
+//	  ...
+//	}
+//
+// The original error returned by the AWS SDK (e.g. *types.DisabledException)
+// is still available using errors.As.
+var (
+	// ErrKeyDisabled means that the key is disabled.
+	ErrKeyDisabled = errors.New(`key is disabled`)
+
+	// ErrKeyPendingDeletion means that the key is scheduled for deletion.
+	ErrKeyPendingDeletion = errors.New(`key is pending deletion`)
+
+	// ErrWrongKeyUsage means that the key usage or the key spec of the key
+	// does not allow the requested operation or algorithm.
+	ErrWrongKeyUsage = errors.New(`key cannot be used for this operation`)
+
+	// ErrThrottled means that the request exceeded the KMS request quota.
+	ErrThrottled = errors.New(`request was throttled`)
+
+	// ErrAccessDenied means that the caller is not allowed to perform the
+	// operation with the key.
+	ErrAccessDenied = errors.New(`access denied`)
+)
+
+// ErrSignatureMismatch means that a signature returned by KMS did not
+// verify against the public key of the key that was used. It is only
+// reported when self-verification is enabled using WithSelfVerify().
+var ErrSignatureMismatch = errors.New(`signature does not verify against the public key`)
+
+// kmsError associates an error returned by the AWS SDK with one of the
+// errors above
+type kmsError struct {
+	kind error
+	err  error
+}
+
+func (e *kmsError) Error() string {
+	return e.err.Error()
+}
+
+func (e *kmsError) Unwrap() []error {
+	return []error{e.kind, e.err}
+}
+
+// classifyError maps the errors returned by the AWS SDK to the errors
+// defined in this package. Errors that do not correspond to any of them
+// are returned as they are.
+func classifyError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var kind error
+	var disabled *types.DisabledException
+	var invalidState *types.KMSInvalidStateException
+	var invalidUsage *types.InvalidKeyUsageException
+	var apiErr smithy.APIError
+	switch {
+	case errors.As(err, &disabled):
+		kind = ErrKeyDisabled
+	case errors.As(err, &invalidState):
+		// KMSInvalidStateException is used for all key states that do
+		// not allow the operation, so the message has to be inspected
+		msg := strings.ToLower(invalidState.ErrorMessage())
+		if strings.Contains(msg, `pending deletion`) || strings.Contains(msg, `pendingdeletion`) {
+			kind = ErrKeyPendingDeletion
+		}
+	case errors.As(err, &invalidUsage):
+		kind = ErrWrongKeyUsage
+	case errors.As(err, &apiErr):
+		if isThrottlingCode(apiErr.ErrorCode()) {
+			kind = ErrThrottled
+		} else if apiErr.ErrorCode() == `AccessDeniedException` {
+			kind = ErrAccessDenied
+		}
+	}
+
+	if kind == nil {
+		return err
+	}
+	return &kmsError{kind: kind, err: err}
+}
+
+// isThrottlingCode returns true if code is one of the error codes that
+// AWS services use to report throttling
+func isThrottlingCode(code string) bool {
+	_, ok := retry.DefaultThrottleErrorCodes[code]
+	return ok
+}
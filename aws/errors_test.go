@@ -0,0 +1,142 @@
+package awssigner_test
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+	"github.com/aws/smithy-go"
+	awssigner "github.com/jwx-go/crypto-signer/v2/aws"
+	"github.com/jwx-go/crypto-signer/v2/aws/kmstest"
+)
+
+func TestErrors(t *testing.T) {
+	ctx := context.Background()
+	digest := sha256.Sum256([]byte("obla-di-obla-da"))
+
+	t.Run("disabled", func(t *testing.T) {
+		client, kid := newTestKey(t, types.KeySpecEccNistP256)
+		if _, err := client.DisableKey(ctx, &kms.DisableKeyInput{KeyId: aws.String(kid)}); err != nil {
+			t.Fatalf("failed to disable key: %s", err)
+		}
+
+		_, err := awssigner.NewECDSA(client).WithKeyID(kid).Sign(rand.Reader, digest[:], crypto.SHA256)
+		if !errors.Is(err, awssigner.ErrKeyDisabled) {
+			t.Fatalf("expected ErrKeyDisabled, got %v", err)
+		}
+		var disabled *types.DisabledException
+		if !errors.As(err, &disabled) {
+			t.Fatalf("expected the original DisabledException to be available, got %v", err)
+		}
+	})
+	t.Run("pending deletion", func(t *testing.T) {
+		client, kid := newTestKey(t, types.KeySpecEccNistP256)
+		if _, err := client.ScheduleKeyDeletion(ctx, &kms.ScheduleKeyDeletionInput{KeyId: aws.String(kid)}); err != nil {
+			t.Fatalf("failed to schedule key deletion: %s", err)
+		}
+
+		sv := awssigner.NewECDSA(client).WithKeyID(kid)
+		if _, err := sv.Sign(rand.Reader, digest[:], crypto.SHA256); !errors.Is(err, awssigner.ErrKeyPendingDeletion) {
+			t.Fatalf("expected ErrKeyPendingDeletion, got %v", err)
+		}
+		if _, err := sv.GetPublicKey(); !errors.Is(err, awssigner.ErrKeyPendingDeletion) {
+			t.Fatalf("expected ErrKeyPendingDeletion, got %v", err)
+		}
+	})
+	t.Run("wrong key usage", func(t *testing.T) {
+		client := kmstest.New()
+		output, err := client.CreateKey(ctx, &kms.CreateKeyInput{
+			KeySpec:  types.KeySpecRsa2048,
+			KeyUsage: types.KeyUsageTypeEncryptDecrypt,
+		})
+		if err != nil {
+			t.Fatalf("failed to create key: %s", err)
+		}
+		kid := aws.ToString(output.KeyMetadata.KeyId)
+
+		sv := awssigner.NewRSA(client).WithKeyID(kid)
+		if _, err := sv.Sign(rand.Reader, digest[:], crypto.SHA256); !errors.Is(err, awssigner.ErrWrongKeyUsage) {
+			t.Fatalf("expected ErrWrongKeyUsage, got %v", err)
+		}
+		if _, err := sv.GetPublicKey(); !errors.Is(err, awssigner.ErrWrongKeyUsage) {
+			t.Fatalf("expected ErrWrongKeyUsage, got %v", err)
+		}
+	})
+
+	apiErrors := []struct {
+		Name     string
+		Err      error
+		Expected error
+	}{
+		{
+			Name:     "throttled",
+			Err:      &smithy.GenericAPIError{Code: `ThrottlingException`, Message: `Rate exceeded`},
+			Expected: awssigner.ErrThrottled,
+		},
+		{
+			Name:     "access denied",
+			Err:      &smithy.GenericAPIError{Code: `AccessDeniedException`, Message: `not authorized to perform kms:Sign`},
+			Expected: awssigner.ErrAccessDenied,
+		},
+	}
+	for _, tc := range apiErrors {
+		t.Run(tc.Name, func(t *testing.T) {
+			client := &faultyClient{KMS: kmstest.New()}
+			output, err := client.CreateKey(ctx, &kms.CreateKeyInput{
+				KeySpec:  types.KeySpecEccNistP256,
+				KeyUsage: types.KeyUsageTypeSignVerify,
+			})
+			if err != nil {
+				t.Fatalf("failed to create key: %s", err)
+			}
+			client.setFault(tc.Err, false)
+
+			sv := awssigner.NewECDSA(client).WithKeyID(aws.ToString(output.KeyMetadata.KeyId))
+			_, err = sv.Sign(rand.Reader, digest[:], crypto.SHA256)
+			if !errors.Is(err, tc.Expected) {
+				t.Fatalf("expected %v, got %v", tc.Expected, err)
+			}
+			if !errors.Is(err, tc.Err) {
+				t.Fatalf("expected the original error to be available, got %v", err)
+			}
+		})
+	}
+}
+
+func TestKeyStateCheck(t *testing.T) {
+	ctx := context.Background()
+	digest := sha256.Sum256([]byte("obla-di-obla-da"))
+
+	client, kid := newTestKey(t, types.KeySpecEccNistP256)
+	sv := awssigner.NewECDSA(client).
+		WithKeyID(kid).
+		WithKeyStateCheck(true)
+
+	if _, err := sv.Sign(rand.Reader, digest[:], crypto.SHA256); err != nil {
+		t.Fatalf("failed to sign: %s", err)
+	}
+
+	if _, err := client.DisableKey(ctx, &kms.DisableKeyInput{KeyId: aws.String(kid)}); err != nil {
+		t.Fatalf("failed to disable key: %s", err)
+	}
+	client.lastMessage = nil
+	if _, err := sv.Sign(rand.Reader, digest[:], crypto.SHA256); !errors.Is(err, awssigner.ErrKeyDisabled) {
+		t.Fatalf("expected ErrKeyDisabled, got %v", err)
+	}
+	if client.lastMessage != nil {
+		t.Fatalf("Sign should not have been called")
+	}
+
+	if _, err := client.ScheduleKeyDeletion(ctx, &kms.ScheduleKeyDeletionInput{KeyId: aws.String(kid)}); err != nil {
+		t.Fatalf("failed to schedule key deletion: %s", err)
+	}
+	if _, err := sv.Sign(rand.Reader, digest[:], crypto.SHA256); !errors.Is(err, awssigner.ErrKeyPendingDeletion) {
+		t.Fatalf("expected ErrKeyPendingDeletion, got %v", err)
+	}
+}
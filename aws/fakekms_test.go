@@ -0,0 +1,62 @@
+package awssigner_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+	awssigner "github.com/jwx-go/crypto-signer/v2/aws"
+	"github.com/jwx-go/crypto-signer/v2/aws/kmstest"
+)
+
+var _ awssigner.Client = kmstest.New()
+
+// recordingClient wraps the in-memory fake KMS, and records the
+// parameters of the last Sign request, the grant tokens of the last
+// Sign or GetPublicKey request, and the number of GetPublicKey requests
+type recordingClient struct {
+	*kmstest.KMS
+
+	mu              sync.Mutex
+	lastAlgorithm   types.SigningAlgorithmSpec
+	lastMessageType types.MessageType
+	lastMessage     []byte
+	lastGrantTokens []string
+	publicKeyCalls  int
+}
+
+func (c *recordingClient) Sign(ctx context.Context, in *kms.SignInput, options ...func(*kms.Options)) (*kms.SignOutput, error) {
+	c.mu.Lock()
+	c.lastAlgorithm = in.SigningAlgorithm
+	c.lastMessageType = in.MessageType
+	c.lastMessage = in.Message
+	c.lastGrantTokens = in.GrantTokens
+	c.mu.Unlock()
+	return c.KMS.Sign(ctx, in, options...)
+}
+
+func (c *recordingClient) GetPublicKey(ctx context.Context, in *kms.GetPublicKeyInput, options ...func(*kms.Options)) (*kms.GetPublicKeyOutput, error) {
+	c.mu.Lock()
+	c.lastGrantTokens = in.GrantTokens
+	c.publicKeyCalls++
+	c.mu.Unlock()
+	return c.KMS.GetPublicKey(ctx, in, options...)
+}
+
+// newTestKey creates a new signing key in a fresh fake KMS, and returns
+// the client along with the key ID
+func newTestKey(t *testing.T, spec types.KeySpec) (*recordingClient, string) {
+	t.Helper()
+	client := &recordingClient{KMS: kmstest.New()}
+	output, err := client.CreateKey(context.Background(), &kms.CreateKeyInput{
+		KeySpec:  spec,
+		KeyUsage: types.KeyUsageTypeSignVerify,
+	})
+	if err != nil {
+		t.Fatalf("failed to create key: %s", err)
+	}
+	return client, aws.ToString(output.KeyMetadata.KeyId)
+}
@@ -0,0 +1,189 @@
+package awssigner
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+)
+
+// HMAC generates and verifies HMACs using AWS KMS keys with the
+// GENERATE_VERIFY_MAC key usage, and the HMAC_224, HMAC_256, HMAC_384,
+// or HMAC_512 key specs. The secret key never leaves KMS.
+//
+// AWS KMS only accepts messages up to 4096 bytes long. See the jwxadapter
+// package for using it to sign and verify HS256/HS384/HS512 JWS messages.
+type HMAC struct {
+	alg          types.MacAlgorithmSpec
+	aliasRefresh time.Duration
+	cache        Cache
+	client       Client
+	ctx          context.Context
+	grantTokens  []string
+	kid          string
+}
+
+// NewHMAC creates a new HMAC object. This object isnot complete by itself -- it
+// needs to be setup with a key ID, and a context.Context object to use while
+// the AWS SDK makes network requests.
+//
+// The MAC algorithm to use (see
+// https://pkg.go.dev/github.com/aws/aws-sdk-go-v2/service/kms/types#MacAlgorithmSpec)
+// is retrieved from KMS using DescribeKey, but it can also be fixed using
+// WithAlgorithm() to avoid the extra request.
+func NewHMAC(client Client) *HMAC {
+	return &HMAC{
+		client: client,
+	}
+}
+
+func (sv *HMAC) getContext() context.Context {
+	ctx := sv.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return ctx
+}
+
+func (sv *HMAC) key() kmsKey {
+	return kmsKey{
+		aliasRefresh: sv.aliasRefresh,
+		cache:        sv.cache,
+		client:       sv.client,
+		grantTokens:  sv.grantTokens,
+		kid:          sv.kid,
+	}
+}
+
+// GenerateMAC computes the HMAC of the given message.
+func (sv *HMAC) GenerateMAC(message []byte) ([]byte, error) {
+	if sv.kid == "" {
+		return nil, fmt.Errorf(`aws.HMAC.GenerateMAC() requires the key ID`)
+	}
+	if len(message) > maxRawMessageSize {
+		return nil, fmt.Errorf(`aws.HMAC.GenerateMAC() cannot process messages larger than %d bytes (got %d)`, maxRawMessageSize, len(message))
+	}
+
+	alg, err := sv.Algorithm()
+	if err != nil {
+		return nil, fmt.Errorf(`aws.HMAC.GenerateMAC() failed to determine MAC algorithm: %w`, err)
+	}
+
+	// sv.ctx is NOT required, but we will use context.Background here
+	// which means there will not be a (clean) way to interrupt this
+	// operation
+	ctx := sv.getContext()
+
+	kid, err := sv.key().resolve(ctx)
+	if err != nil {
+		return nil, fmt.Errorf(`aws.HMAC.GenerateMAC() %w`, err)
+	}
+
+	return kmsGenerateMac(ctx, sv.client, kid, sv.grantTokens, message, alg)
+}
+
+// VerifyMAC verifies that mac is the HMAC of the given message. The
+// comparison is performed by KMS, and an error is returned if the MAC
+// is not valid.
+func (sv *HMAC) VerifyMAC(message, mac []byte) error {
+	if sv.kid == "" {
+		return fmt.Errorf(`aws.HMAC.VerifyMAC() requires the key ID`)
+	}
+	if len(message) > maxRawMessageSize {
+		return fmt.Errorf(`aws.HMAC.VerifyMAC() cannot process messages larger than %d bytes (got %d)`, maxRawMessageSize, len(message))
+	}
+
+	alg, err := sv.Algorithm()
+	if err != nil {
+		return fmt.Errorf(`aws.HMAC.VerifyMAC() failed to determine MAC algorithm: %w`, err)
+	}
+
+	// sv.ctx is NOT required, but we will use context.Background here
+	// which means there will not be a (clean) way to interrupt this
+	// operation
+	ctx := sv.getContext()
+
+	kid, err := sv.key().resolve(ctx)
+	if err != nil {
+		return fmt.Errorf(`aws.HMAC.VerifyMAC() %w`, err)
+	}
+
+	return kmsVerifyMac(ctx, sv.client, kid, sv.grantTokens, message, mac, alg)
+}
+
+// KeyARN returns the ARN of the key, resolving the alias (if any) in the
+// same way as RSA.KeyARN(). Use it as the "kid" header of HS256/HS384/HS512
+// JWS messages, so that the right key is used to verify them after the
+// alias has been pointed to a new key.
+func (sv *HMAC) KeyARN() (string, error) {
+	if sv.kid == "" {
+		return "", fmt.Errorf(`aws.HMAC.KeyARN() requires the key ID`)
+	}
+
+	// sv.ctx is NOT required, but we will use context.Background here
+	// which means there will not be a (clean) way to interrupt this
+	// operation
+	ctx := sv.getContext()
+
+	return sv.key().arn(ctx)
+}
+
+// Algorithm returns the MAC algorithm. If it was not specified using
+// WithAlgorithm(), it is retrieved from KMS.
+func (sv *HMAC) Algorithm() (types.MacAlgorithmSpec, error) {
+	if sv.alg != "" {
+		return sv.alg, nil
+	}
+	if sv.kid == "" {
+		return "", fmt.Errorf(`aws.HMAC requires the key ID`)
+	}
+
+	// sv.ctx is NOT required, but we will use context.Background here
+	// which means there will not be a (clean) way to interrupt this
+	// operation
+	ctx := sv.getContext()
+
+	kid, err := sv.key().resolve(ctx)
+	if err != nil {
+		return "", fmt.Errorf(`aws.HMAC.Algorithm() %w`, err)
+	}
+
+	if cache := sv.cache; cache != nil {
+		v, ok := cache.Get(kid)
+		if ok {
+			if alg, ok := v.(types.MacAlgorithmSpec); ok {
+				return alg, nil
+			}
+		}
+	}
+
+	output, err := sv.client.DescribeKey(ctx, &kms.DescribeKeyInput{
+		KeyId:       aws.String(kid),
+		GrantTokens: sv.grantTokens,
+	})
+	if err != nil {
+		return "", fmt.Errorf(`failed to describe key via KMS: %w`, classifyError(err))
+	}
+
+	metadata := output.KeyMetadata
+	if metadata == nil {
+		return "", fmt.Errorf(`KMS returned no metadata for key %q`, kid)
+	}
+	if metadata.KeyUsage != types.KeyUsageTypeGenerateVerifyMac {
+		return "", fmt.Errorf(`invalid key usage. expected %s, got %q: %w`, types.KeyUsageTypeGenerateVerifyMac, metadata.KeyUsage, ErrWrongKeyUsage)
+	}
+	// HMAC keys support exactly one MAC algorithm
+	if len(metadata.MacAlgorithms) != 1 {
+		return "", fmt.Errorf(`expected exactly one MAC algorithm, got %v`, metadata.MacAlgorithms)
+	}
+	alg := metadata.MacAlgorithms[0]
+
+	if cache := sv.cache; cache != nil {
+		cache.Set(kid, alg)
+	}
+
+	return alg, nil
+}
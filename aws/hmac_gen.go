@@ -0,0 +1,101 @@
+package awssigner
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+)
+
+// WithAlgorithm associates a new types.MacAlgorithmSpec with the object, which will be used for GenerateMAC() and VerifyMAC().
+//
+// If it is not specified, the algorithm is retrieved from KMS.
+func (cs *HMAC) WithAlgorithm(v types.MacAlgorithmSpec) *HMAC {
+	return &HMAC{
+		client:       cs.client,
+		alg:          v,
+		aliasRefresh: cs.aliasRefresh,
+		cache:        cs.cache,
+		ctx:          cs.ctx,
+		grantTokens:  cs.grantTokens,
+		kid:          cs.kid,
+	}
+}
+
+// WithAliasRefreshInterval specifies how long an alias is considered to
+// point to the same key. This only has an effect when a Cache is provided:
+// aliases are then resolved to the key ARN using KMS DescribeKey, and the
+// cached items are stored under the key ARN, so that they do not become
+// stale when the alias is updated to point to a different key.
+//
+// If it is not specified, DefaultAliasRefreshInterval is used.
+func (cs *HMAC) WithAliasRefreshInterval(v time.Duration) *HMAC {
+	return &HMAC{
+		client:       cs.client,
+		alg:          cs.alg,
+		aliasRefresh: v,
+		cache:        cs.cache,
+		ctx:          cs.ctx,
+		grantTokens:  cs.grantTokens,
+		kid:          cs.kid,
+	}
+}
+
+// WithCache specifies the cache storage for frequently used items.
+// Currently only the MAC algorithm is cached.
+//
+// If it is not specified, nothing will be cached.
+func (cs *HMAC) WithCache(v Cache) *HMAC {
+	return &HMAC{
+		client:       cs.client,
+		alg:          cs.alg,
+		aliasRefresh: cs.aliasRefresh,
+		cache:        v,
+		ctx:          cs.ctx,
+		grantTokens:  cs.grantTokens,
+		kid:          cs.kid,
+	}
+}
+
+// WithContext associates a new context.Context with the object, which will be used for GenerateMAC() and VerifyMAC()
+func (cs *HMAC) WithContext(v context.Context) *HMAC {
+	return &HMAC{
+		client:       cs.client,
+		alg:          cs.alg,
+		aliasRefresh: cs.aliasRefresh,
+		cache:        cs.cache,
+		ctx:          v,
+		grantTokens:  cs.grantTokens,
+		kid:          cs.kid,
+	}
+}
+
+// WithGrantTokens specifies the grant tokens to send along with each request
+// to KMS. Grant tokens allow you to use the permissions granted by a
+// grant before the grant has achieved eventual consistency.
+//
+// See https://docs.aws.amazon.com/kms/latest/developerguide/grant-manage.html#using-grant-token
+func (cs *HMAC) WithGrantTokens(v []string) *HMAC {
+	return &HMAC{
+		client:       cs.client,
+		alg:          cs.alg,
+		aliasRefresh: cs.aliasRefresh,
+		cache:        cs.cache,
+		ctx:          cs.ctx,
+		grantTokens:  v,
+		kid:          cs.kid,
+	}
+}
+
+// WithKeyID associates a new string with the object, which will be used for GenerateMAC() and VerifyMAC()
+func (cs *HMAC) WithKeyID(v string) *HMAC {
+	return &HMAC{
+		client:       cs.client,
+		alg:          cs.alg,
+		aliasRefresh: cs.aliasRefresh,
+		cache:        cs.cache,
+		ctx:          cs.ctx,
+		grantTokens:  cs.grantTokens,
+		kid:          v,
+	}
+}
@@ -0,0 +1,85 @@
+package awssigner_test
+
+import (
+	"context"
+	"crypto/rand"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+	awssigner "github.com/jwx-go/crypto-signer/v2/aws"
+	"github.com/jwx-go/crypto-signer/v2/aws/kmstest"
+)
+
+// noMetadataClient returns DescribeKey responses without key metadata
+type noMetadataClient struct {
+	*kmstest.KMS
+}
+
+func (c *noMetadataClient) DescribeKey(context.Context, *kms.DescribeKeyInput, ...func(*kms.Options)) (*kms.DescribeKeyOutput, error) {
+	return &kms.DescribeKeyOutput{}, nil
+}
+
+func TestHMAC(t *testing.T) {
+	client := kmstest.New()
+	output, err := client.CreateKey(context.Background(), &kms.CreateKeyInput{
+		KeySpec:  types.KeySpecHmac256,
+		KeyUsage: types.KeyUsageTypeGenerateVerifyMac,
+	})
+	if err != nil {
+		t.Fatalf("failed to create key: %s", err)
+	}
+
+	mac := awssigner.NewHMAC(client).
+		WithKeyID(aws.ToString(output.KeyMetadata.KeyId)).
+		WithCache(NewDumbCache())
+
+	alg, err := mac.Algorithm()
+	if err != nil {
+		t.Fatalf("failed to get MAC algorithm: %s", err)
+	}
+	if alg != types.MacAlgorithmSpecHmacSha256 {
+		t.Fatalf("expected %q, got %q", types.MacAlgorithmSpecHmacSha256, alg)
+	}
+
+	message := []byte("obla-di-obla-da")
+	generated, err := mac.GenerateMAC(message)
+	if err != nil {
+		t.Fatalf("failed to generate MAC: %s", err)
+	}
+	if err := mac.VerifyMAC(message, generated); err != nil {
+		t.Fatalf("failed to verify MAC: %s", err)
+	}
+	if err := mac.VerifyMAC([]byte("wrong payload"), generated); err == nil {
+		t.Fatalf("verification should have failed")
+	}
+
+	t.Run("wrong algorithm", func(t *testing.T) {
+		if _, err := mac.WithAlgorithm(types.MacAlgorithmSpecHmacSha512).GenerateMAC(message); err == nil {
+			t.Fatalf("generating a MAC with the wrong algorithm should fail")
+		}
+	})
+	t.Run("message too large", func(t *testing.T) {
+		large := make([]byte, 4097)
+		if _, err := rand.Read(large); err != nil {
+			t.Fatalf("failed to generate message: %s", err)
+		}
+		if _, err := mac.GenerateMAC(large); err == nil {
+			t.Fatalf("generating a MAC for a large message should fail")
+		}
+	})
+	t.Run("no metadata", func(t *testing.T) {
+		mac := awssigner.NewHMAC(&noMetadataClient{KMS: client}).
+			WithKeyID(aws.ToString(output.KeyMetadata.KeyId))
+		if _, err := mac.Algorithm(); err == nil {
+			t.Fatalf("expected an error for a DescribeKey response without metadata")
+		}
+	})
+	t.Run("wrong key usage", func(t *testing.T) {
+		client, kid := newTestKey(t, types.KeySpecEccNistP256)
+		if _, err := awssigner.NewHMAC(client).WithKeyID(kid).GenerateMAC(message); err == nil {
+			t.Fatalf("generating a MAC with a SIGN_VERIFY key should fail")
+		}
+	})
+}
@@ -72,7 +72,37 @@ func _main() error {
 	return nil
 }
 
+// carriedFields returns the names of the fields that do not have a
+// builder method, but must be copied to the new object. Unless specified
+// otherwise using `carry`, this is just the client.
+func carriedFields(obj *codegen.Object) ([]string, error) {
+	v, ok := obj.Extra(`carry`)
+	if !ok {
+		return []string{`client`}, nil
+	}
+
+	list, ok := v.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf(`"carry" should be a list in %q`, obj.Name(true))
+	}
+
+	var names []string
+	for _, item := range list {
+		name, ok := item.(string)
+		if !ok {
+			return nil, fmt.Errorf(`"carry" should be a list of strings in %q`, obj.Name(true))
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
 func generateSigner(obj *codegen.Object) error {
+	carried, err := carriedFields(obj)
+	if err != nil {
+		return err
+	}
+
 	var buf bytes.Buffer
 	o := codegen.NewOutput(&buf)
 
@@ -96,7 +126,9 @@ func generateSigner(obj *codegen.Object) error {
 		}
 		o.L(`func (cs *%[1]s) With%[2]s(v %[3]s) *%[1]s {`, obj.Name(true), field.GetterMethod(true), field.Type())
 		o.L(`return &%s{`, obj.Name(true))
-		o.L(`client: cs.client,`)
+		for _, name := range carried {
+			o.L(`%[1]s: cs.%[1]s,`, name)
+		}
 		for _, finner := range obj.Fields() {
 			if finner.Name(false) == field.Name(false) {
 				o.L(`%s: v,`, finner.Name(false))
@@ -0,0 +1,181 @@
+package jwxadapter
+
+import (
+	"crypto/aes"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwe"
+)
+
+// KeyAgreer is implemented by objects that can perform ECDH key agreement
+// with a remote public key, such as awssigner.ECDH and *ecdh.PrivateKey
+type KeyAgreer interface {
+	ECDH(*ecdh.PublicKey) ([]byte, error)
+}
+
+type ecdhKeyDecrypter struct {
+	agreer KeyAgreer
+}
+
+// ECDHKeyDecrypter wraps a KeyAgreer (such as awssigner.ECDH) so that it
+// can be used as a jwe.KeyDecrypter for the ECDH-ES, ECDH-ES+A128KW,
+// ECDH-ES+A192KW, and ECDH-ES+A256KW key encryption algorithms.
+//
+// Only the key agreement is performed by the KeyAgreer. The Concat KDF
+// and the AES key unwrapping described in RFC 7518 are performed locally,
+// using the shared secret.
+func ECDHKeyDecrypter(agreer KeyAgreer) jwe.KeyDecrypter {
+	return &ecdhKeyDecrypter{
+		agreer: agreer,
+	}
+}
+
+func (kd *ecdhKeyDecrypter) DecryptKey(alg jwa.KeyEncryptionAlgorithm, encryptedKey []byte, recipient jwe.Recipient, message *jwe.Message) ([]byte, error) {
+	var wrapped bool
+	var keylen int
+	var algID string
+	switch alg {
+	case jwa.ECDH_ES:
+		enc := message.ProtectedHeaders().ContentEncryption()
+		size, ok := contentKeySize(enc)
+		if !ok {
+			return nil, fmt.Errorf(`jwxadapter.ECDHKeyDecrypter does not support content encryption algorithm %q`, enc)
+		}
+		keylen = size
+		algID = enc.String()
+	case jwa.ECDH_ES_A128KW:
+		wrapped, keylen, algID = true, 16, alg.String()
+	case jwa.ECDH_ES_A192KW:
+		wrapped, keylen, algID = true, 24, alg.String()
+	case jwa.ECDH_ES_A256KW:
+		wrapped, keylen, algID = true, 32, alg.String()
+	default:
+		return nil, fmt.Errorf(`jwxadapter.ECDHKeyDecrypter does not support key encryption algorithm %s`, alg)
+	}
+
+	headers := []jwe.Headers{recipient.Headers(), message.ProtectedHeaders(), message.UnprotectedHeaders()}
+
+	var remote *ecdh.PublicKey
+	var apu, apv []byte
+	for _, h := range headers {
+		if h == nil {
+			continue
+		}
+		if epk := h.EphemeralPublicKey(); epk != nil && remote == nil {
+			var pubkey ecdsa.PublicKey
+			if err := epk.Raw(&pubkey); err != nil {
+				return nil, fmt.Errorf(`failed to get ephemeral public key: %w`, err)
+			}
+			key, err := pubkey.ECDH()
+			if err != nil {
+				return nil, fmt.Errorf(`failed to convert ephemeral public key: %w`, err)
+			}
+			remote = key
+		}
+		if v := h.AgreementPartyUInfo(); len(v) > 0 && apu == nil {
+			apu = v
+		}
+		if v := h.AgreementPartyVInfo(); len(v) > 0 && apv == nil {
+			apv = v
+		}
+	}
+	if remote == nil {
+		return nil, fmt.Errorf(`failed to get 'epk' field`)
+	}
+
+	z, err := kd.agreer.ECDH(remote)
+	if err != nil {
+		return nil, fmt.Errorf(`failed to perform key agreement: %w`, err)
+	}
+
+	key := concatKDF(z, algID, apu, apv, keylen)
+	if !wrapped {
+		return key, nil
+	}
+
+	cek, err := keyUnwrap(key, encryptedKey)
+	if err != nil {
+		return nil, fmt.Errorf(`failed to unwrap content encryption key: %w`, err)
+	}
+	return cek, nil
+}
+
+// contentKeySize returns the size of the key used by the given content
+// encryption algorithm
+func contentKeySize(enc jwa.ContentEncryptionAlgorithm) (int, bool) {
+	switch enc {
+	case jwa.A128GCM:
+		return 16, true
+	case jwa.A192GCM:
+		return 24, true
+	case jwa.A256GCM, jwa.A128CBC_HS256:
+		return 32, true
+	case jwa.A192CBC_HS384:
+		return 48, true
+	case jwa.A256CBC_HS512:
+		return 64, true
+	}
+	return 0, false
+}
+
+// concatKDF implements the Concat KDF as specified in RFC 7518 Section 4.6.2
+func concatKDF(z []byte, algID string, apu, apv []byte, keylen int) []byte {
+	var otherInfo []byte
+	for _, v := range [][]byte{[]byte(algID), apu, apv} {
+		otherInfo = binary.BigEndian.AppendUint32(otherInfo, uint32(len(v)))
+		otherInfo = append(otherInfo, v...)
+	}
+	otherInfo = binary.BigEndian.AppendUint32(otherInfo, uint32(keylen*8))
+
+	var out []byte
+	for counter := uint32(1); len(out) < keylen; counter++ {
+		h := sha256.New()
+		_ = binary.Write(h, binary.BigEndian, counter)
+		h.Write(z)
+		h.Write(otherInfo)
+		out = h.Sum(out)
+	}
+	return out[:keylen]
+}
+
+var keyWrapDefaultIV = []byte{0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6}
+
+// keyUnwrap implements the AES key unwrap algorithm as specified in RFC 3394
+func keyUnwrap(kek, ciphertext []byte) ([]byte, error) {
+	if len(ciphertext)%8 != 0 || len(ciphertext) < 24 {
+		return nil, fmt.Errorf(`invalid wrapped key length %d`, len(ciphertext))
+	}
+
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, fmt.Errorf(`failed to create cipher: %w`, err)
+	}
+
+	n := len(ciphertext)/8 - 1
+	a := make([]byte, 8)
+	copy(a, ciphertext[:8])
+	r := make([]byte, n*8)
+	copy(r, ciphertext[8:])
+
+	buf := make([]byte, 16)
+	for j := 5; j >= 0; j-- {
+		for i := n; i >= 1; i-- {
+			binary.BigEndian.PutUint64(buf[:8], binary.BigEndian.Uint64(a)^uint64(n*j+i))
+			copy(buf[8:], r[(i-1)*8:i*8])
+			block.Decrypt(buf, buf)
+			copy(a, buf[:8])
+			copy(r[(i-1)*8:i*8], buf[8:])
+		}
+	}
+
+	if subtle.ConstantTimeCompare(a, keyWrapDefaultIV) != 1 {
+		return nil, fmt.Errorf(`integrity check failed`)
+	}
+	return r, nil
+}
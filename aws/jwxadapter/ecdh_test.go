@@ -0,0 +1,60 @@
+package jwxadapter_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+	awssigner "github.com/jwx-go/crypto-signer/v2/aws"
+	"github.com/jwx-go/crypto-signer/v2/aws/jwxadapter"
+	"github.com/jwx-go/crypto-signer/v2/aws/kmstest"
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwe"
+)
+
+func TestECDHKeyDecrypter(t *testing.T) {
+	client := kmstest.New()
+	payload := []byte("obla-di-obla-da")
+
+	for _, spec := range []types.KeySpec{types.KeySpecEccNistP256, types.KeySpecEccNistP384, types.KeySpecEccNistP521} {
+		t.Run(string(spec), func(t *testing.T) {
+			output, err := client.CreateKey(context.Background(), &kms.CreateKeyInput{
+				KeySpec:  spec,
+				KeyUsage: types.KeyUsageTypeKeyAgreement,
+			})
+			if err != nil {
+				t.Fatalf("failed to create key: %s", err)
+			}
+
+			kd := awssigner.NewECDH(client).
+				WithKeyID(aws.ToString(output.KeyMetadata.KeyId))
+			pubkey, err := kd.GetPublicKey()
+			if err != nil {
+				t.Fatalf("failed to get public key: %s", err)
+			}
+
+			algs := []jwa.KeyEncryptionAlgorithm{jwa.ECDH_ES, jwa.ECDH_ES_A128KW, jwa.ECDH_ES_A192KW, jwa.ECDH_ES_A256KW}
+			for _, alg := range algs {
+				for _, enc := range []jwa.ContentEncryptionAlgorithm{jwa.A256GCM, jwa.A128CBC_HS256} {
+					t.Run(alg.String()+"/"+enc.String(), func(t *testing.T) {
+						encrypted, err := jwe.Encrypt(payload, jwe.WithKey(alg, pubkey), jwe.WithContentEncryption(enc))
+						if err != nil {
+							t.Fatalf("failed to encrypt: %s", err)
+						}
+
+						decrypted, err := jwe.Decrypt(encrypted, jwe.WithKey(alg, jwxadapter.ECDHKeyDecrypter(kd)))
+						if err != nil {
+							t.Fatalf("failed to decrypt: %s", err)
+						}
+						if !bytes.Equal(payload, decrypted) {
+							t.Fatalf("payload and decrypted does not match")
+						}
+					})
+				}
+			}
+		})
+	}
+}
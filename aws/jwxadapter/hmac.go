@@ -0,0 +1,115 @@
+package jwxadapter
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jws"
+)
+
+// MACer is implemented by objects that can generate and verify MACs
+// without exposing the secret key, such as awssigner.HMAC
+type MACer interface {
+	GenerateMAC(message []byte) ([]byte, error)
+	VerifyMAC(message, mac []byte) error
+}
+
+var registerHMACOnce sync.Once
+
+// RegisterHMAC registers jws signers and verifiers for HS256, HS384,
+// and HS512 that accept a MACer (such as awssigner.HMAC) as the key.
+// Keys of any other type are handled by the signers and verifiers that
+// were registered previously, so regular []byte secrets and jwk.Key
+// objects continue to work.
+//
+// Because jwx keeps a global registry of signers and verifiers, this
+// function affects all jws operations in the program. Calling it more
+// than once has no further effect.
+func RegisterHMAC() error {
+	var err error
+	registerHMACOnce.Do(func() {
+		for _, alg := range []jwa.SignatureAlgorithm{jwa.HS256, jwa.HS384, jwa.HS512} {
+			signer, serr := jws.NewSigner(alg)
+			if serr != nil {
+				err = fmt.Errorf(`failed to create fallback signer for %s: %w`, alg, serr)
+				return
+			}
+			verifier, verr := jws.NewVerifier(alg)
+			if verr != nil {
+				err = fmt.Errorf(`failed to create fallback verifier for %s: %w`, alg, verr)
+				return
+			}
+
+			hs := &hmacSignerVerifier{
+				alg:      alg,
+				signer:   signer,
+				verifier: verifier,
+			}
+			jws.RegisterSigner(alg, jws.SignerFactoryFn(func() (jws.Signer, error) {
+				return hs, nil
+			}))
+			jws.RegisterVerifier(alg, jws.VerifierFactoryFn(func() (jws.Verifier, error) {
+				return hs, nil
+			}))
+		}
+	})
+	return err
+}
+
+// hmacSignerVerifier is a jws.Signer and jws.Verifier that uses a MACer
+// if one is given as the key, and falls back to the original jwx
+// implementation otherwise
+type hmacSignerVerifier struct {
+	alg      jwa.SignatureAlgorithm
+	signer   jws.Signer
+	verifier jws.Verifier
+}
+
+func (hs *hmacSignerVerifier) Algorithm() jwa.SignatureAlgorithm {
+	return hs.alg
+}
+
+func (hs *hmacSignerVerifier) Sign(payload []byte, key interface{}) ([]byte, error) {
+	macer, ok := key.(MACer)
+	if !ok {
+		return hs.signer.Sign(payload, key)
+	}
+
+	mac, err := macer.GenerateMAC(payload)
+	if err != nil {
+		return nil, fmt.Errorf(`failed to generate MAC: %w`, err)
+	}
+	// make sure that the key actually uses the hash function that
+	// the algorithm says it does
+	if size := hmacSize(hs.alg); len(mac) != size {
+		return nil, fmt.Errorf(`expected a %d byte MAC for %s, got %d bytes`, size, hs.alg, len(mac))
+	}
+	return mac, nil
+}
+
+func (hs *hmacSignerVerifier) Verify(payload, signature []byte, key interface{}) error {
+	macer, ok := key.(MACer)
+	if !ok {
+		return hs.verifier.Verify(payload, signature, key)
+	}
+
+	if size := hmacSize(hs.alg); len(signature) != size {
+		return fmt.Errorf(`expected a %d byte MAC for %s, got %d bytes`, size, hs.alg, len(signature))
+	}
+	if err := macer.VerifyMAC(payload, signature); err != nil {
+		return fmt.Errorf(`failed to verify MAC: %w`, err)
+	}
+	return nil
+}
+
+func hmacSize(alg jwa.SignatureAlgorithm) int {
+	switch alg {
+	case jwa.HS256:
+		return 32
+	case jwa.HS384:
+		return 48
+	default:
+		return 64
+	}
+}
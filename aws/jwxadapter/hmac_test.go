@@ -0,0 +1,90 @@
+package jwxadapter_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+	awssigner "github.com/jwx-go/crypto-signer/v2/aws"
+	"github.com/jwx-go/crypto-signer/v2/aws/jwxadapter"
+	"github.com/jwx-go/crypto-signer/v2/aws/kmstest"
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jws"
+)
+
+func TestHMAC(t *testing.T) {
+	if err := jwxadapter.RegisterHMAC(); err != nil {
+		t.Fatalf("failed to register HMAC signers: %s", err)
+	}
+
+	client := kmstest.New()
+	payload := []byte("obla-di-obla-da")
+
+	testcases := map[jwa.SignatureAlgorithm]types.KeySpec{
+		jwa.HS256: types.KeySpecHmac256,
+		jwa.HS384: types.KeySpecHmac384,
+		jwa.HS512: types.KeySpecHmac512,
+	}
+	for alg, spec := range testcases {
+		t.Run(alg.String(), func(t *testing.T) {
+			output, err := client.CreateKey(context.Background(), &kms.CreateKeyInput{
+				KeySpec:  spec,
+				KeyUsage: types.KeyUsageTypeGenerateVerifyMac,
+			})
+			if err != nil {
+				t.Fatalf("failed to create key: %s", err)
+			}
+
+			mac := awssigner.NewHMAC(client).
+				WithKeyID(aws.ToString(output.KeyMetadata.KeyId))
+
+			signed, err := jws.Sign(payload, jws.WithKey(alg, mac))
+			if err != nil {
+				t.Fatalf("failed to sign: %s", err)
+			}
+
+			verified, err := jws.Verify(signed, jws.WithKey(alg, mac))
+			if err != nil {
+				t.Fatalf("failed to verify: %s", err)
+			}
+			if !bytes.Equal(payload, verified) {
+				t.Fatalf("payload and verified does not match")
+			}
+
+			// tamper with the payload
+			tampered := bytes.Replace(signed, []byte(`.`), []byte(`.e30`), 1)
+			if _, err := jws.Verify(tampered, jws.WithKey(alg, mac)); err == nil {
+				t.Fatalf("verification of a tampered message should fail")
+			}
+		})
+	}
+
+	t.Run("algorithm mismatch", func(t *testing.T) {
+		output, err := client.CreateKey(context.Background(), &kms.CreateKeyInput{
+			KeySpec:  types.KeySpecHmac384,
+			KeyUsage: types.KeyUsageTypeGenerateVerifyMac,
+		})
+		if err != nil {
+			t.Fatalf("failed to create key: %s", err)
+		}
+		mac := awssigner.NewHMAC(client).
+			WithKeyID(aws.ToString(output.KeyMetadata.KeyId))
+		if _, err := jws.Sign(payload, jws.WithKey(jwa.HS256, mac)); err == nil {
+			t.Fatalf("signing HS256 with an HMAC_384 key should fail")
+		}
+	})
+
+	t.Run("fallback to []byte keys", func(t *testing.T) {
+		secret := []byte("0123456789abcdef0123456789abcdef")
+		signed, err := jws.Sign(payload, jws.WithKey(jwa.HS256, secret))
+		if err != nil {
+			t.Fatalf("failed to sign: %s", err)
+		}
+		if _, err := jws.Verify(signed, jws.WithKey(jwa.HS256, secret)); err != nil {
+			t.Fatalf("failed to verify: %s", err)
+		}
+	})
+}
@@ -0,0 +1,64 @@
+// Package jwxadapter contains adapters that allow the objects in awssigner
+// to be used with github.com/lestrrat-go/jwx/v2 in places where jwx does not
+// accept the standard crypto interfaces.
+//
+// For example, jwx expects an *rsa.PrivateKey to decrypt RSA-OAEP encrypted
+// content encryption keys, so an awssigner.RSADecrypter has to be wrapped
+// using KeyDecrypter before it can be passed to jwe.Decrypt:
+//
+//	dec := awssigner.NewRSADecrypter(client).
+//	  WithKeyID(kid)
+//	payload, err := jwe.Decrypt(msg, jwe.WithKey(jwa.RSA_OAEP_256, jwxadapter.KeyDecrypter(dec)))
+//
+// Similarly, awssigner.ECDH can be wrapped using ECDHKeyDecrypter to
+// decrypt ECDH-ES encrypted messages, and awssigner.HMAC can be used
+// to sign and verify HS256/HS384/HS512 messages after calling RegisterHMAC.
+// After calling RegisterVerifiers, awssigner.Verifier can be used to verify
+// RS*, PS*, ES*, and EdDSA messages using KMS, without the public key.
+package jwxadapter
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"fmt"
+
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwe"
+)
+
+type keyDecrypter struct {
+	decrypter crypto.Decrypter
+}
+
+// KeyDecrypter wraps a crypto.Decrypter for RSA keys (such as
+// awssigner.RSADecrypter) so that it can be used as a jwe.KeyDecrypter.
+// The RSA-OAEP key encryption algorithms are supported, and the
+// corresponding *rsa.OAEPOptions are passed to the crypto.Decrypter.
+func KeyDecrypter(decrypter crypto.Decrypter) jwe.KeyDecrypter {
+	return &keyDecrypter{
+		decrypter: decrypter,
+	}
+}
+
+func (kd *keyDecrypter) DecryptKey(alg jwa.KeyEncryptionAlgorithm, encryptedKey []byte, _ jwe.Recipient, _ *jwe.Message) ([]byte, error) {
+	var hash crypto.Hash
+	switch alg {
+	case jwa.RSA_OAEP:
+		hash = crypto.SHA1
+	case jwa.RSA_OAEP_256:
+		hash = crypto.SHA256
+	case jwa.RSA_OAEP_384:
+		hash = crypto.SHA384
+	case jwa.RSA_OAEP_512:
+		hash = crypto.SHA512
+	default:
+		return nil, fmt.Errorf(`jwxadapter.KeyDecrypter does not support key encryption algorithm %s`, alg)
+	}
+
+	cek, err := kd.decrypter.Decrypt(rand.Reader, encryptedKey, &rsa.OAEPOptions{Hash: hash})
+	if err != nil {
+		return nil, fmt.Errorf(`failed to decrypt content encryption key: %w`, err)
+	}
+	return cek, nil
+}
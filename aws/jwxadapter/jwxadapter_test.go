@@ -0,0 +1,62 @@
+package jwxadapter_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+	awssigner "github.com/jwx-go/crypto-signer/v2/aws"
+	"github.com/jwx-go/crypto-signer/v2/aws/jwxadapter"
+	"github.com/jwx-go/crypto-signer/v2/aws/kmstest"
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwe"
+)
+
+func TestKeyDecrypter(t *testing.T) {
+	client := kmstest.New()
+	output, err := client.CreateKey(context.Background(), &kms.CreateKeyInput{
+		KeySpec:  types.KeySpecRsa2048,
+		KeyUsage: types.KeyUsageTypeEncryptDecrypt,
+	})
+	if err != nil {
+		t.Fatalf("failed to create key: %s", err)
+	}
+
+	dec := awssigner.NewRSADecrypter(client).
+		WithKeyID(aws.ToString(output.KeyMetadata.KeyId))
+	pubkey, err := dec.GetPublicKey()
+	if err != nil {
+		t.Fatalf("failed to get public key: %s", err)
+	}
+
+	payload := []byte("obla-di-obla-da")
+	for _, alg := range []jwa.KeyEncryptionAlgorithm{jwa.RSA_OAEP, jwa.RSA_OAEP_256} {
+		t.Run(alg.String(), func(t *testing.T) {
+			encrypted, err := jwe.Encrypt(payload, jwe.WithKey(alg, pubkey))
+			if err != nil {
+				t.Fatalf("failed to encrypt: %s", err)
+			}
+
+			decrypted, err := jwe.Decrypt(encrypted, jwe.WithKey(alg, jwxadapter.KeyDecrypter(dec)))
+			if err != nil {
+				t.Fatalf("failed to decrypt: %s", err)
+			}
+			if !bytes.Equal(payload, decrypted) {
+				t.Fatalf("payload and decrypted does not match")
+			}
+		})
+	}
+
+	t.Run(jwa.RSA_OAEP_512.String(), func(t *testing.T) {
+		encrypted, err := jwe.Encrypt(payload, jwe.WithKey(jwa.RSA_OAEP_512, pubkey))
+		if err != nil {
+			t.Fatalf("failed to encrypt: %s", err)
+		}
+		if _, err := jwe.Decrypt(encrypted, jwe.WithKey(jwa.RSA_OAEP_512, jwxadapter.KeyDecrypter(dec))); err == nil {
+			t.Fatalf("RSA-OAEP-512 is not supported by AWS KMS, and should fail")
+		}
+	})
+}
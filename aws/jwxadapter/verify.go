@@ -0,0 +1,161 @@
+package jwxadapter
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"encoding/asn1"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jws"
+)
+
+// SignatureVerifier is implemented by objects that can verify signatures
+// without exposing the public key, such as awssigner.Verifier. digest and
+// opts follow the conventions of crypto.Signer, and ECDSA signatures are
+// ASN.1 DER encoded.
+type SignatureVerifier interface {
+	Verify(digest, signature []byte, opts crypto.SignerOpts) error
+}
+
+var registerVerifiersOnce sync.Once
+
+// verifyAlgorithms lists the algorithms handled by RegisterVerifiers
+var verifyAlgorithms = []jwa.SignatureAlgorithm{
+	jwa.RS256, jwa.RS384, jwa.RS512,
+	jwa.PS256, jwa.PS384, jwa.PS512,
+	jwa.ES256, jwa.ES384, jwa.ES512, jwa.ES256K,
+	jwa.EdDSA,
+}
+
+// RegisterVerifiers registers jws verifiers for the RS*, PS*, ES*, ES256K,
+// and EdDSA algorithms that accept a SignatureVerifier (such as
+// awssigner.Verifier) as the key. This allows jws.Verify to verify
+// messages without ever holding the public key:
+//
+//	v := awssigner.NewVerifier(client).
+//	  WithKeyID(kid)
+//	payload, err := jws.Verify(msg, jws.WithKey(jwa.ES256, v.WithContext(ctx)))
+//
+// Keys of any other type are handled by the verifiers that were registered
+// previously, so public keys and jwk.Key objects continue to work.
+//
+// Because jwx keeps a global registry of verifiers, this function affects
+// all jws operations in the program. Calling it more than once has no
+// further effect.
+func RegisterVerifiers() error {
+	var err error
+	registerVerifiersOnce.Do(func() {
+		for _, alg := range verifyAlgorithms {
+			verifier, verr := jws.NewVerifier(alg)
+			if verr != nil {
+				err = fmt.Errorf(`failed to create fallback verifier for %s: %w`, alg, verr)
+				return
+			}
+
+			rv := &remoteVerifier{
+				alg:      alg,
+				verifier: verifier,
+			}
+			jws.RegisterVerifier(alg, jws.VerifierFactoryFn(func() (jws.Verifier, error) {
+				return rv, nil
+			}))
+		}
+	})
+	return err
+}
+
+// remoteVerifier is a jws.Verifier that uses a SignatureVerifier if one
+// is given as the key, and falls back to the original jwx implementation
+// otherwise
+type remoteVerifier struct {
+	alg      jwa.SignatureAlgorithm
+	verifier jws.Verifier
+}
+
+func (rv *remoteVerifier) Verify(payload, signature []byte, key interface{}) error {
+	sv, ok := key.(SignatureVerifier)
+	if !ok {
+		return rv.verifier.Verify(payload, signature, key)
+	}
+
+	// EdDSA signs the message itself
+	if rv.alg == jwa.EdDSA {
+		if err := sv.Verify(payload, signature, crypto.Hash(0)); err != nil {
+			return fmt.Errorf(`failed to verify signature: %w`, err)
+		}
+		return nil
+	}
+
+	hash, err := signatureHash(rv.alg)
+	if err != nil {
+		return err
+	}
+	h := hash.New()
+	h.Write(payload)
+	digest := h.Sum(nil)
+
+	var opts crypto.SignerOpts = hash
+	switch rv.alg {
+	case jwa.PS256, jwa.PS384, jwa.PS512:
+		opts = &rsa.PSSOptions{
+			SaltLength: rsa.PSSSaltLengthEqualsHash,
+			Hash:       hash,
+		}
+	case jwa.ES256, jwa.ES384, jwa.ES512, jwa.ES256K:
+		// JWS uses the fixed size r||s encoding, while KMS expects
+		// ASN.1 DER
+		signature, err = ecdsaSignatureDER(rv.alg, signature)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := sv.Verify(digest, signature, opts); err != nil {
+		return fmt.Errorf(`failed to verify signature: %w`, err)
+	}
+	return nil
+}
+
+func signatureHash(alg jwa.SignatureAlgorithm) (crypto.Hash, error) {
+	switch alg {
+	case jwa.RS256, jwa.PS256, jwa.ES256, jwa.ES256K:
+		return crypto.SHA256, nil
+	case jwa.RS384, jwa.PS384, jwa.ES384:
+		return crypto.SHA384, nil
+	case jwa.RS512, jwa.PS512, jwa.ES512:
+		return crypto.SHA512, nil
+	default:
+		return 0, fmt.Errorf(`unsupported signature algorithm %s`, alg)
+	}
+}
+
+// ecdsaSignatureDER converts a JWS ECDSA signature (r||s, each padded to
+// the size of the curve) to ASN.1 DER
+func ecdsaSignatureDER(alg jwa.SignatureAlgorithm, signature []byte) ([]byte, error) {
+	var size int
+	switch alg {
+	case jwa.ES256, jwa.ES256K:
+		size = 32
+	case jwa.ES384:
+		size = 48
+	default:
+		size = 66
+	}
+	if len(signature) != 2*size {
+		return nil, fmt.Errorf(`expected a %d byte signature for %s, got %d bytes`, 2*size, alg, len(signature))
+	}
+
+	der, err := asn1.Marshal(struct {
+		R, S *big.Int
+	}{
+		R: new(big.Int).SetBytes(signature[:size]),
+		S: new(big.Int).SetBytes(signature[size:]),
+	})
+	if err != nil {
+		return nil, fmt.Errorf(`failed to encode signature: %w`, err)
+	}
+	return der, nil
+}
@@ -0,0 +1,91 @@
+package jwxadapter_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+	awssigner "github.com/jwx-go/crypto-signer/v2/aws"
+	"github.com/jwx-go/crypto-signer/v2/aws/jwxadapter"
+	"github.com/jwx-go/crypto-signer/v2/aws/kmstest"
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jws"
+)
+
+// noPublicKeyClient denies GetPublicKey, like a key policy that does not
+// allow kms:GetPublicKey would
+type noPublicKeyClient struct {
+	*kmstest.KMS
+}
+
+func (c *noPublicKeyClient) GetPublicKey(context.Context, *kms.GetPublicKeyInput, ...func(*kms.Options)) (*kms.GetPublicKeyOutput, error) {
+	return nil, &types.KMSInvalidStateException{Message: aws.String(`GetPublicKey is not allowed`)}
+}
+
+func TestRegisterVerifiers(t *testing.T) {
+	if err := jwxadapter.RegisterVerifiers(); err != nil {
+		t.Fatalf("failed to register verifiers: %s", err)
+	}
+
+	client := kmstest.New()
+	payload := []byte("obla-di-obla-da")
+
+	testcases := []struct {
+		Algorithm jwa.SignatureAlgorithm
+		Spec      types.KeySpec
+	}{
+		{Algorithm: jwa.RS256, Spec: types.KeySpecRsa2048},
+		{Algorithm: jwa.PS384, Spec: types.KeySpecRsa3072},
+		{Algorithm: jwa.ES256, Spec: types.KeySpecEccNistP256},
+		{Algorithm: jwa.ES384, Spec: types.KeySpecEccNistP384},
+		{Algorithm: jwa.ES512, Spec: types.KeySpecEccNistP521},
+		{Algorithm: jwa.ES256K, Spec: types.KeySpecEccSecgP256k1},
+		{Algorithm: jwa.EdDSA, Spec: types.KeySpecEccNistEdwards25519},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.Algorithm.String(), func(t *testing.T) {
+			output, err := client.CreateKey(context.Background(), &kms.CreateKeyInput{
+				KeySpec:  tc.Spec,
+				KeyUsage: types.KeyUsageTypeSignVerify,
+			})
+			if err != nil {
+				t.Fatalf("failed to create key: %s", err)
+			}
+			kid := aws.ToString(output.KeyMetadata.KeyId)
+
+			signed, err := jws.Sign(payload, jws.WithKey(tc.Algorithm, awssigner.New(client).WithKeyID(kid)))
+			if err != nil {
+				t.Fatalf("failed to sign: %s", err)
+			}
+
+			v := awssigner.NewVerifier(&noPublicKeyClient{KMS: client}).
+				WithKeyID(kid)
+
+			verified, err := jws.Verify(signed, jws.WithKey(tc.Algorithm, v))
+			if err != nil {
+				t.Fatalf("failed to verify: %s", err)
+			}
+			if !bytes.Equal(payload, verified) {
+				t.Fatalf("payload and verified does not match")
+			}
+
+			// public keys are still handled by the original verifiers
+			pubkey, err := awssigner.New(client).WithKeyID(kid).GetPublicKey()
+			if err != nil {
+				t.Fatalf("failed to get public key: %s", err)
+			}
+			if _, err := jws.Verify(signed, jws.WithKey(tc.Algorithm, pubkey)); err != nil {
+				t.Fatalf("failed to verify using the public key: %s", err)
+			}
+
+			// tamper with the payload
+			tampered := bytes.Replace(signed, []byte(`.`), []byte(`.e30`), 1)
+			if _, err := jws.Verify(tampered, jws.WithKey(tc.Algorithm, v)); err == nil {
+				t.Fatalf("verification of a tampered message should fail")
+			}
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package awssigner
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+	"github.com/emmansun/gmsm/sm2"
+	"github.com/emmansun/gmsm/smx509"
+	"github.com/jwx-go/crypto-signer/v2/internal/ecdsaenc"
+)
+
+// keyInfo holds the information about a KMS key that is returned along
+// with its public key. Signer uses the key spec and the signing algorithms
+// in order to decide how to sign.
+type keyInfo struct {
+	spec      types.KeySpec
+	usage     types.KeyUsageType
+	algs      []types.SigningAlgorithmSpec
+	publicKey crypto.PublicKey
+}
+
+// keyInfoCacheKey is the key under which keyInfo is stored in the Cache.
+// It is a distinct type so that it does not collide with the other items
+// that are stored under the key ARN, such as the MAC algorithm of HMAC
+// keys.
+type keyInfoCacheKey string
+
+// keyMemo remembers the key information that was retrieved from KMS, so
+// that it is retrieved only once even if no Cache is provided. It is
+// shared between all the objects derived from the same object, and is
+// keyed by the resolved key ID, so that objects that were given
+// different keys using WithKeyID() do not mix them up.
+type keyMemo struct {
+	mu    sync.Mutex
+	infos map[string]*keyInfo
+}
+
+func (m *keyMemo) get(kid string) (*keyInfo, bool) {
+	if m == nil {
+		return nil, false
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	info, ok := m.infos[kid]
+	return info, ok
+}
+
+func (m *keyMemo) set(kid string, info *keyInfo) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.infos == nil {
+		m.infos = make(map[string]*keyInfo)
+	}
+	m.infos[kid] = info
+}
+
+// kmsKey identifies a KMS key along with the settings that are required
+// to access it. Each object in this package builds one from its own
+// fields, and uses it for the operations that do not depend on the kind
+// of the key.
+type kmsKey struct {
+	aliasRefresh time.Duration
+	cache        Cache
+	client       Client
+	grantTokens  []string
+	kid          string
+	memo         *keyMemo
+}
+
+// resolve returns the key ID to use in requests to KMS (see resolveKeyID)
+func (k kmsKey) resolve(ctx context.Context) (string, error) {
+	kid, err := resolveKeyID(ctx, k.client, k.kid, k.grantTokens, k.cache, k.aliasRefresh)
+	if err != nil {
+		return "", fmt.Errorf(`failed to resolve key ID: %w`, err)
+	}
+	return kid, nil
+}
+
+// arn returns the ARN of the key (see resolveKeyARN)
+func (k kmsKey) arn(ctx context.Context) (string, error) {
+	return resolveKeyARN(ctx, k.client, k.kid, k.grantTokens, k.cache, k.aliasRefresh)
+}
+
+// info returns the public key of the key, along with the other
+// information returned by the KMS GetPublicKey API. The key must be
+// usable for the given purpose.
+func (k kmsKey) info(ctx context.Context, usage types.KeyUsageType) (*keyInfo, error) {
+	kid, err := k.resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return k.resolvedInfo(ctx, kid, usage)
+}
+
+// resolvedInfo works like info, for a key ID that has already been
+// resolved.
+func (k kmsKey) resolvedInfo(ctx context.Context, kid string, usage types.KeyUsageType) (*keyInfo, error) {
+	info, ok := k.memo.get(kid)
+	if !ok {
+		if cache := k.cache; cache != nil {
+			if v, ok := cache.Get(keyInfoCacheKey(kid)); ok {
+				info, _ = v.(*keyInfo)
+			}
+		}
+	}
+	if info != nil {
+		if info.usage != usage {
+			return nil, fmt.Errorf(`invalid key usage. expected %s, got %q: %w`, usage, info.usage, ErrWrongKeyUsage)
+		}
+		k.memo.set(kid, info)
+		return info, nil
+	}
+
+	output, err := kmsGetPublicKey(ctx, k.client, kid, k.grantTokens, usage)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := parseKMSPublicKey(output.KeySpec, output.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf(`failed to parse key: %w`, err)
+	}
+
+	info = &keyInfo{
+		spec:      output.KeySpec,
+		usage:     output.KeyUsage,
+		algs:      output.SigningAlgorithms,
+		publicKey: key,
+	}
+
+	k.memo.set(kid, info)
+	if cache := k.cache; cache != nil {
+		cache.Set(keyInfoCacheKey(kid), info)
+	}
+	return info, nil
+}
+
+// checkSign makes sure that the key can be used for signing with alg
+// (see kmsCheckSign)
+func (k kmsKey) checkSign(ctx context.Context, alg types.SigningAlgorithmSpec) error {
+	kid, err := k.resolve(ctx)
+	if err != nil {
+		return err
+	}
+	return kmsCheckSign(ctx, k.client, kid, k.grantTokens, alg)
+}
+
+// selfVerify verifies a signature that KMS returned for the (resolved)
+// key ID kid against its public key. A mismatch is reported to hook.
+//
+// The public key is memoized, so when kid is an alias (i.e. there is no
+// Cache), it stays the public key of the key that the alias pointed to
+// when it was first retrieved.
+func (k kmsKey) selfVerify(ctx context.Context, kid string, hook func(string, error), message []byte, mt types.MessageType, alg types.SigningAlgorithmSpec, signature []byte) error {
+	info, err := k.resolvedInfo(ctx, kid, types.KeyUsageTypeSignVerify)
+	if err != nil {
+		return fmt.Errorf(`failed to retrieve public key for self-verification: %w`, err)
+	}
+	return reportMismatch(kid, hook, verifySignature(info.publicKey, alg, message, mt, signature))
+}
+
+// parseKMSPublicKey parses a DER encoded public key returned by the KMS
+// GetPublicKey API for a key with the given key spec.
+func parseKMSPublicKey(spec types.KeySpec, der []byte) (crypto.PublicKey, error) {
+	switch spec {
+	case types.KeySpecMlDsa44, types.KeySpecMlDsa65, types.KeySpecMlDsa87:
+		return ParseMLDSAPublicKey(der)
+	case types.KeySpecSm2:
+		key, err := smx509.ParsePKIXPublicKey(der)
+		if err != nil {
+			return nil, err
+		}
+		if pubkey, ok := key.(*ecdsa.PublicKey); !ok || !sm2.IsSM2PublicKey(pubkey) {
+			return nil, fmt.Errorf(`expected SM2 public key, got %T`, key)
+		}
+		return key, nil
+	default:
+		return ecdsaenc.ParsePublicKey(der)
+	}
+}
@@ -0,0 +1,38 @@
+package awssigner_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+	awssigner "github.com/jwx-go/crypto-signer/v2/aws"
+)
+
+func TestSharedCache(t *testing.T) {
+	client, kid := newTestKey(t, types.KeySpecRsa2048)
+	cache := NewDumbCache()
+
+	if _, err := awssigner.New(client).WithKeyID(kid).WithCache(cache).GetPublicKey(); err != nil {
+		t.Fatalf("failed to get public key: %s", err)
+	}
+
+	t.Run("same key type", func(t *testing.T) {
+		if _, err := awssigner.NewRSA(client).WithKeyID(kid).WithCache(cache).GetPublicKey(); err != nil {
+			t.Fatalf("failed to get public key: %s", err)
+		}
+		if client.publicKeyCalls != 1 {
+			t.Fatalf("expected the public key to be retrieved once, got %d", client.publicKeyCalls)
+		}
+	})
+	t.Run("wrong key type", func(t *testing.T) {
+		if _, err := awssigner.NewECDSA(client).WithKeyID(kid).WithCache(cache).GetPublicKey(); err == nil {
+			t.Fatalf("an RSA key should not be usable with ECDSA")
+		}
+	})
+	t.Run("wrong key usage", func(t *testing.T) {
+		_, err := awssigner.NewRSADecrypter(client).WithKeyID(kid).WithCache(cache).GetPublicKey()
+		if !errors.Is(err, awssigner.ErrWrongKeyUsage) {
+			t.Fatalf("expected ErrWrongKeyUsage, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,235 @@
+package awssigner
+
+import (
+	"context"
+	"crypto"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+)
+
+// Client is the subset of the AWS KMS API that is used by the objects
+// in this package. *kms.Client from github.com/aws/aws-sdk-go-v2/service/kms
+// satisfies this interface.
+//
+// Accepting an interface allows you to wrap the client (e.g. to add
+// instrumentation), or to replace it altogether in tests. See the kmstest
+// package for an in-memory implementation.
+type Client interface {
+	Sign(context.Context, *kms.SignInput, ...func(*kms.Options)) (*kms.SignOutput, error)
+	GetPublicKey(context.Context, *kms.GetPublicKeyInput, ...func(*kms.Options)) (*kms.GetPublicKeyOutput, error)
+	DescribeKey(context.Context, *kms.DescribeKeyInput, ...func(*kms.Options)) (*kms.DescribeKeyOutput, error)
+	Verify(context.Context, *kms.VerifyInput, ...func(*kms.Options)) (*kms.VerifyOutput, error)
+	Decrypt(context.Context, *kms.DecryptInput, ...func(*kms.Options)) (*kms.DecryptOutput, error)
+	DeriveSharedSecret(context.Context, *kms.DeriveSharedSecretInput, ...func(*kms.Options)) (*kms.DeriveSharedSecretOutput, error)
+	GenerateMac(context.Context, *kms.GenerateMacInput, ...func(*kms.Options)) (*kms.GenerateMacOutput, error)
+	VerifyMac(context.Context, *kms.VerifyMacInput, ...func(*kms.Options)) (*kms.VerifyMacOutput, error)
+}
+
+var _ Client = (*kms.Client)(nil)
+
+// kmsSign calls the KMS Sign API, and returns the resulting signature.
+func kmsSign(ctx context.Context, client Client, kid string, grantTokens []string, message []byte, mt types.MessageType, alg types.SigningAlgorithmSpec) ([]byte, error) {
+	input := kms.SignInput{
+		KeyId:            aws.String(kid),
+		GrantTokens:      grantTokens,
+		Message:          message,
+		MessageType:      mt,
+		SigningAlgorithm: alg,
+	}
+	signed, err := client.Sign(ctx, &input)
+	if err != nil {
+		return nil, fmt.Errorf(`failed to sign via KMS: %w`, classifyError(err))
+	}
+
+	return signed.Signature, nil
+}
+
+// kmsSignLimited calls kmsSign, subject to the rate limit and the
+// retries of limiter, which may be nil.
+func kmsSignLimited(ctx context.Context, limiter *Limiter, client Client, kid string, grantTokens []string, message []byte, mt types.MessageType, alg types.SigningAlgorithmSpec) ([]byte, error) {
+	var signed []byte
+	err := limiter.do(ctx, kid, func() error {
+		var err error
+		signed, err = kmsSign(ctx, client, kid, grantTokens, message, mt, alg)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return signed, nil
+}
+
+// kmsVerify calls the KMS Verify API. It returns nil only if KMS reports
+// that the signature is valid.
+func kmsVerify(ctx context.Context, client Client, kid string, grantTokens []string, message, signature []byte, mt types.MessageType, alg types.SigningAlgorithmSpec) error {
+	input := kms.VerifyInput{
+		KeyId:            aws.String(kid),
+		GrantTokens:      grantTokens,
+		Message:          message,
+		MessageType:      mt,
+		Signature:        signature,
+		SigningAlgorithm: alg,
+	}
+	verified, err := client.Verify(ctx, &input)
+	if err != nil {
+		return fmt.Errorf(`failed to verify via KMS: %w`, classifyError(err))
+	}
+	if !verified.SignatureValid {
+		return fmt.Errorf(`invalid signature`)
+	}
+	return nil
+}
+
+// kmsCheckSign calls the KMS Sign API with DryRun enabled, so that the
+// permissions and the state of the key are checked without actually
+// signing anything. The message is a dummy, appropriate for alg.
+func kmsCheckSign(ctx context.Context, client Client, kid string, grantTokens []string, alg types.SigningAlgorithmSpec) error {
+	// algorithms that do not sign a digest get a one byte RAW message
+	message := []byte{0}
+	mt := types.MessageTypeRaw
+	if hash := signingAlgorithmHash(alg); hash != crypto.Hash(0) {
+		message = make([]byte, hash.Size())
+		mt = types.MessageTypeDigest
+	}
+
+	input := kms.SignInput{
+		KeyId:            aws.String(kid),
+		GrantTokens:      grantTokens,
+		Message:          message,
+		MessageType:      mt,
+		SigningAlgorithm: alg,
+		DryRun:           aws.Bool(true),
+	}
+	_, err := client.Sign(ctx, &input)
+
+	// KMS reports a successful dry run as an error
+	var dryRun *types.DryRunOperationException
+	if err != nil && !errors.As(err, &dryRun) {
+		return fmt.Errorf(`failed to sign via KMS (dry run): %w`, classifyError(err))
+	}
+	return nil
+}
+
+// kmsDecrypt calls the KMS Decrypt API, and returns the resulting plaintext.
+func kmsDecrypt(ctx context.Context, client Client, kid string, grantTokens []string, ciphertext []byte, alg types.EncryptionAlgorithmSpec) ([]byte, error) {
+	input := kms.DecryptInput{
+		KeyId:               aws.String(kid),
+		GrantTokens:         grantTokens,
+		CiphertextBlob:      ciphertext,
+		EncryptionAlgorithm: alg,
+	}
+	decrypted, err := client.Decrypt(ctx, &input)
+	if err != nil {
+		return nil, fmt.Errorf(`failed to decrypt via KMS: %w`, classifyError(err))
+	}
+
+	return decrypted.Plaintext, nil
+}
+
+// kmsDeriveSharedSecret calls the KMS DeriveSharedSecret API, and returns
+// the raw shared secret.
+func kmsDeriveSharedSecret(ctx context.Context, client Client, kid string, grantTokens []string, publicKey []byte) ([]byte, error) {
+	input := kms.DeriveSharedSecretInput{
+		KeyId:                 aws.String(kid),
+		GrantTokens:           grantTokens,
+		KeyAgreementAlgorithm: types.KeyAgreementAlgorithmSpecEcdh,
+		PublicKey:             publicKey,
+	}
+	derived, err := client.DeriveSharedSecret(ctx, &input)
+	if err != nil {
+		return nil, fmt.Errorf(`failed to derive shared secret via KMS: %w`, classifyError(err))
+	}
+
+	return derived.SharedSecret, nil
+}
+
+// kmsGenerateMac calls the KMS GenerateMac API, and returns the resulting MAC.
+func kmsGenerateMac(ctx context.Context, client Client, kid string, grantTokens []string, message []byte, alg types.MacAlgorithmSpec) ([]byte, error) {
+	input := kms.GenerateMacInput{
+		KeyId:        aws.String(kid),
+		GrantTokens:  grantTokens,
+		Message:      message,
+		MacAlgorithm: alg,
+	}
+	generated, err := client.GenerateMac(ctx, &input)
+	if err != nil {
+		return nil, fmt.Errorf(`failed to generate MAC via KMS: %w`, classifyError(err))
+	}
+
+	return generated.Mac, nil
+}
+
+// kmsVerifyMac calls the KMS VerifyMac API. An invalid MAC is reported
+// as an error.
+func kmsVerifyMac(ctx context.Context, client Client, kid string, grantTokens []string, message, mac []byte, alg types.MacAlgorithmSpec) error {
+	input := kms.VerifyMacInput{
+		KeyId:        aws.String(kid),
+		GrantTokens:  grantTokens,
+		Message:      message,
+		Mac:          mac,
+		MacAlgorithm: alg,
+	}
+	verified, err := client.VerifyMac(ctx, &input)
+	if err != nil {
+		return fmt.Errorf(`failed to verify MAC via KMS: %w`, classifyError(err))
+	}
+	if !verified.MacValid {
+		return fmt.Errorf(`invalid MAC`)
+	}
+	return nil
+}
+
+// kmsCheckKeyState calls the KMS DescribeKey API, and makes sure that
+// the key is enabled, and that it can be used for the given purpose.
+func kmsCheckKeyState(ctx context.Context, client Client, kid string, grantTokens []string, usage types.KeyUsageType) error {
+	input := kms.DescribeKeyInput{
+		KeyId:       aws.String(kid),
+		GrantTokens: grantTokens,
+	}
+	output, err := client.DescribeKey(ctx, &input)
+	if err != nil {
+		return fmt.Errorf(`failed to describe key via KMS: %w`, classifyError(err))
+	}
+
+	metadata := output.KeyMetadata
+	if metadata == nil {
+		return fmt.Errorf(`KMS returned no metadata for key %q`, kid)
+	}
+
+	switch metadata.KeyState {
+	case types.KeyStateEnabled:
+	case types.KeyStateDisabled:
+		return fmt.Errorf(`%s: %w`, aws.ToString(metadata.Arn), ErrKeyDisabled)
+	case types.KeyStatePendingDeletion, types.KeyStatePendingReplicaDeletion:
+		return fmt.Errorf(`%s: %w`, aws.ToString(metadata.Arn), ErrKeyPendingDeletion)
+	default:
+		return fmt.Errorf(`%s cannot be used in state %s`, aws.ToString(metadata.Arn), metadata.KeyState)
+	}
+
+	if metadata.KeyUsage != usage {
+		return fmt.Errorf(`invalid key usage. expected %s, got %q: %w`, usage, metadata.KeyUsage, ErrWrongKeyUsage)
+	}
+	return nil
+}
+
+// kmsGetPublicKey calls the KMS GetPublicKey API, and makes sure that
+// the key can be used for the given purpose.
+func kmsGetPublicKey(ctx context.Context, client Client, kid string, grantTokens []string, usage types.KeyUsageType) (*kms.GetPublicKeyOutput, error) {
+	input := kms.GetPublicKeyInput{
+		KeyId:       aws.String(kid),
+		GrantTokens: grantTokens,
+	}
+	output, err := client.GetPublicKey(ctx, &input)
+	if err != nil {
+		return nil, fmt.Errorf(`failed to get public key from KMS: %w`, classifyError(err))
+	}
+
+	if output.KeyUsage != usage {
+		return nil, fmt.Errorf(`invalid key usage. expected %s, got %q: %w`, usage, output.KeyUsage, ErrWrongKeyUsage)
+	}
+	return output, nil
+}
@@ -0,0 +1,17 @@
+//go:build !go1.27
+
+package kmstest
+
+import (
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+	"github.com/cloudflare/circl/sign"
+)
+
+// signExternalMu requires crypto/mldsa (see externalmu_go127.go), as
+// circl can only sign the message itself.
+func signExternalMu(sign.PrivateKey, []byte) ([]byte, error) {
+	return nil, &types.UnsupportedOperationException{
+		Message: aws.String(`kmstest requires Go 1.27 or later to sign with the EXTERNAL_MU message type`),
+	}
+}
@@ -0,0 +1,39 @@
+//go:build go1.27
+
+package kmstest
+
+import (
+	"crypto"
+	"crypto/mldsa"
+	"fmt"
+
+	"github.com/cloudflare/circl/sign"
+)
+
+// signExternalMu signs mu using crypto/mldsa, as circl can only sign the
+// message itself. The key is converted using the seed that it was
+// generated from.
+func signExternalMu(priv sign.PrivateKey, mu []byte) ([]byte, error) {
+	var params mldsa.Parameters
+	switch name := priv.Scheme().Name(); name {
+	case `ML-DSA-44`:
+		params = mldsa.MLDSA44()
+	case `ML-DSA-65`:
+		params = mldsa.MLDSA65()
+	case `ML-DSA-87`:
+		params = mldsa.MLDSA87()
+	default:
+		return nil, fmt.Errorf(`unsupported ML-DSA scheme %s`, name)
+	}
+
+	seeded, ok := priv.(interface{ Seed() []byte })
+	if !ok || seeded.Seed() == nil {
+		return nil, fmt.Errorf(`the seed of the %s key is not available`, priv.Scheme().Name())
+	}
+
+	key, err := mldsa.NewPrivateKey(params, seeded.Seed())
+	if err != nil {
+		return nil, fmt.Errorf(`failed to convert %s key: %w`, priv.Scheme().Name(), err)
+	}
+	return key.Sign(nil, mu, crypto.MLDSAMu)
+}
@@ -0,0 +1,491 @@
+package kmstest
+
+import (
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	_ "crypto/sha1" // for RSAES_OAEP_SHA_1
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+	"github.com/cloudflare/circl/sign"
+	"github.com/cloudflare/circl/sign/mldsa/mldsa44"
+	"github.com/cloudflare/circl/sign/mldsa/mldsa65"
+	"github.com/cloudflare/circl/sign/mldsa/mldsa87"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	secp256k1ecdsa "github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+	"github.com/emmansun/gmsm/sm2"
+	"github.com/emmansun/gmsm/sm3"
+	"github.com/emmansun/gmsm/smx509"
+)
+
+var (
+	oidPublicKeyECDSA      = asn1.ObjectIdentifier{1, 2, 840, 10045, 2, 1}
+	oidNamedCurveSecp256k1 = asn1.ObjectIdentifier{1, 3, 132, 0, 10}
+)
+
+// keyMaterial holds the private key of a single KMS key, along with
+// its DER encoded SubjectPublicKeyInfo
+type keyMaterial struct {
+	spec  types.KeySpec
+	usage types.KeyUsageType
+	// one of *rsa.PrivateKey, *ecdsa.PrivateKey, *secp256k1.PrivateKey,
+	// ed25519.PrivateKey, *sm2.PrivateKey, sign.PrivateKey, or []byte
+	// for HMAC keys
+	priv interface{}
+	spki []byte
+}
+
+func generateKeyMaterial(spec types.KeySpec, usage types.KeyUsageType) (*keyMaterial, error) {
+	var supported bool
+	switch usage {
+	case types.KeyUsageTypeSignVerify:
+		supported = true
+	case types.KeyUsageTypeEncryptDecrypt:
+		supported = isRSA(spec)
+	case types.KeyUsageTypeKeyAgreement:
+		switch spec {
+		case types.KeySpecEccNistP256, types.KeySpecEccNistP384, types.KeySpecEccNistP521:
+			supported = true
+		}
+	case types.KeyUsageTypeGenerateVerifyMac:
+		supported = macAlgorithmHash(macAlgorithm(spec)) != crypto.Hash(0)
+	}
+	if !supported {
+		return nil, &types.UnsupportedOperationException{
+			Message: aws.String(fmt.Sprintf(`kmstest does not support key spec %s for key usage %s`, spec, usage)),
+		}
+	}
+
+	var priv interface{}
+	var spki []byte
+	var err error
+	switch spec {
+	case types.KeySpecRsa2048, types.KeySpecRsa3072, types.KeySpecRsa4096:
+		bits := map[types.KeySpec]int{
+			types.KeySpecRsa2048: 2048,
+			types.KeySpecRsa3072: 3072,
+			types.KeySpecRsa4096: 4096,
+		}[spec]
+		var key *rsa.PrivateKey
+		key, err = rsa.GenerateKey(rand.Reader, bits)
+		if err == nil {
+			priv = key
+			spki, err = x509.MarshalPKIXPublicKey(key.Public())
+		}
+	case types.KeySpecEccNistP256, types.KeySpecEccNistP384, types.KeySpecEccNistP521:
+		curve := map[types.KeySpec]elliptic.Curve{
+			types.KeySpecEccNistP256: elliptic.P256(),
+			types.KeySpecEccNistP384: elliptic.P384(),
+			types.KeySpecEccNistP521: elliptic.P521(),
+		}[spec]
+		var key *ecdsa.PrivateKey
+		key, err = ecdsa.GenerateKey(curve, rand.Reader)
+		if err == nil {
+			priv = key
+			spki, err = x509.MarshalPKIXPublicKey(key.Public())
+		}
+	case types.KeySpecEccSecgP256k1:
+		var key *secp256k1.PrivateKey
+		key, err = secp256k1.GeneratePrivateKey()
+		if err == nil {
+			priv = key
+			var params []byte
+			params, err = asn1.Marshal(oidNamedCurveSecp256k1)
+			if err == nil {
+				spki, err = marshalSPKI(pkix.AlgorithmIdentifier{
+					Algorithm:  oidPublicKeyECDSA,
+					Parameters: asn1.RawValue{FullBytes: params},
+				}, key.PubKey().SerializeUncompressed())
+			}
+		}
+	case types.KeySpecEccNistEdwards25519:
+		var key ed25519.PrivateKey
+		_, key, err = ed25519.GenerateKey(rand.Reader)
+		if err == nil {
+			priv = key
+			spki, err = x509.MarshalPKIXPublicKey(key.Public())
+		}
+	case types.KeySpecMlDsa44, types.KeySpecMlDsa65, types.KeySpecMlDsa87:
+		scheme := mldsaScheme(spec)
+		var pub sign.PublicKey
+		var key sign.PrivateKey
+		pub, key, err = scheme.GenerateKey()
+		if err == nil {
+			priv = key
+			var raw []byte
+			raw, err = pub.MarshalBinary()
+			if err == nil {
+				spki, err = marshalSPKI(pkix.AlgorithmIdentifier{
+					Algorithm: scheme.(interface{ Oid() asn1.ObjectIdentifier }).Oid(),
+				}, raw)
+			}
+		}
+	case types.KeySpecHmac224, types.KeySpecHmac256, types.KeySpecHmac384, types.KeySpecHmac512:
+		// the key is as long as the output of the hash function
+		key := make([]byte, macAlgorithmHash(macAlgorithm(spec)).Size())
+		_, err = rand.Read(key)
+		priv = key
+	case types.KeySpecSm2:
+		var key *sm2.PrivateKey
+		key, err = sm2.GenerateKey(rand.Reader)
+		if err == nil {
+			priv = key
+			spki, err = smx509.MarshalPKIXPublicKey(&key.PublicKey)
+		}
+	default:
+		return nil, &types.UnsupportedOperationException{
+			Message: aws.String(fmt.Sprintf(`kmstest does not support key spec %s for key usage %s`, spec, usage)),
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf(`failed to generate %s key: %w`, spec, err)
+	}
+
+	return &keyMaterial{
+		spec:  spec,
+		usage: usage,
+		priv:  priv,
+		spki:  spki,
+	}, nil
+}
+
+// signingAlgorithms returns the signing algorithms that KMS allows for
+// the key spec
+func (m *keyMaterial) signingAlgorithms() []types.SigningAlgorithmSpec {
+	if m.usage != types.KeyUsageTypeSignVerify {
+		return nil
+	}
+
+	switch m.spec {
+	case types.KeySpecRsa2048, types.KeySpecRsa3072, types.KeySpecRsa4096:
+		return []types.SigningAlgorithmSpec{
+			types.SigningAlgorithmSpecRsassaPssSha256,
+			types.SigningAlgorithmSpecRsassaPssSha384,
+			types.SigningAlgorithmSpecRsassaPssSha512,
+			types.SigningAlgorithmSpecRsassaPkcs1V15Sha256,
+			types.SigningAlgorithmSpecRsassaPkcs1V15Sha384,
+			types.SigningAlgorithmSpecRsassaPkcs1V15Sha512,
+		}
+	case types.KeySpecEccNistP256, types.KeySpecEccSecgP256k1:
+		return []types.SigningAlgorithmSpec{types.SigningAlgorithmSpecEcdsaSha256}
+	case types.KeySpecEccNistP384:
+		return []types.SigningAlgorithmSpec{types.SigningAlgorithmSpecEcdsaSha384}
+	case types.KeySpecEccNistP521:
+		return []types.SigningAlgorithmSpec{types.SigningAlgorithmSpecEcdsaSha512}
+	case types.KeySpecEccNistEdwards25519:
+		return []types.SigningAlgorithmSpec{
+			types.SigningAlgorithmSpecEd25519Sha512,
+			types.SigningAlgorithmSpecEd25519PhSha512,
+		}
+	case types.KeySpecMlDsa44, types.KeySpecMlDsa65, types.KeySpecMlDsa87:
+		return []types.SigningAlgorithmSpec{types.SigningAlgorithmSpecMlDsaShake256}
+	case types.KeySpecSm2:
+		return []types.SigningAlgorithmSpec{types.SigningAlgorithmSpecSm2dsa}
+	}
+	return nil
+}
+
+// encryptionAlgorithms returns the encryption algorithms that KMS allows
+// for the key spec
+func (m *keyMaterial) encryptionAlgorithms() []types.EncryptionAlgorithmSpec {
+	if m.usage != types.KeyUsageTypeEncryptDecrypt {
+		return nil
+	}
+	return []types.EncryptionAlgorithmSpec{
+		types.EncryptionAlgorithmSpecRsaesOaepSha1,
+		types.EncryptionAlgorithmSpecRsaesOaepSha256,
+	}
+}
+
+// keyAgreementAlgorithms returns the key agreement algorithms that KMS
+// allows for the key spec
+func (m *keyMaterial) keyAgreementAlgorithms() []types.KeyAgreementAlgorithmSpec {
+	if m.usage != types.KeyUsageTypeKeyAgreement {
+		return nil
+	}
+	return []types.KeyAgreementAlgorithmSpec{types.KeyAgreementAlgorithmSpecEcdh}
+}
+
+// macAlgorithms returns the MAC algorithms that KMS allows for the
+// key spec
+func (m *keyMaterial) macAlgorithms() []types.MacAlgorithmSpec {
+	if m.usage != types.KeyUsageTypeGenerateVerifyMac {
+		return nil
+	}
+	return []types.MacAlgorithmSpec{macAlgorithm(m.spec)}
+}
+
+// prepare validates the message type and the length of the message, and
+// hashes RAW messages for the algorithms that sign a digest.
+func (m *keyMaterial) prepare(message []byte, mt types.MessageType, alg types.SigningAlgorithmSpec) ([]byte, types.MessageType, error) {
+	if mt == "" {
+		mt = types.MessageTypeRaw
+	}
+	if len(message) == 0 {
+		return nil, "", validationError(`1 validation error detected: Value at 'message' failed to satisfy constraint: Member must have length greater than or equal to 1`)
+	}
+
+	switch mt {
+	case types.MessageTypeRaw:
+		if len(message) > maxRawMessageSize {
+			return nil, "", validationError(fmt.Sprintf(`1 validation error detected: Value at 'message' failed to satisfy constraint: Member must have length less than or equal to %d`, maxRawMessageSize))
+		}
+	case types.MessageTypeDigest:
+	case types.MessageTypeExternalMu:
+		if alg != types.SigningAlgorithmSpecMlDsaShake256 {
+			return nil, "", validationError(fmt.Sprintf(`EXTERNAL_MU message type is not valid for signing algorithm %s`, alg))
+		}
+		if len(message) != mldsaMuSize {
+			return nil, "", validationError(fmt.Sprintf(`EXTERNAL_MU message must be %d bytes long`, mldsaMuSize))
+		}
+		return message, mt, nil
+	default:
+		return nil, "", validationError(fmt.Sprintf(`invalid message type %s`, mt))
+	}
+
+	switch alg {
+	case types.SigningAlgorithmSpecEd25519Sha512, types.SigningAlgorithmSpecMlDsaShake256:
+		// these sign the message itself (or, for ML-DSA, mu)
+		if mt == types.MessageTypeDigest {
+			return nil, "", validationError(fmt.Sprintf(`%s message type is not valid for signing algorithm %s`, mt, alg))
+		}
+		return message, mt, nil
+	case types.SigningAlgorithmSpecSm2dsa:
+		// SM2 digests include the public key, which is handled when signing
+		if mt == types.MessageTypeDigest && len(message) != sm3.Size {
+			return nil, "", digestLengthError(alg, sm3.Size)
+		}
+		return message, mt, nil
+	}
+
+	hash := signingAlgorithmHash(alg)
+	if mt == types.MessageTypeRaw {
+		h := hash.New()
+		h.Write(message)
+		return h.Sum(nil), types.MessageTypeDigest, nil
+	}
+	if len(message) != hash.Size() {
+		return nil, "", digestLengthError(alg, hash.Size())
+	}
+	return message, mt, nil
+}
+
+func (m *keyMaterial) sign(message []byte, mt types.MessageType, alg types.SigningAlgorithmSpec) ([]byte, error) {
+	message, mt, err := m.prepare(message, mt, alg)
+	if err != nil {
+		return nil, err
+	}
+
+	switch priv := m.priv.(type) {
+	case *rsa.PrivateKey:
+		hash := signingAlgorithmHash(alg)
+		if isPSS(alg) {
+			return rsa.SignPSS(rand.Reader, priv, hash, message, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		}
+		return rsa.SignPKCS1v15(rand.Reader, priv, hash, message)
+	case *ecdsa.PrivateKey:
+		return ecdsa.SignASN1(rand.Reader, priv, message)
+	case *secp256k1.PrivateKey:
+		return secp256k1ecdsa.Sign(priv, message).Serialize(), nil
+	case ed25519.PrivateKey:
+		if alg == types.SigningAlgorithmSpecEd25519PhSha512 {
+			return priv.Sign(rand.Reader, message, &ed25519.Options{Hash: crypto.SHA512})
+		}
+		return ed25519.Sign(priv, message), nil
+	case *sm2.PrivateKey:
+		var opts crypto.SignerOpts
+		if mt == types.MessageTypeRaw {
+			// KMS computes the digest using the default ID
+			opts = sm2.DefaultSM2SignerOpts
+		}
+		return sm2.SignASN1(rand.Reader, priv, message, opts)
+	case sign.PrivateKey:
+		if mt == types.MessageTypeExternalMu {
+			return signExternalMu(priv, message)
+		}
+		return priv.Scheme().Sign(priv, message, nil), nil
+	}
+	return nil, fmt.Errorf(`unsupported private key type %T`, m.priv)
+}
+
+func (m *keyMaterial) verify(message []byte, mt types.MessageType, alg types.SigningAlgorithmSpec, signature []byte) error {
+	message, mt, err := m.prepare(message, mt, alg)
+	if err != nil {
+		return err
+	}
+	if mt == types.MessageTypeExternalMu {
+		return &types.UnsupportedOperationException{
+			Message: aws.String(`kmstest does not support verifying with the EXTERNAL_MU message type`),
+		}
+	}
+
+	var ok bool
+	switch priv := m.priv.(type) {
+	case *rsa.PrivateKey:
+		hash := signingAlgorithmHash(alg)
+		if isPSS(alg) {
+			ok = rsa.VerifyPSS(&priv.PublicKey, hash, message, signature, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash}) == nil
+		} else {
+			ok = rsa.VerifyPKCS1v15(&priv.PublicKey, hash, message, signature) == nil
+		}
+	case *ecdsa.PrivateKey:
+		ok = ecdsa.VerifyASN1(&priv.PublicKey, message, signature)
+	case *secp256k1.PrivateKey:
+		sig, err := secp256k1ecdsa.ParseDERSignature(signature)
+		ok = err == nil && sig.Verify(message, priv.PubKey())
+	case ed25519.PrivateKey:
+		var opts ed25519.Options
+		if alg == types.SigningAlgorithmSpecEd25519PhSha512 {
+			opts.Hash = crypto.SHA512
+		}
+		ok = ed25519.VerifyWithOptions(priv.Public().(ed25519.PublicKey), message, signature, &opts) == nil
+	case *sm2.PrivateKey:
+		if mt == types.MessageTypeRaw {
+			ok = sm2.VerifyASN1WithSM2(&priv.PublicKey, nil, message, signature)
+		} else {
+			ok = sm2.VerifyASN1(&priv.PublicKey, message, signature)
+		}
+	case sign.PrivateKey:
+		ok = priv.Scheme().Verify(priv.Public().(sign.PublicKey), message, signature, nil)
+	}
+	if !ok {
+		return &types.KMSInvalidSignatureException{}
+	}
+	return nil
+}
+
+func (m *keyMaterial) encrypt(plaintext []byte, alg types.EncryptionAlgorithmSpec) ([]byte, error) {
+	priv := m.priv.(*rsa.PrivateKey)
+	ciphertext, err := rsa.EncryptOAEP(encryptionAlgorithmHash(alg).New(), rand.Reader, &priv.PublicKey, plaintext, nil)
+	if err != nil {
+		return nil, validationError(fmt.Sprintf(`failed to encrypt using %s: %s`, alg, err))
+	}
+	return ciphertext, nil
+}
+
+func (m *keyMaterial) decrypt(ciphertext []byte, alg types.EncryptionAlgorithmSpec) ([]byte, error) {
+	priv := m.priv.(*rsa.PrivateKey)
+	plaintext, err := rsa.DecryptOAEP(encryptionAlgorithmHash(alg).New(), rand.Reader, priv, ciphertext, nil)
+	if err != nil {
+		return nil, &types.InvalidCiphertextException{}
+	}
+	return plaintext, nil
+}
+
+func (m *keyMaterial) deriveSharedSecret(publicKey []byte) ([]byte, error) {
+	priv, err := m.priv.(*ecdsa.PrivateKey).ECDH()
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := x509.ParsePKIXPublicKey(publicKey)
+	if err != nil {
+		return nil, validationError(fmt.Sprintf(`failed to parse public key: %s`, err))
+	}
+	var remote *ecdh.PublicKey
+	if key, ok := parsed.(*ecdsa.PublicKey); ok {
+		remote, err = key.ECDH()
+	}
+	if remote == nil || err != nil || remote.Curve() != priv.Curve() {
+		return nil, validationError(fmt.Sprintf(`public key must be on the same curve as the %s key`, m.spec))
+	}
+	return priv.ECDH(remote)
+}
+
+func (m *keyMaterial) generateMac(message []byte, alg types.MacAlgorithmSpec) ([]byte, error) {
+	if len(message) == 0 || len(message) > maxRawMessageSize {
+		return nil, validationError(fmt.Sprintf(`1 validation error detected: Value at 'message' failed to satisfy constraint: Member must have length between 1 and %d`, maxRawMessageSize))
+	}
+	h := hmac.New(macAlgorithmHash(alg).New, m.priv.([]byte))
+	h.Write(message)
+	return h.Sum(nil), nil
+}
+
+func mldsaScheme(spec types.KeySpec) sign.Scheme {
+	switch spec {
+	case types.KeySpecMlDsa44:
+		return mldsa44.Scheme()
+	case types.KeySpecMlDsa65:
+		return mldsa65.Scheme()
+	default:
+		return mldsa87.Scheme()
+	}
+}
+
+func signingAlgorithmHash(alg types.SigningAlgorithmSpec) crypto.Hash {
+	switch alg {
+	case types.SigningAlgorithmSpecRsassaPssSha256, types.SigningAlgorithmSpecRsassaPkcs1V15Sha256, types.SigningAlgorithmSpecEcdsaSha256:
+		return crypto.SHA256
+	case types.SigningAlgorithmSpecRsassaPssSha384, types.SigningAlgorithmSpecRsassaPkcs1V15Sha384, types.SigningAlgorithmSpecEcdsaSha384:
+		return crypto.SHA384
+	default:
+		return crypto.SHA512
+	}
+}
+
+func encryptionAlgorithmHash(alg types.EncryptionAlgorithmSpec) crypto.Hash {
+	if alg == types.EncryptionAlgorithmSpecRsaesOaepSha1 {
+		return crypto.SHA1
+	}
+	return crypto.SHA256
+}
+
+// macAlgorithm returns the MAC algorithm for the HMAC key spec
+func macAlgorithm(spec types.KeySpec) types.MacAlgorithmSpec {
+	return types.MacAlgorithmSpec(strings.Replace(string(spec), `HMAC_`, `HMAC_SHA_`, 1))
+}
+
+func macAlgorithmHash(alg types.MacAlgorithmSpec) crypto.Hash {
+	switch alg {
+	case types.MacAlgorithmSpecHmacSha224:
+		return crypto.SHA224
+	case types.MacAlgorithmSpecHmacSha256:
+		return crypto.SHA256
+	case types.MacAlgorithmSpecHmacSha384:
+		return crypto.SHA384
+	case types.MacAlgorithmSpecHmacSha512:
+		return crypto.SHA512
+	}
+	return crypto.Hash(0)
+}
+
+func isRSA(spec types.KeySpec) bool {
+	switch spec {
+	case types.KeySpecRsa2048, types.KeySpecRsa3072, types.KeySpecRsa4096:
+		return true
+	}
+	return false
+}
+
+func isPSS(alg types.SigningAlgorithmSpec) bool {
+	switch alg {
+	case types.SigningAlgorithmSpecRsassaPssSha256, types.SigningAlgorithmSpecRsassaPssSha384, types.SigningAlgorithmSpecRsassaPssSha512:
+		return true
+	}
+	return false
+}
+
+func digestLengthError(alg types.SigningAlgorithmSpec, size int) error {
+	return validationError(fmt.Sprintf(`Digest is invalid length for algorithm %s. Expected %d bytes.`, alg, size))
+}
+
+func marshalSPKI(alg pkix.AlgorithmIdentifier, key []byte) ([]byte, error) {
+	return asn1.Marshal(struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}{
+		Algorithm: alg,
+		PublicKey: asn1.BitString{Bytes: key, BitLength: 8 * len(key)},
+	})
+}
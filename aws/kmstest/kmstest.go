@@ -0,0 +1,754 @@
+// Package kmstest provides an in-memory stand-in for AWS KMS, which can be
+// used to test code that depends on awssigner without talking to AWS.
+//
+// Keys are created using CreateKey just like with the real service, and
+// real key material is generated for each key spec, so signatures created
+// by the fake can be verified using the public key returned by
+// GetPublicKey. Key usage and key state (enabled, disabled, pending
+// deletion) are enforced, and errors are reported using the same types
+// that the AWS SDK returns (e.g. *types.NotFoundException), so that error
+// handling code can be tested as well.
+//
+// Requests with DryRun set to true are validated as usual, and fail with
+// *types.DryRunOperationException if they would have succeeded. Grant
+// tokens are accepted, but are ignored. Aliases created using CreateAlias
+// can be used in place of key IDs, and can be re-pointed using UpdateAlias.
+//
+//	client := kmstest.New()
+//	key, err := client.CreateKey(ctx, &kms.CreateKeyInput{
+//	  KeySpec:  types.KeySpecEccNistP256,
+//	  KeyUsage: types.KeyUsageTypeSignVerify,
+//	})
+//	...
+//	signer := awssigner.NewECDSA(client).
+//	  WithKeyID(*key.KeyMetadata.KeyId)
+package kmstest
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+	"github.com/aws/smithy-go"
+)
+
+const (
+	// DefaultRegion is the region that appears in the ARNs of the keys
+	// created by the fake
+	DefaultRegion = `us-east-1`
+
+	// DefaultAccountID is the AWS account ID that appears in the ARNs of
+	// the keys created by the fake
+	DefaultAccountID = `111122223333`
+)
+
+// maxRawMessageSize is the largest message that AWS KMS accepts
+// when MessageType is RAW.
+const maxRawMessageSize = 4096
+
+// mldsaMuSize is the size of the ML-DSA message representative (mu),
+// which is signed when MessageType is EXTERNAL_MU.
+const mldsaMuSize = 64
+
+// KMS is an in-memory fake of the AWS KMS API. It implements the same
+// methods as *kms.Client for the operations that it supports, and is
+// safe for concurrent use.
+//
+// The zero value is not usable; use New() to create one.
+type KMS struct {
+	mu      sync.RWMutex
+	region  string
+	account string
+	keys    map[string]*key
+	aliases map[string]string // alias name -> key ID
+}
+
+// New creates a new, empty fake KMS in DefaultRegion.
+func New() *KMS {
+	return NewInRegion(DefaultRegion)
+}
+
+// NewInRegion creates a new, empty fake KMS in the given region. Use
+// one fake per region, along with Replicate(), to test code that uses
+// multi-Region keys.
+func NewInRegion(region string) *KMS {
+	return &KMS{
+		region:  region,
+		account: DefaultAccountID,
+		keys:    make(map[string]*key),
+		aliases: make(map[string]string),
+	}
+}
+
+// Region returns the region that the fake is in.
+func (k *KMS) Region() string {
+	return k.region
+}
+
+// CreateKey creates a new key with freshly generated key material. Only
+// the KeySpec, KeyUsage, Description, and MultiRegion fields of the input
+// are used.
+func (k *KMS) CreateKey(_ context.Context, in *kms.CreateKeyInput, _ ...func(*kms.Options)) (*kms.CreateKeyOutput, error) {
+	spec := in.KeySpec
+	if spec == "" {
+		spec = types.KeySpecSymmetricDefault
+	}
+	usage := in.KeyUsage
+	if usage == "" {
+		usage = types.KeyUsageTypeEncryptDecrypt
+	}
+
+	material, err := generateKeyMaterial(spec, usage)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := newKeyID()
+	if err != nil {
+		return nil, fmt.Errorf(`failed to generate key ID: %w`, err)
+	}
+
+	multiRegion := aws.ToBool(in.MultiRegion)
+	if multiRegion {
+		// multi-Region key IDs are prefixed with "mrk-", and are
+		// the same in all regions
+		id = `mrk-` + strings.ReplaceAll(id, `-`, ``)
+	}
+
+	now := time.Now()
+	arn := fmt.Sprintf(`arn:aws:kms:%s:%s:key/%s`, k.region, k.account, id)
+	metadata := types.KeyMetadata{
+		KeyId:                  aws.String(id),
+		Arn:                    aws.String(arn),
+		AWSAccountId:           aws.String(k.account),
+		CreationDate:           &now,
+		Description:            in.Description,
+		Enabled:                true,
+		KeyManager:             types.KeyManagerTypeCustomer,
+		KeySpec:                spec,
+		CustomerMasterKeySpec:  types.CustomerMasterKeySpec(spec),
+		KeyState:               types.KeyStateEnabled,
+		KeyUsage:               usage,
+		MultiRegion:            aws.Bool(multiRegion),
+		Origin:                 types.OriginTypeAwsKms,
+		SigningAlgorithms:      material.signingAlgorithms(),
+		EncryptionAlgorithms:   material.encryptionAlgorithms(),
+		KeyAgreementAlgorithms: material.keyAgreementAlgorithms(),
+		MacAlgorithms:          material.macAlgorithms(),
+	}
+	if multiRegion {
+		metadata.MultiRegionConfiguration = &types.MultiRegionConfiguration{
+			MultiRegionKeyType: types.MultiRegionKeyTypePrimary,
+			PrimaryKey: &types.MultiRegionKey{
+				Arn:    aws.String(arn),
+				Region: aws.String(k.region),
+			},
+		}
+	}
+
+	k.mu.Lock()
+	k.keys[id] = &key{metadata: metadata, material: material}
+	k.mu.Unlock()
+
+	return &kms.CreateKeyOutput{KeyMetadata: cloneMetadata(&metadata)}, nil
+}
+
+// Replicate creates a replica of the given multi-Region key in another
+// fake, which stands for another region. The replica shares the key
+// material with the original key, but its state is independent.
+//
+// This is not part of the KMS API: the real ReplicateKey operation is
+// called in the region of the primary key, which the fake cannot reach.
+func (k *KMS) Replicate(keyID string, replica *KMS) (*types.KeyMetadata, error) {
+	k.mu.RLock()
+	original, err := k.lookup(&keyID)
+	if err != nil {
+		k.mu.RUnlock()
+		return nil, err
+	}
+	metadata := *cloneMetadata(&original.metadata)
+	material := original.material
+	k.mu.RUnlock()
+
+	if !aws.ToBool(metadata.MultiRegion) {
+		return nil, &types.UnsupportedOperationException{
+			Message: aws.String(fmt.Sprintf(`%s is not a multi-Region key`, aws.ToString(metadata.Arn))),
+		}
+	}
+	if replica.region == k.region {
+		return nil, validationError(fmt.Sprintf(`the replica must be in a different region than %s`, k.region))
+	}
+
+	id := aws.ToString(metadata.KeyId)
+	primaryArn := metadata.Arn
+	arn := fmt.Sprintf(`arn:aws:kms:%s:%s:key/%s`, replica.region, replica.account, id)
+	now := time.Now()
+	metadata.Arn = aws.String(arn)
+	metadata.AWSAccountId = aws.String(replica.account)
+	metadata.CreationDate = &now
+	metadata.KeyState = types.KeyStateEnabled
+	metadata.Enabled = true
+	metadata.DeletionDate = nil
+	metadata.MultiRegionConfiguration = &types.MultiRegionConfiguration{
+		MultiRegionKeyType: types.MultiRegionKeyTypeReplica,
+		PrimaryKey: &types.MultiRegionKey{
+			Arn:    primaryArn,
+			Region: aws.String(k.region),
+		},
+	}
+
+	replica.mu.Lock()
+	defer replica.mu.Unlock()
+	if _, ok := replica.keys[id]; ok {
+		return nil, &types.AlreadyExistsException{
+			Message: aws.String(fmt.Sprintf(`%s already exists`, arn)),
+		}
+	}
+	replica.keys[id] = &key{metadata: metadata, material: material}
+	return cloneMetadata(&metadata), nil
+}
+
+// CreateAlias creates an alias that points to the given key. Aliases can
+// be used in place of the key ID in all operations.
+func (k *KMS) CreateAlias(_ context.Context, in *kms.CreateAliasInput, _ ...func(*kms.Options)) (*kms.CreateAliasOutput, error) {
+	name := aws.ToString(in.AliasName)
+	if err := validateAliasName(name); err != nil {
+		return nil, err
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if _, ok := k.aliases[name]; ok {
+		return nil, &types.AlreadyExistsException{
+			Message: aws.String(fmt.Sprintf(`An alias with the name %s already exists`, k.aliasARN(name))),
+		}
+	}
+	key, err := k.lookup(in.TargetKeyId)
+	if err != nil {
+		return nil, err
+	}
+	k.aliases[name] = aws.ToString(key.metadata.KeyId)
+	return &kms.CreateAliasOutput{}, nil
+}
+
+// UpdateAlias changes the key that an existing alias points to.
+func (k *KMS) UpdateAlias(_ context.Context, in *kms.UpdateAliasInput, _ ...func(*kms.Options)) (*kms.UpdateAliasOutput, error) {
+	name := aws.ToString(in.AliasName)
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if _, ok := k.aliases[name]; !ok {
+		return nil, notFoundError(k.aliasARN(name))
+	}
+	key, err := k.lookup(in.TargetKeyId)
+	if err != nil {
+		return nil, err
+	}
+	k.aliases[name] = aws.ToString(key.metadata.KeyId)
+	return &kms.UpdateAliasOutput{}, nil
+}
+
+// DeleteAlias deletes an alias. The key that it points to is not affected.
+func (k *KMS) DeleteAlias(_ context.Context, in *kms.DeleteAliasInput, _ ...func(*kms.Options)) (*kms.DeleteAliasOutput, error) {
+	name := aws.ToString(in.AliasName)
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if _, ok := k.aliases[name]; !ok {
+		return nil, notFoundError(k.aliasARN(name))
+	}
+	delete(k.aliases, name)
+	return &kms.DeleteAliasOutput{}, nil
+}
+
+// DescribeKey returns the metadata of the given key.
+func (k *KMS) DescribeKey(_ context.Context, in *kms.DescribeKeyInput, _ ...func(*kms.Options)) (*kms.DescribeKeyOutput, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	key, err := k.lookup(in.KeyId)
+	if err != nil {
+		return nil, err
+	}
+	return &kms.DescribeKeyOutput{KeyMetadata: cloneMetadata(&key.metadata)}, nil
+}
+
+// EnableKey sets the state of the given key to Enabled.
+func (k *KMS) EnableKey(_ context.Context, in *kms.EnableKeyInput, _ ...func(*kms.Options)) (*kms.EnableKeyOutput, error) {
+	return &kms.EnableKeyOutput{}, k.setState(in.KeyId, types.KeyStateEnabled)
+}
+
+// DisableKey sets the state of the given key to Disabled. Disabled keys
+// cannot be used for cryptographic operations.
+func (k *KMS) DisableKey(_ context.Context, in *kms.DisableKeyInput, _ ...func(*kms.Options)) (*kms.DisableKeyOutput, error) {
+	return &kms.DisableKeyOutput{}, k.setState(in.KeyId, types.KeyStateDisabled)
+}
+
+// ScheduleKeyDeletion sets the state of the given key to PendingDeletion.
+// The key is never actually deleted, but it cannot be used for
+// cryptographic operations until CancelKeyDeletion is called.
+func (k *KMS) ScheduleKeyDeletion(_ context.Context, in *kms.ScheduleKeyDeletionInput, _ ...func(*kms.Options)) (*kms.ScheduleKeyDeletionOutput, error) {
+	days := aws.ToInt32(in.PendingWindowInDays)
+	if days == 0 {
+		days = 30
+	}
+	if days < 7 || days > 30 {
+		return nil, validationError(fmt.Sprintf(`PendingWindowInDays must be between 7 and 30, got %d`, days))
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	key, err := k.lookup(in.KeyId)
+	if err != nil {
+		return nil, err
+	}
+	if key.metadata.KeyState == types.KeyStatePendingDeletion {
+		return nil, invalidStateError(&key.metadata)
+	}
+
+	deletionDate := time.Now().AddDate(0, 0, int(days))
+	key.metadata.KeyState = types.KeyStatePendingDeletion
+	key.metadata.Enabled = false
+	key.metadata.DeletionDate = &deletionDate
+	return &kms.ScheduleKeyDeletionOutput{
+		KeyId:               key.metadata.Arn,
+		DeletionDate:        &deletionDate,
+		KeyState:            types.KeyStatePendingDeletion,
+		PendingWindowInDays: aws.Int32(days),
+	}, nil
+}
+
+// CancelKeyDeletion cancels a previous ScheduleKeyDeletion. Just like with
+// the real service, the key is left in the Disabled state.
+func (k *KMS) CancelKeyDeletion(_ context.Context, in *kms.CancelKeyDeletionInput, _ ...func(*kms.Options)) (*kms.CancelKeyDeletionOutput, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	key, err := k.lookup(in.KeyId)
+	if err != nil {
+		return nil, err
+	}
+	if key.metadata.KeyState != types.KeyStatePendingDeletion {
+		return nil, invalidStateError(&key.metadata)
+	}
+	key.metadata.KeyState = types.KeyStateDisabled
+	key.metadata.DeletionDate = nil
+	return &kms.CancelKeyDeletionOutput{KeyId: key.metadata.Arn}, nil
+}
+
+// GetPublicKey returns the DER encoded SubjectPublicKeyInfo of the given
+// asymmetric key. Unlike the other operations, this also works for
+// disabled keys, as is the case with the real service.
+func (k *KMS) GetPublicKey(_ context.Context, in *kms.GetPublicKeyInput, _ ...func(*kms.Options)) (*kms.GetPublicKeyOutput, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	key, err := k.lookup(in.KeyId)
+	if err != nil {
+		return nil, err
+	}
+	if key.metadata.KeyState == types.KeyStatePendingDeletion {
+		return nil, invalidStateError(&key.metadata)
+	}
+	if key.material.spki == nil {
+		return nil, &types.UnsupportedOperationException{
+			Message: aws.String(fmt.Sprintf(`%s key type is not supported for this operation.`, key.metadata.KeySpec)),
+		}
+	}
+
+	return &kms.GetPublicKeyOutput{
+		KeyId:                  key.metadata.Arn,
+		KeySpec:                key.metadata.KeySpec,
+		CustomerMasterKeySpec:  key.metadata.CustomerMasterKeySpec,
+		KeyUsage:               key.metadata.KeyUsage,
+		PublicKey:              append([]byte(nil), key.material.spki...),
+		SigningAlgorithms:      append([]types.SigningAlgorithmSpec(nil), key.metadata.SigningAlgorithms...),
+		EncryptionAlgorithms:   append([]types.EncryptionAlgorithmSpec(nil), key.metadata.EncryptionAlgorithms...),
+		KeyAgreementAlgorithms: append([]types.KeyAgreementAlgorithmSpec(nil), key.metadata.KeyAgreementAlgorithms...),
+	}, nil
+}
+
+// Sign signs the given message or digest using the given key.
+//
+// The EXTERNAL_MU message type for ML-DSA keys requires crypto/mldsa, so
+// it is only supported when built with Go 1.27 or later. Otherwise, it is
+// rejected with *types.UnsupportedOperationException.
+func (k *KMS) Sign(_ context.Context, in *kms.SignInput, _ ...func(*kms.Options)) (*kms.SignOutput, error) {
+	key, err := k.usableKey(in.KeyId, types.KeyUsageTypeSignVerify, `Sign`)
+	if err != nil {
+		return nil, err
+	}
+	if err := key.checkSigningAlgorithm(in.SigningAlgorithm); err != nil {
+		return nil, err
+	}
+	if aws.ToBool(in.DryRun) {
+		return nil, dryRunError()
+	}
+
+	signature, err := key.material.sign(in.Message, in.MessageType, in.SigningAlgorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	return &kms.SignOutput{
+		KeyId:            key.metadata.Arn,
+		Signature:        signature,
+		SigningAlgorithm: in.SigningAlgorithm,
+	}, nil
+}
+
+// Verify verifies the given signature. Just like the real service, an
+// invalid signature is reported using *types.KMSInvalidSignatureException.
+//
+// The fake does not support the EXTERNAL_MU message type for ML-DSA keys.
+func (k *KMS) Verify(_ context.Context, in *kms.VerifyInput, _ ...func(*kms.Options)) (*kms.VerifyOutput, error) {
+	key, err := k.usableKey(in.KeyId, types.KeyUsageTypeSignVerify, `Verify`)
+	if err != nil {
+		return nil, err
+	}
+	if err := key.checkSigningAlgorithm(in.SigningAlgorithm); err != nil {
+		return nil, err
+	}
+	if aws.ToBool(in.DryRun) {
+		return nil, dryRunError()
+	}
+
+	if err := key.material.verify(in.Message, in.MessageType, in.SigningAlgorithm, in.Signature); err != nil {
+		return nil, err
+	}
+
+	return &kms.VerifyOutput{
+		KeyId:            key.metadata.Arn,
+		SignatureValid:   true,
+		SigningAlgorithm: in.SigningAlgorithm,
+	}, nil
+}
+
+// Encrypt encrypts the given plaintext using the public key of the given
+// asymmetric key. Only RSA keys with the ENCRYPT_DECRYPT key usage are
+// supported.
+func (k *KMS) Encrypt(_ context.Context, in *kms.EncryptInput, _ ...func(*kms.Options)) (*kms.EncryptOutput, error) {
+	key, err := k.usableKey(in.KeyId, types.KeyUsageTypeEncryptDecrypt, `Encrypt`)
+	if err != nil {
+		return nil, err
+	}
+	if err := key.checkEncryptionAlgorithm(in.EncryptionAlgorithm); err != nil {
+		return nil, err
+	}
+	if aws.ToBool(in.DryRun) {
+		return nil, dryRunError()
+	}
+
+	ciphertext, err := key.material.encrypt(in.Plaintext, in.EncryptionAlgorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	return &kms.EncryptOutput{
+		KeyId:               key.metadata.Arn,
+		CiphertextBlob:      ciphertext,
+		EncryptionAlgorithm: in.EncryptionAlgorithm,
+	}, nil
+}
+
+// Decrypt decrypts the given ciphertext. Just like the real service, the
+// key ID must be specified for asymmetric keys, and a ciphertext that
+// cannot be decrypted is reported using *types.InvalidCiphertextException.
+func (k *KMS) Decrypt(_ context.Context, in *kms.DecryptInput, _ ...func(*kms.Options)) (*kms.DecryptOutput, error) {
+	key, err := k.usableKey(in.KeyId, types.KeyUsageTypeEncryptDecrypt, `Decrypt`)
+	if err != nil {
+		return nil, err
+	}
+	if err := key.checkEncryptionAlgorithm(in.EncryptionAlgorithm); err != nil {
+		return nil, err
+	}
+	if aws.ToBool(in.DryRun) {
+		return nil, dryRunError()
+	}
+
+	plaintext, err := key.material.decrypt(in.CiphertextBlob, in.EncryptionAlgorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	return &kms.DecryptOutput{
+		KeyId:               key.metadata.Arn,
+		Plaintext:           plaintext,
+		EncryptionAlgorithm: in.EncryptionAlgorithm,
+	}, nil
+}
+
+// DeriveSharedSecret performs ECDH key agreement between the given key and
+// the given DER encoded public key, and returns the raw shared secret.
+func (k *KMS) DeriveSharedSecret(_ context.Context, in *kms.DeriveSharedSecretInput, _ ...func(*kms.Options)) (*kms.DeriveSharedSecretOutput, error) {
+	key, err := k.usableKey(in.KeyId, types.KeyUsageTypeKeyAgreement, `DeriveSharedSecret`)
+	if err != nil {
+		return nil, err
+	}
+	if in.KeyAgreementAlgorithm != types.KeyAgreementAlgorithmSpecEcdh {
+		return nil, &types.InvalidKeyUsageException{
+			Message: aws.String(fmt.Sprintf(`%s is not a supported key agreement algorithm for %s key %s.`, in.KeyAgreementAlgorithm, key.metadata.KeySpec, aws.ToString(key.metadata.Arn))),
+		}
+	}
+
+	if aws.ToBool(in.DryRun) {
+		return nil, dryRunError()
+	}
+
+	secret, err := key.material.deriveSharedSecret(in.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &kms.DeriveSharedSecretOutput{
+		KeyId:                 key.metadata.Arn,
+		KeyAgreementAlgorithm: in.KeyAgreementAlgorithm,
+		KeyOrigin:             key.metadata.Origin,
+		SharedSecret:          secret,
+	}, nil
+}
+
+// GenerateMac computes the HMAC of the given message using the given key.
+func (k *KMS) GenerateMac(_ context.Context, in *kms.GenerateMacInput, _ ...func(*kms.Options)) (*kms.GenerateMacOutput, error) {
+	key, err := k.usableKey(in.KeyId, types.KeyUsageTypeGenerateVerifyMac, `GenerateMac`)
+	if err != nil {
+		return nil, err
+	}
+	if err := key.checkMacAlgorithm(in.MacAlgorithm); err != nil {
+		return nil, err
+	}
+	if aws.ToBool(in.DryRun) {
+		return nil, dryRunError()
+	}
+
+	mac, err := key.material.generateMac(in.Message, in.MacAlgorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	return &kms.GenerateMacOutput{
+		KeyId:        key.metadata.Arn,
+		Mac:          mac,
+		MacAlgorithm: in.MacAlgorithm,
+	}, nil
+}
+
+// VerifyMac verifies the given HMAC. Just like the real service, an
+// invalid MAC is reported using *types.KMSInvalidMacException.
+func (k *KMS) VerifyMac(_ context.Context, in *kms.VerifyMacInput, _ ...func(*kms.Options)) (*kms.VerifyMacOutput, error) {
+	key, err := k.usableKey(in.KeyId, types.KeyUsageTypeGenerateVerifyMac, `VerifyMac`)
+	if err != nil {
+		return nil, err
+	}
+	if err := key.checkMacAlgorithm(in.MacAlgorithm); err != nil {
+		return nil, err
+	}
+	if aws.ToBool(in.DryRun) {
+		return nil, dryRunError()
+	}
+
+	mac, err := key.material.generateMac(in.Message, in.MacAlgorithm)
+	if err != nil {
+		return nil, err
+	}
+	if !hmac.Equal(mac, in.Mac) {
+		return nil, &types.KMSInvalidMacException{}
+	}
+
+	return &kms.VerifyMacOutput{
+		KeyId:        key.metadata.Arn,
+		MacAlgorithm: in.MacAlgorithm,
+		MacValid:     true,
+	}, nil
+}
+
+// key is a single KMS key
+type key struct {
+	metadata types.KeyMetadata
+	material *keyMaterial
+}
+
+func (key *key) checkSigningAlgorithm(alg types.SigningAlgorithmSpec) error {
+	for _, allowed := range key.metadata.SigningAlgorithms {
+		if alg == allowed {
+			return nil
+		}
+	}
+	return &types.InvalidKeyUsageException{
+		Message: aws.String(fmt.Sprintf(`%s is not a supported signing algorithm for %s key %s.`, alg, key.metadata.KeySpec, aws.ToString(key.metadata.Arn))),
+	}
+}
+
+func (key *key) checkEncryptionAlgorithm(alg types.EncryptionAlgorithmSpec) error {
+	for _, allowed := range key.metadata.EncryptionAlgorithms {
+		if alg == allowed {
+			return nil
+		}
+	}
+	return &types.InvalidKeyUsageException{
+		Message: aws.String(fmt.Sprintf(`%s is not a supported encryption algorithm for %s key %s.`, alg, key.metadata.KeySpec, aws.ToString(key.metadata.Arn))),
+	}
+}
+
+func (key *key) checkMacAlgorithm(alg types.MacAlgorithmSpec) error {
+	for _, allowed := range key.metadata.MacAlgorithms {
+		if alg == allowed {
+			return nil
+		}
+	}
+	return &types.InvalidKeyUsageException{
+		Message: aws.String(fmt.Sprintf(`%s is not a supported MAC algorithm for %s key %s.`, alg, key.metadata.KeySpec, aws.ToString(key.metadata.Arn))),
+	}
+}
+
+// lookup finds the key with the given key ID or key ARN.
+// k.mu must be held by the caller.
+func (k *KMS) lookup(keyID *string) (*key, error) {
+	id := aws.ToString(keyID)
+	if id == "" {
+		return nil, validationError(`1 validation error detected: Value null at 'keyId' failed to satisfy constraint: Member must not be null`)
+	}
+
+	if arn := strings.TrimPrefix(id, fmt.Sprintf(`arn:aws:kms:%s:%s:key/`, k.region, k.account)); arn != id {
+		id = arn
+	} else if arn := strings.TrimPrefix(id, fmt.Sprintf(`arn:aws:kms:%s:%s:`, k.region, k.account)); arn != id && strings.HasPrefix(arn, `alias/`) {
+		id = arn
+	} else if strings.HasPrefix(id, `arn:`) {
+		return nil, notFoundError(id)
+	}
+
+	if strings.HasPrefix(id, `alias/`) {
+		target, ok := k.aliases[id]
+		if !ok {
+			return nil, notFoundError(k.aliasARN(id))
+		}
+		id = target
+	}
+
+	key, ok := k.keys[id]
+	if !ok {
+		return nil, notFoundError(aws.ToString(keyID))
+	}
+	return key, nil
+}
+
+// usableKey finds the given key, and makes sure that it can be used for
+// the given operation.
+func (k *KMS) usableKey(keyID *string, usage types.KeyUsageType, op string) (*key, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	key, err := k.lookup(keyID)
+	if err != nil {
+		return nil, err
+	}
+
+	switch key.metadata.KeyState {
+	case types.KeyStateEnabled:
+	case types.KeyStateDisabled:
+		return nil, &types.DisabledException{
+			Message: aws.String(fmt.Sprintf(`%s is disabled.`, aws.ToString(key.metadata.Arn))),
+		}
+	default:
+		return nil, invalidStateError(&key.metadata)
+	}
+
+	if key.metadata.KeyUsage != usage {
+		return nil, &types.InvalidKeyUsageException{
+			Message: aws.String(fmt.Sprintf(`%s key usage is %s which is not valid for %s.`, aws.ToString(key.metadata.Arn), key.metadata.KeyUsage, op)),
+		}
+	}
+	return key, nil
+}
+
+func (k *KMS) setState(keyID *string, state types.KeyState) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	key, err := k.lookup(keyID)
+	if err != nil {
+		return err
+	}
+	if key.metadata.KeyState == types.KeyStatePendingDeletion {
+		return invalidStateError(&key.metadata)
+	}
+	key.metadata.KeyState = state
+	key.metadata.Enabled = state == types.KeyStateEnabled
+	return nil
+}
+
+func cloneMetadata(metadata *types.KeyMetadata) *types.KeyMetadata {
+	clone := *metadata
+	clone.SigningAlgorithms = append([]types.SigningAlgorithmSpec(nil), metadata.SigningAlgorithms...)
+	clone.EncryptionAlgorithms = append([]types.EncryptionAlgorithmSpec(nil), metadata.EncryptionAlgorithms...)
+	clone.KeyAgreementAlgorithms = append([]types.KeyAgreementAlgorithmSpec(nil), metadata.KeyAgreementAlgorithms...)
+	clone.MacAlgorithms = append([]types.MacAlgorithmSpec(nil), metadata.MacAlgorithms...)
+	return &clone
+}
+
+func newKeyID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf(`%x-%x-%x-%x-%x`, b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
+
+// aliasARN returns the ARN of the given alias name
+func (k *KMS) aliasARN(name string) string {
+	return fmt.Sprintf(`arn:aws:kms:%s:%s:%s`, k.region, k.account, name)
+}
+
+func validateAliasName(name string) error {
+	if !strings.HasPrefix(name, `alias/`) || len(name) == len(`alias/`) {
+		return validationError(fmt.Sprintf(`Alias must start with the prefix "alias/", got %q`, name))
+	}
+	if strings.HasPrefix(name, `alias/aws/`) {
+		return validationError(`Alias must not begin with the reserved prefix "alias/aws/"`)
+	}
+	return nil
+}
+
+func notFoundError(keyID string) error {
+	return &types.NotFoundException{
+		Message: aws.String(fmt.Sprintf(`Key '%s' does not exist`, keyID)),
+	}
+}
+
+func invalidStateError(metadata *types.KeyMetadata) error {
+	return &types.KMSInvalidStateException{
+		Message: aws.String(fmt.Sprintf(`%s is %s.`, aws.ToString(metadata.Arn), metadata.KeyState)),
+	}
+}
+
+// dryRunError creates the error that KMS returns when a request with
+// DryRun set to true would have succeeded
+func dryRunError() error {
+	return &types.DryRunOperationException{
+		Message: aws.String(`The request would have succeeded, but the DryRun option is set.`),
+	}
+}
+
+// validationError creates an error similar to the ValidationException
+// that KMS returns when the request parameters are invalid. The AWS SDK
+// does not have a dedicated type for this error.
+func validationError(msg string) error {
+	return &smithy.GenericAPIError{
+		Code:    `ValidationException`,
+		Message: msg,
+		Fault:   smithy.FaultClient,
+	}
+}
@@ -0,0 +1,222 @@
+package kmstest_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+	"github.com/aws/smithy-go"
+	awssigner "github.com/jwx-go/crypto-signer/v2/aws"
+	"github.com/jwx-go/crypto-signer/v2/aws/kmstest"
+)
+
+var _ awssigner.Client = kmstest.New()
+
+func createKey(t *testing.T, client *kmstest.KMS, spec types.KeySpec) *types.KeyMetadata {
+	t.Helper()
+	output, err := client.CreateKey(context.Background(), &kms.CreateKeyInput{
+		KeySpec:  spec,
+		KeyUsage: types.KeyUsageTypeSignVerify,
+	})
+	if err != nil {
+		t.Fatalf("failed to create key: %s", err)
+	}
+	return output.KeyMetadata
+}
+
+func TestSignVerify(t *testing.T) {
+	ctx := context.Background()
+	client := kmstest.New()
+
+	specs := []types.KeySpec{
+		types.KeySpecRsa2048,
+		types.KeySpecEccNistP256,
+		types.KeySpecEccNistP384,
+		types.KeySpecEccNistP521,
+		types.KeySpecEccSecgP256k1,
+		types.KeySpecEccNistEdwards25519,
+		types.KeySpecMlDsa44,
+		types.KeySpecMlDsa65,
+		types.KeySpecMlDsa87,
+		types.KeySpecSm2,
+	}
+	for _, spec := range specs {
+		t.Run(string(spec), func(t *testing.T) {
+			metadata := createKey(t, client, spec)
+
+			pubkey, err := client.GetPublicKey(ctx, &kms.GetPublicKeyInput{KeyId: metadata.Arn})
+			if err != nil {
+				t.Fatalf("failed to get public key: %s", err)
+			}
+			if pubkey.KeySpec != spec || len(pubkey.PublicKey) == 0 {
+				t.Fatalf("unexpected public key output")
+			}
+
+			for _, alg := range metadata.SigningAlgorithms {
+				message := []byte("obla-di-obla-da")
+				signed, err := client.Sign(ctx, &kms.SignInput{
+					KeyId:            metadata.KeyId,
+					Message:          message,
+					MessageType:      types.MessageTypeRaw,
+					SigningAlgorithm: alg,
+				})
+				if err != nil {
+					t.Fatalf("failed to sign using %s: %s", alg, err)
+				}
+
+				verified, err := client.Verify(ctx, &kms.VerifyInput{
+					KeyId:            metadata.KeyId,
+					Message:          message,
+					MessageType:      types.MessageTypeRaw,
+					Signature:        signed.Signature,
+					SigningAlgorithm: alg,
+				})
+				if err != nil || !verified.SignatureValid {
+					t.Fatalf("failed to verify using %s: %s", alg, err)
+				}
+
+				var invalid *types.KMSInvalidSignatureException
+				_, err = client.Verify(ctx, &kms.VerifyInput{
+					KeyId:            metadata.KeyId,
+					Message:          []byte("wrong payload"),
+					MessageType:      types.MessageTypeRaw,
+					Signature:        signed.Signature,
+					SigningAlgorithm: alg,
+				})
+				if !errors.As(err, &invalid) {
+					t.Fatalf("expected KMSInvalidSignatureException, got %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestExternalMu(t *testing.T) {
+	ctx := context.Background()
+	client := kmstest.New()
+
+	for _, spec := range []types.KeySpec{types.KeySpecMlDsa44, types.KeySpecMlDsa65, types.KeySpecMlDsa87} {
+		t.Run(string(spec), func(t *testing.T) {
+			metadata := createKey(t, client, spec)
+			pubkey, err := client.GetPublicKey(ctx, &kms.GetPublicKeyInput{KeyId: metadata.Arn})
+			if err != nil {
+				t.Fatalf("failed to get public key: %s", err)
+			}
+			key, err := awssigner.ParseMLDSAPublicKey(pubkey.PublicKey)
+			if err != nil {
+				t.Fatalf("failed to parse public key: %s", err)
+			}
+
+			// larger than what is accepted with RAW
+			message := make([]byte, 8192)
+			mu, err := awssigner.MLDSAExternalMu(key, message)
+			if err != nil {
+				t.Fatalf("failed to compute mu: %s", err)
+			}
+
+			sign := func(message []byte) (*kms.SignOutput, error) {
+				return client.Sign(ctx, &kms.SignInput{
+					KeyId:            metadata.KeyId,
+					Message:          message,
+					MessageType:      types.MessageTypeExternalMu,
+					SigningAlgorithm: types.SigningAlgorithmSpecMlDsaShake256,
+				})
+			}
+
+			var target smithy.APIError
+			if _, err := sign(message[:32]); !errors.As(err, &target) || target.ErrorCode() != `ValidationException` {
+				t.Fatalf("expected ValidationException, got %v", err)
+			}
+
+			signed, err := sign(mu)
+			var unsupported *types.UnsupportedOperationException
+			if errors.As(err, &unsupported) {
+				t.Skipf("EXTERNAL_MU is not supported: %s", err)
+			}
+			if err != nil {
+				t.Fatalf("failed to sign mu: %s", err)
+			}
+			if err := awssigner.VerifyMLDSA(key, message, signed.Signature); err != nil {
+				t.Fatalf("failed to verify: %s", err)
+			}
+		})
+	}
+}
+
+func TestErrors(t *testing.T) {
+	ctx := context.Background()
+	client := kmstest.New()
+	metadata := createKey(t, client, types.KeySpecEccNistP256)
+	digest := sha256.Sum256([]byte("obla-di-obla-da"))
+
+	sign := func(keyID *string, message []byte, alg types.SigningAlgorithmSpec) error {
+		_, err := client.Sign(ctx, &kms.SignInput{
+			KeyId:            keyID,
+			Message:          message,
+			MessageType:      types.MessageTypeDigest,
+			SigningAlgorithm: alg,
+		})
+		return err
+	}
+
+	t.Run("not found", func(t *testing.T) {
+		var target *types.NotFoundException
+		if err := sign(aws.String(`00000000-0000-4000-8000-000000000000`), digest[:], types.SigningAlgorithmSpecEcdsaSha256); !errors.As(err, &target) {
+			t.Fatalf("expected NotFoundException, got %v", err)
+		}
+	})
+	t.Run("wrong signing algorithm", func(t *testing.T) {
+		var target *types.InvalidKeyUsageException
+		if err := sign(metadata.KeyId, digest[:], types.SigningAlgorithmSpecEcdsaSha384); !errors.As(err, &target) {
+			t.Fatalf("expected InvalidKeyUsageException, got %v", err)
+		}
+	})
+	t.Run("wrong digest length", func(t *testing.T) {
+		var target smithy.APIError
+		if err := sign(metadata.KeyId, digest[:20], types.SigningAlgorithmSpecEcdsaSha256); !errors.As(err, &target) || target.ErrorCode() != `ValidationException` {
+			t.Fatalf("expected ValidationException, got %v", err)
+		}
+	})
+	t.Run("disabled", func(t *testing.T) {
+		if _, err := client.DisableKey(ctx, &kms.DisableKeyInput{KeyId: metadata.KeyId}); err != nil {
+			t.Fatalf("failed to disable key: %s", err)
+		}
+		var target *types.DisabledException
+		if err := sign(metadata.KeyId, digest[:], types.SigningAlgorithmSpecEcdsaSha256); !errors.As(err, &target) {
+			t.Fatalf("expected DisabledException, got %v", err)
+		}
+		if _, err := client.EnableKey(ctx, &kms.EnableKeyInput{KeyId: metadata.KeyId}); err != nil {
+			t.Fatalf("failed to enable key: %s", err)
+		}
+		if err := sign(metadata.KeyId, digest[:], types.SigningAlgorithmSpecEcdsaSha256); err != nil {
+			t.Fatalf("failed to sign: %s", err)
+		}
+	})
+	t.Run("pending deletion", func(t *testing.T) {
+		if _, err := client.ScheduleKeyDeletion(ctx, &kms.ScheduleKeyDeletionInput{KeyId: metadata.KeyId}); err != nil {
+			t.Fatalf("failed to schedule key deletion: %s", err)
+		}
+		var target *types.KMSInvalidStateException
+		if err := sign(metadata.KeyId, digest[:], types.SigningAlgorithmSpecEcdsaSha256); !errors.As(err, &target) {
+			t.Fatalf("expected KMSInvalidStateException, got %v", err)
+		}
+		if _, err := client.GetPublicKey(ctx, &kms.GetPublicKeyInput{KeyId: metadata.KeyId}); !errors.As(err, &target) {
+			t.Fatalf("expected KMSInvalidStateException, got %v", err)
+		}
+
+		if _, err := client.CancelKeyDeletion(ctx, &kms.CancelKeyDeletionInput{KeyId: metadata.KeyId}); err != nil {
+			t.Fatalf("failed to cancel key deletion: %s", err)
+		}
+		described, err := client.DescribeKey(ctx, &kms.DescribeKeyInput{KeyId: metadata.KeyId})
+		if err != nil {
+			t.Fatalf("failed to describe key: %s", err)
+		}
+		if described.KeyMetadata.KeyState != types.KeyStateDisabled {
+			t.Fatalf("expected key state Disabled, got %q", described.KeyMetadata.KeyState)
+		}
+	})
+}
@@ -0,0 +1,236 @@
+package awssigner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultRetryBaseDelay is the delay before the first retry of a
+	// throttled request, unless specified otherwise using WithBaseDelay()
+	DefaultRetryBaseDelay = 100 * time.Millisecond
+
+	// DefaultRetryMaxDelay is the upper bound of the delay between retries
+	// of a throttled request, unless specified otherwise using WithMaxDelay()
+	DefaultRetryMaxDelay = 5 * time.Second
+)
+
+// Limiter limits the rate of the requests that are sent to KMS for each
+// key, and retries requests that KMS throttles anyway.
+//
+// The rate is limited using a token bucket per key, which allows the
+// number of requests per second specified via NewLimiter() or WithLimit(),
+// with bursts of up to the number of requests specified via WithBurst().
+// Requests that would exceed the rate wait for their turn, unless that
+// would take longer than the deadline of the context.Context allows.
+//
+// Requests that fail with ErrThrottled are retried up to the number of
+// times specified via WithMaxRetries(), with exponential backoff and full
+// jitter. Retries are not attempted if the delay would go past the
+// deadline of the context.Context.
+//
+// A Limiter is safe for concurrent use, and should be shared between all
+// the objects that use the same keys. The objects derived from a Limiter
+// using the With* methods share its token buckets and statistics.
+type Limiter struct {
+	baseDelay  time.Duration
+	burst      int
+	limit      float64
+	maxDelay   time.Duration
+	maxRetries int
+	state      *limiterState
+}
+
+// LimiterStats holds the number of times that the rate limit and the
+// retries kicked in. Retrieve it using Limiter.Stats().
+type LimiterStats struct {
+	// Requests is the number of requests that went through the Limiter,
+	// not counting retries
+	Requests uint64
+	// Delayed is the number of requests (including retries) that had
+	// to wait because of the rate limit
+	Delayed uint64
+	// DelayedTime is the total amount of time spent waiting because of
+	// the rate limit
+	DelayedTime time.Duration
+	// Rejected is the number of requests that were not sent, because
+	// waiting for the rate limit would have exceeded the deadline
+	Rejected uint64
+	// Throttled is the number of requests that KMS throttled
+	Throttled uint64
+	// Retries is the number of times a throttled request was retried
+	Retries uint64
+	// BackoffTime is the total amount of time spent waiting between retries
+	BackoffTime time.Duration
+	// Exhausted is the number of throttled requests that were not retried,
+	// because the number of retries specified via WithMaxRetries() was
+	// reached or because of the deadline
+	Exhausted uint64
+}
+
+type limiterState struct {
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+	stats   LimiterStats
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewLimiter creates a new Limiter that allows limit requests per second
+// for each key. Use the same value as the KMS request quota that applies
+// to you (or a fraction thereof, if the quota is shared between several
+// processes). If limit is zero or less, requests are not rate limited,
+// but throttled requests are still retried.
+func NewLimiter(limit float64) *Limiter {
+	return &Limiter{
+		limit: limit,
+		state: &limiterState{
+			buckets: make(map[string]*tokenBucket),
+		},
+	}
+}
+
+// Stats returns the number of times that the rate limit and the retries
+// kicked in so far.
+func (l *Limiter) Stats() LimiterStats {
+	l.state.mu.Lock()
+	defer l.state.mu.Unlock()
+	return l.state.stats
+}
+
+// do calls fn, after waiting for the rate limit for key, and retries it
+// if it fails with ErrThrottled. A nil Limiter just calls fn.
+func (l *Limiter) do(ctx context.Context, key string, fn func() error) error {
+	if l == nil {
+		return fn()
+	}
+
+	l.record(func(stats *LimiterStats) { stats.Requests++ })
+	for attempt := 0; ; attempt++ {
+		if err := l.wait(ctx, key); err != nil {
+			return err
+		}
+
+		err := fn()
+		if err == nil || !errors.Is(err, ErrThrottled) {
+			return err
+		}
+		l.record(func(stats *LimiterStats) { stats.Throttled++ })
+
+		if attempt >= l.maxRetries {
+			l.record(func(stats *LimiterStats) { stats.Exhausted++ })
+			return err
+		}
+
+		delay := l.backoff(attempt)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			l.record(func(stats *LimiterStats) { stats.Exhausted++ })
+			return err
+		}
+
+		l.record(func(stats *LimiterStats) {
+			stats.Retries++
+			stats.BackoffTime += delay
+		})
+		if err := sleep(ctx, delay); err != nil {
+			return fmt.Errorf(`interrupted while backing off: %w`, err)
+		}
+	}
+}
+
+// wait waits until the token bucket for key allows another request
+func (l *Limiter) wait(ctx context.Context, key string) error {
+	if l.limit <= 0 {
+		return nil
+	}
+
+	burst := l.burst
+	if burst <= 0 {
+		burst = int(math.Max(1, math.Ceil(l.limit)))
+	}
+
+	st := l.state
+	st.mu.Lock()
+	now := time.Now()
+	bucket, ok := st.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: float64(burst), last: now}
+		st.buckets[key] = bucket
+	}
+	bucket.tokens = math.Min(float64(burst), bucket.tokens+now.Sub(bucket.last).Seconds()*l.limit)
+	bucket.last = now
+
+	// take the token now, and give it back if we cannot wait for it
+	bucket.tokens--
+	if bucket.tokens >= 0 {
+		st.mu.Unlock()
+		return nil
+	}
+
+	delay := time.Duration(-bucket.tokens / l.limit * float64(time.Second))
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+		bucket.tokens++
+		st.stats.Rejected++
+		st.mu.Unlock()
+		return fmt.Errorf(`rate limit for %q would be exceeded before the deadline: %w`, key, ErrThrottled)
+	}
+	st.stats.Delayed++
+	st.stats.DelayedTime += delay
+	st.mu.Unlock()
+
+	if err := sleep(ctx, delay); err != nil {
+		// the request is not sent, so give the token back
+		st.mu.Lock()
+		bucket.tokens++
+		st.mu.Unlock()
+		return fmt.Errorf(`interrupted while waiting for rate limit: %w`, err)
+	}
+	return nil
+}
+
+// backoff returns the delay before the given retry, using exponential
+// backoff with full jitter
+func (l *Limiter) backoff(attempt int) time.Duration {
+	base := l.baseDelay
+	if base <= 0 {
+		base = DefaultRetryBaseDelay
+	}
+	maxDelay := l.maxDelay
+	if maxDelay <= 0 {
+		maxDelay = DefaultRetryMaxDelay
+	}
+
+	ceiling := maxDelay
+	if attempt < 32 {
+		if d := base << attempt; d > 0 && d < maxDelay {
+			ceiling = d
+		}
+	}
+	return rand.N(ceiling) + 1
+}
+
+func (l *Limiter) record(fn func(*LimiterStats)) {
+	l.state.mu.Lock()
+	fn(&l.state.stats)
+	l.state.mu.Unlock()
+}
+
+// sleep waits for d, or until ctx is done
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,79 @@
+package awssigner
+
+import "time"
+
+// WithBaseDelay specifies the upper bound of the delay before the first retry
+// of a throttled request. The bound doubles with each retry, up to the value
+// specified via WithMaxDelay(), and the actual delay is chosen at random
+// below it.
+//
+// If it is not specified, DefaultRetryBaseDelay is used.
+func (cs *Limiter) WithBaseDelay(v time.Duration) *Limiter {
+	return &Limiter{
+		state:      cs.state,
+		baseDelay:  v,
+		burst:      cs.burst,
+		limit:      cs.limit,
+		maxDelay:   cs.maxDelay,
+		maxRetries: cs.maxRetries,
+	}
+}
+
+// WithBurst specifies the number of requests that can be sent at once for
+// each key, before the rate limit kicks in.
+//
+// If it is not specified, it is the limit rounded up, which allows one
+// second worth of requests to be sent at once.
+func (cs *Limiter) WithBurst(v int) *Limiter {
+	return &Limiter{
+		state:      cs.state,
+		baseDelay:  cs.baseDelay,
+		burst:      v,
+		limit:      cs.limit,
+		maxDelay:   cs.maxDelay,
+		maxRetries: cs.maxRetries,
+	}
+}
+
+// WithLimit specifies the number of requests per second that are allowed
+// for each key. If it is zero or less, requests are not rate limited.
+func (cs *Limiter) WithLimit(v float64) *Limiter {
+	return &Limiter{
+		state:      cs.state,
+		baseDelay:  cs.baseDelay,
+		burst:      cs.burst,
+		limit:      v,
+		maxDelay:   cs.maxDelay,
+		maxRetries: cs.maxRetries,
+	}
+}
+
+// WithMaxDelay specifies the upper bound of the delay between retries of a
+// throttled request.
+//
+// If it is not specified, DefaultRetryMaxDelay is used.
+func (cs *Limiter) WithMaxDelay(v time.Duration) *Limiter {
+	return &Limiter{
+		state:      cs.state,
+		baseDelay:  cs.baseDelay,
+		burst:      cs.burst,
+		limit:      cs.limit,
+		maxDelay:   v,
+		maxRetries: cs.maxRetries,
+	}
+}
+
+// WithMaxRetries specifies how many times a request that KMS throttled is
+// retried.
+//
+// If it is not specified, throttled requests are not retried.
+func (cs *Limiter) WithMaxRetries(v int) *Limiter {
+	return &Limiter{
+		state:      cs.state,
+		baseDelay:  cs.baseDelay,
+		burst:      cs.burst,
+		limit:      cs.limit,
+		maxDelay:   cs.maxDelay,
+		maxRetries: v,
+	}
+}
@@ -0,0 +1,150 @@
+package awssigner_test
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+	"github.com/aws/smithy-go"
+	awssigner "github.com/jwx-go/crypto-signer/v2/aws"
+	"github.com/jwx-go/crypto-signer/v2/aws/kmstest"
+)
+
+// throttlingClient wraps the in-memory fake KMS, and fails the given
+// number of Sign requests with ThrottlingException
+type throttlingClient struct {
+	*kmstest.KMS
+	throttle atomic.Int32
+}
+
+func (c *throttlingClient) Sign(ctx context.Context, in *kms.SignInput, options ...func(*kms.Options)) (*kms.SignOutput, error) {
+	if c.throttle.Add(-1) >= 0 {
+		return nil, &smithy.GenericAPIError{Code: `ThrottlingException`, Message: `Rate exceeded`}
+	}
+	return c.KMS.Sign(ctx, in, options...)
+}
+
+func TestLimiter(t *testing.T) {
+	digest := sha256.Sum256([]byte("obla-di-obla-da"))
+
+	newSigner := func(t *testing.T, limiter *awssigner.Limiter) (*throttlingClient, *awssigner.ECDSA) {
+		t.Helper()
+		client := &throttlingClient{KMS: kmstest.New()}
+		output, err := client.CreateKey(context.Background(), &kms.CreateKeyInput{
+			KeySpec:  types.KeySpecEccNistP256,
+			KeyUsage: types.KeyUsageTypeSignVerify,
+		})
+		if err != nil {
+			t.Fatalf("failed to create key: %s", err)
+		}
+		return client, awssigner.NewECDSA(client).
+			WithKeyID(aws.ToString(output.KeyMetadata.KeyId)).
+			WithLimiter(limiter)
+	}
+
+	t.Run("rate limit", func(t *testing.T) {
+		limiter := awssigner.NewLimiter(20).WithBurst(1)
+		_, sv := newSigner(t, limiter)
+
+		start := time.Now()
+		for range 3 {
+			if _, err := sv.Sign(rand.Reader, digest[:], crypto.SHA256); err != nil {
+				t.Fatalf("failed to sign: %s", err)
+			}
+		}
+		if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+			t.Fatalf("expected the requests to be spread over at least 100ms, took %s", elapsed)
+		}
+
+		stats := limiter.Stats()
+		if stats.Requests != 3 || stats.Delayed != 2 || stats.DelayedTime <= 0 {
+			t.Fatalf("unexpected stats: %+v", stats)
+		}
+	})
+	t.Run("deadline", func(t *testing.T) {
+		limiter := awssigner.NewLimiter(1).WithBurst(1)
+		_, sv := newSigner(t, limiter)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		sv = sv.WithContext(ctx)
+
+		if _, err := sv.Sign(rand.Reader, digest[:], crypto.SHA256); err != nil {
+			t.Fatalf("failed to sign: %s", err)
+		}
+		start := time.Now()
+		if _, err := sv.Sign(rand.Reader, digest[:], crypto.SHA256); !errors.Is(err, awssigner.ErrThrottled) {
+			t.Fatalf("expected ErrThrottled, got %v", err)
+		}
+		if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+			t.Fatalf("expected the request to be rejected without waiting, took %s", elapsed)
+		}
+		if stats := limiter.Stats(); stats.Rejected != 1 {
+			t.Fatalf("unexpected stats: %+v", stats)
+		}
+	})
+	t.Run("retry", func(t *testing.T) {
+		limiter := awssigner.NewLimiter(0).
+			WithMaxRetries(3).
+			WithBaseDelay(time.Millisecond)
+		client, sv := newSigner(t, limiter)
+		client.throttle.Store(2)
+
+		if _, err := sv.Sign(rand.Reader, digest[:], crypto.SHA256); err != nil {
+			t.Fatalf("failed to sign: %s", err)
+		}
+		stats := limiter.Stats()
+		if stats.Throttled != 2 || stats.Retries != 2 || stats.Exhausted != 0 {
+			t.Fatalf("unexpected stats: %+v", stats)
+		}
+	})
+	t.Run("retries exhausted", func(t *testing.T) {
+		limiter := awssigner.NewLimiter(0).
+			WithMaxRetries(1).
+			WithBaseDelay(time.Millisecond)
+		client, sv := newSigner(t, limiter)
+		client.throttle.Store(5)
+
+		if _, err := sv.Sign(rand.Reader, digest[:], crypto.SHA256); !errors.Is(err, awssigner.ErrThrottled) {
+			t.Fatalf("expected ErrThrottled, got %v", err)
+		}
+		stats := limiter.Stats()
+		if stats.Throttled != 2 || stats.Retries != 1 || stats.Exhausted != 1 {
+			t.Fatalf("unexpected stats: %+v", stats)
+		}
+	})
+	t.Run("canceled while waiting", func(t *testing.T) {
+		limiter := awssigner.NewLimiter(5).WithBurst(1)
+		_, sv := newSigner(t, limiter)
+
+		if _, err := sv.Sign(rand.Reader, digest[:], crypto.SHA256); err != nil {
+			t.Fatalf("failed to sign: %s", err)
+		}
+
+		// the request waits for 200ms, but is canceled before that
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(20*time.Millisecond, cancel)
+		if _, err := sv.WithContext(ctx).Sign(rand.Reader, digest[:], crypto.SHA256); !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected context.Canceled, got %v", err)
+		}
+
+		// the canceled request gave its token back, so the next request
+		// waits for less than 200ms instead of almost 400ms
+		ctx, cancel = context.WithTimeout(context.Background(), 280*time.Millisecond)
+		defer cancel()
+		if _, err := sv.WithContext(ctx).Sign(rand.Reader, digest[:], crypto.SHA256); err != nil {
+			t.Fatalf("failed to sign: %s", err)
+		}
+		if stats := limiter.Stats(); stats.Rejected != 0 {
+			t.Fatalf("unexpected stats: %+v", stats)
+		}
+	})
+}
@@ -0,0 +1,64 @@
+package awssigner
+
+import (
+	"bytes"
+	"crypto"
+	"fmt"
+	"io"
+
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+)
+
+// messageForSigning reads the message to be signed from r, and returns
+// what should be sent to KMS along with the message type.
+//
+// If mt is types.MessageTypeRaw, the message is returned as is, and it
+// must not be larger than maxRawMessageSize. If mt is
+// types.MessageTypeDigest, the message is hashed locally using hash. If
+// mt is empty, messages up to maxRawMessageSize bytes are returned as is,
+// and larger messages are hashed.
+//
+// Messages that are hashed are never held in memory as a whole, so r may
+// be arbitrarily large. A zero hash means that the algorithm requires
+// the message itself.
+func messageForSigning(r io.Reader, hash crypto.Hash, mt types.MessageType) ([]byte, types.MessageType, error) {
+	switch mt {
+	case "", types.MessageTypeRaw, types.MessageTypeDigest:
+	default:
+		return nil, "", fmt.Errorf(`unsupported message type %q`, mt)
+	}
+
+	if mt == types.MessageTypeDigest {
+		if hash == crypto.Hash(0) {
+			return nil, "", fmt.Errorf(`the signing algorithm does not support message type %s`, mt)
+		}
+		return hashMessage(r, hash)
+	}
+
+	// read one byte more than allowed, to find out if the message is
+	// small enough to be sent as is
+	var buf bytes.Buffer
+	if _, err := io.CopyN(&buf, r, maxRawMessageSize+1); err != nil && err != io.EOF {
+		return nil, "", fmt.Errorf(`failed to read message: %w`, err)
+	}
+	if buf.Len() <= maxRawMessageSize {
+		return buf.Bytes(), types.MessageTypeRaw, nil
+	}
+
+	if mt == types.MessageTypeRaw || hash == crypto.Hash(0) {
+		return nil, "", fmt.Errorf(`cannot sign messages larger than %d bytes with message type %s`, maxRawMessageSize, types.MessageTypeRaw)
+	}
+	return hashMessage(io.MultiReader(&buf, r), hash)
+}
+
+func hashMessage(r io.Reader, hash crypto.Hash) ([]byte, types.MessageType, error) {
+	if !hash.Available() {
+		return nil, "", fmt.Errorf(`hash function %s is not available`, hash)
+	}
+
+	h := hash.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return nil, "", fmt.Errorf(`failed to hash message: %w`, err)
+	}
+	return h.Sum(nil), types.MessageTypeDigest, nil
+}
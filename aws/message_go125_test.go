@@ -0,0 +1,18 @@
+//go:build go1.25
+
+package awssigner_test
+
+import (
+	"crypto"
+
+	awssigner "github.com/jwx-go/crypto-signer/v2/aws"
+)
+
+var (
+	_ crypto.MessageSigner = (*awssigner.RSA)(nil)
+	_ crypto.MessageSigner = (*awssigner.ECDSA)(nil)
+	_ crypto.MessageSigner = (*awssigner.EdDSA)(nil)
+	_ crypto.MessageSigner = (*awssigner.MLDSA)(nil)
+	_ crypto.MessageSigner = (*awssigner.SM2)(nil)
+	_ crypto.MessageSigner = (*awssigner.Signer)(nil)
+)
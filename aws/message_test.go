@@ -0,0 +1,148 @@
+package awssigner_test
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"io"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+	awssigner "github.com/jwx-go/crypto-signer/v2/aws"
+)
+
+func TestSignMessage(t *testing.T) {
+	small := []byte("ob-la-di, ob-la-da, life goes on, bra")
+	large := bytes.Repeat([]byte("la-la how the life goes on "), 1000)
+
+	t.Run("ECDSA", func(t *testing.T) {
+		client, kid := newTestKey(t, types.KeySpecEccNistP256)
+		sv := awssigner.NewECDSA(client).WithKeyID(kid)
+		pubkey, err := sv.GetPublicKey()
+		if err != nil {
+			t.Fatalf("failed to get public key: %s", err)
+		}
+
+		testcases := []struct {
+			Name        string
+			Signer      *awssigner.ECDSA
+			Message     []byte
+			MessageType types.MessageType
+			Error       bool
+		}{
+			{Name: "small message", Signer: sv, Message: small, MessageType: types.MessageTypeRaw},
+			{Name: "large message", Signer: sv, Message: large, MessageType: types.MessageTypeDigest},
+			{Name: "forced digest", Signer: sv.WithMessageType(types.MessageTypeDigest), Message: small, MessageType: types.MessageTypeDigest},
+			{Name: "forced raw", Signer: sv.WithMessageType(types.MessageTypeRaw), Message: large, Error: true},
+			{Name: "unsupported message type", Signer: sv.WithMessageType(types.MessageTypeExternalMu), Message: small, Error: true},
+		}
+		for _, tc := range testcases {
+			t.Run(tc.Name, func(t *testing.T) {
+				signed, err := tc.Signer.SignMessage(rand.Reader, tc.Message, crypto.SHA256)
+				if tc.Error {
+					if err == nil {
+						t.Fatalf("expected an error")
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("failed to sign: %s", err)
+				}
+				if client.lastMessageType != tc.MessageType {
+					t.Fatalf("expected message type %s, got %s", tc.MessageType, client.lastMessageType)
+				}
+
+				digest := sha256.Sum256(tc.Message)
+				if !ecdsa.VerifyASN1(pubkey.(*ecdsa.PublicKey), digest[:], signed) {
+					t.Fatalf("failed to verify signature")
+				}
+			})
+		}
+	})
+	t.Run("RSA stream", func(t *testing.T) {
+		client, kid := newTestKey(t, types.KeySpecRsa2048)
+		sv := awssigner.NewRSA(client).WithKeyID(kid)
+		pubkey, err := sv.GetPublicKey()
+		if err != nil {
+			t.Fatalf("failed to get public key: %s", err)
+		}
+
+		// a message that is much larger than anything KMS would accept,
+		// read from a stream
+		const size = 1 << 20
+		opts := &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA512}
+		signed, err := sv.SignStream(io.LimitReader(zeroReader{}, size), opts)
+		if err != nil {
+			t.Fatalf("failed to sign: %s", err)
+		}
+		if client.lastAlgorithm != types.SigningAlgorithmSpecRsassaPssSha512 || client.lastMessageType != types.MessageTypeDigest {
+			t.Fatalf("expected %s with %s, got %s with %s", types.SigningAlgorithmSpecRsassaPssSha512, types.MessageTypeDigest, client.lastAlgorithm, client.lastMessageType)
+		}
+
+		digest := sha512.Sum512(make([]byte, size))
+		if err := rsa.VerifyPSS(pubkey.(*rsa.PublicKey), crypto.SHA512, digest[:], signed, opts); err != nil {
+			t.Fatalf("failed to verify signature: %s", err)
+		}
+	})
+	t.Run("Signer Ed25519", func(t *testing.T) {
+		client, kid := newTestKey(t, types.KeySpecEccNistEdwards25519)
+		sv := awssigner.New(client).WithKeyID(kid)
+		pubkey, err := sv.GetPublicKey()
+		if err != nil {
+			t.Fatalf("failed to get public key: %s", err)
+		}
+
+		signed, err := sv.SignMessage(rand.Reader, small, crypto.Hash(0))
+		if err != nil {
+			t.Fatalf("failed to sign: %s", err)
+		}
+		if !ed25519.Verify(pubkey.(ed25519.PublicKey), small, signed) {
+			t.Fatalf("failed to verify signature")
+		}
+
+		if _, err := sv.SignMessage(rand.Reader, large, crypto.Hash(0)); err == nil {
+			t.Fatalf("expected an error for a large message without Ed25519ph")
+		}
+
+		signed, err = sv.SignMessage(rand.Reader, large, crypto.SHA512)
+		if err != nil {
+			t.Fatalf("failed to sign: %s", err)
+		}
+		if client.lastAlgorithm != types.SigningAlgorithmSpecEd25519PhSha512 {
+			t.Fatalf("expected %s, got %s", types.SigningAlgorithmSpecEd25519PhSha512, client.lastAlgorithm)
+		}
+		digest := sha512.Sum512(large)
+		if err := ed25519.VerifyWithOptions(pubkey.(ed25519.PublicKey), digest[:], signed, &ed25519.Options{Hash: crypto.SHA512}); err != nil {
+			t.Fatalf("failed to verify signature: %s", err)
+		}
+	})
+	t.Run("EdDSA Ed25519ph", func(t *testing.T) {
+		client, kid := newTestKey(t, types.KeySpecEccNistEdwards25519)
+		sv := awssigner.NewEdDSA(client).WithKeyID(kid)
+		pubkey, err := sv.GetPublicKey()
+		if err != nil {
+			t.Fatalf("failed to get public key: %s", err)
+		}
+
+		signed, err := sv.SignMessage(rand.Reader, large, crypto.SHA512)
+		if err != nil {
+			t.Fatalf("failed to sign: %s", err)
+		}
+		digest := sha512.Sum512(large)
+		if err := ed25519.VerifyWithOptions(pubkey.(ed25519.PublicKey), digest[:], signed, &ed25519.Options{Hash: crypto.SHA512}); err != nil {
+			t.Fatalf("failed to verify signature: %s", err)
+		}
+	})
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
@@ -0,0 +1,312 @@
+package awssigner
+
+import (
+	"context"
+	"crypto"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+	"github.com/cloudflare/circl/sign"
+	"github.com/cloudflare/circl/sign/mldsa/mldsa44"
+	"github.com/cloudflare/circl/sign/mldsa/mldsa65"
+	"github.com/cloudflare/circl/sign/mldsa/mldsa87"
+	"golang.org/x/crypto/sha3"
+)
+
+// maxRawMessageSize is the largest message that AWS KMS accepts
+// when MessageType is RAW.
+const maxRawMessageSize = 4096
+
+// MLDSA is a crypto.Signer for AWS KMS keys with the ML_DSA_44, ML_DSA_65,
+// or ML_DSA_87 key specs.
+//
+// ML-DSA signs the full message instead of a digest, so the `digest`
+// argument to Sign() is expected to contain the raw payload.
+type MLDSA struct {
+	aliasRefresh time.Duration
+	checkState   bool
+	client       Client
+	cache        Cache
+	ctx          context.Context
+	grantTokens  []string
+	kid          string
+	limiter      *Limiter
+	memo         *keyMemo
+	mt           types.MessageType
+	mismatchHook func(string, error)
+	selfVerify   bool
+}
+
+// NewMLDSA creates a new MLDSA object. This object isnot complete by itself -- it
+// needs to be setup with a key ID, and a context.Context object to use while
+// the AWS SDK makes network requests.
+//
+// The signing algorithm is always ML_DSA_SHAKE_256, so there is no need to
+// specify it.
+func NewMLDSA(client Client) *MLDSA {
+	return &MLDSA{
+		client: client,
+		memo:   &keyMemo{},
+	}
+}
+
+func (sv *MLDSA) getContext() context.Context {
+	ctx := sv.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return ctx
+}
+
+func (sv *MLDSA) key() kmsKey {
+	return kmsKey{
+		aliasRefresh: sv.aliasRefresh,
+		cache:        sv.cache,
+		client:       sv.client,
+		grantTokens:  sv.grantTokens,
+		kid:          sv.kid,
+		memo:         sv.memo,
+	}
+}
+
+// Sign generates a signature for the given message.
+//
+// opts.HashFunc() must return zero, as ML-DSA does not sign pre-hashed
+// messages. ML-DSA context strings are not supported by AWS KMS, and
+// therefore the signature is always computed with an empty context.
+//
+// When the message type is types.MessageTypeExternalMu, the message
+// representative (mu) is computed locally from the public key and the
+// message before it is sent to KMS. This allows messages larger than
+// the 4096 byte limit imposed on types.MessageTypeRaw to be signed.
+func (sv *MLDSA) Sign(_ io.Reader, message []byte, opts crypto.SignerOpts) ([]byte, error) {
+	if sv.kid == "" {
+		return nil, fmt.Errorf(`aws.MLDSA.Sign() requires the key ID`)
+	}
+
+	if opts != nil && opts.HashFunc() != crypto.Hash(0) {
+		return nil, fmt.Errorf(`aws.MLDSA.Sign() expected opts.HashFunc() to be zero, got %s`, opts.HashFunc())
+	}
+
+	// the signature is verified over the message itself, even when mu
+	// is sent to KMS
+	original := message
+
+	mt := sv.mt
+	if mt == "" {
+		mt = types.MessageTypeRaw
+	}
+
+	switch mt {
+	case types.MessageTypeRaw:
+		if len(message) > maxRawMessageSize {
+			return nil, fmt.Errorf(`aws.MLDSA.Sign() cannot sign messages larger than %d bytes with message type RAW (got %d bytes), use EXTERNAL_MU instead`, maxRawMessageSize, len(message))
+		}
+	case types.MessageTypeExternalMu:
+		pubkey, err := sv.GetPublicKey()
+		if err != nil {
+			return nil, fmt.Errorf(`failed to retrieve public key to compute mu: %w`, err)
+		}
+		mu, err := MLDSAExternalMu(pubkey, message)
+		if err != nil {
+			return nil, fmt.Errorf(`failed to compute mu: %w`, err)
+		}
+		message = mu
+	default:
+		return nil, fmt.Errorf(`aws.MLDSA.Sign() does not support message type %q`, mt)
+	}
+
+	// sv.ctx is NOT required, but we will use context.Background here
+	// which means there will not be a (clean) way to interrupt this
+	// operation
+	ctx := sv.getContext()
+
+	kid, err := sv.key().resolve(ctx)
+	if err != nil {
+		return nil, fmt.Errorf(`aws.MLDSA.Sign() %w`, err)
+	}
+
+	if sv.checkState {
+		if err := kmsCheckKeyState(ctx, sv.client, kid, sv.grantTokens, types.KeyUsageTypeSignVerify); err != nil {
+			return nil, fmt.Errorf(`aws.MLDSA.Sign() refused to use the key: %w`, err)
+		}
+	}
+
+	signed, err := kmsSignLimited(ctx, sv.limiter, sv.client, kid, sv.grantTokens, message, mt, types.SigningAlgorithmSpecMlDsaShake256)
+	if err != nil {
+		return nil, err
+	}
+	if sv.selfVerify {
+		if err := sv.key().selfVerify(ctx, kid, sv.mismatchHook, original, types.MessageTypeRaw, types.SigningAlgorithmSpecMlDsaShake256, signed); err != nil {
+			return nil, fmt.Errorf(`aws.MLDSA.Sign() %w`, err)
+		}
+	}
+	return signed, nil
+}
+
+// SignMessage implements crypto.MessageSigner. Because Sign() already
+// receives the message rather than a digest, it is the same as Sign().
+func (sv *MLDSA) SignMessage(rand io.Reader, message []byte, opts crypto.SignerOpts) ([]byte, error) {
+	return sv.Sign(rand, message, opts)
+}
+
+// CheckAccess makes sure that the key can be used for signing, by calling
+// the KMS Sign API with DryRun enabled. Use it at startup to find out
+// about missing permissions or disabled keys before the first signature
+// is actually required.
+func (sv *MLDSA) CheckAccess(ctx context.Context) error {
+	if sv.kid == "" {
+		return fmt.Errorf(`aws.MLDSA.CheckAccess() requires the key ID`)
+	}
+	if err := sv.key().checkSign(ctx, types.SigningAlgorithmSpecMlDsaShake256); err != nil {
+		return fmt.Errorf(`aws.MLDSA.CheckAccess() %w`, err)
+	}
+	return nil
+}
+
+// KeyARN returns the ARN of the key, resolving the alias (if any) in the
+// same way as RSA.KeyARN(). It identifies the key that produced a
+// signature even after the alias has been pointed to a new key, so
+// publish it along with the public key.
+func (sv *MLDSA) KeyARN() (string, error) {
+	if sv.kid == "" {
+		return "", fmt.Errorf(`aws.MLDSA.KeyARN() requires the key ID`)
+	}
+
+	// sv.ctx is NOT required, but we will use context.Background here
+	// which means there will not be a (clean) way to interrupt this
+	// operation
+	ctx := sv.getContext()
+
+	return sv.key().arn(ctx)
+}
+
+// Public returns the corresponding public key.
+//
+// Because the crypto.Signer API does not allow for an error to be returned,
+// the return value from this function cannot describe what kind of error
+// occurred.
+func (sv *MLDSA) Public() crypto.PublicKey {
+	pubkey, _ := sv.GetPublicKey()
+	return pubkey
+}
+
+// This method is an escape hatch for those cases where the user needs
+// to debug what went wrong during the GetPublicKey operation.
+//
+// The returned value is one of *mldsa44.PublicKey, *mldsa65.PublicKey, or
+// *mldsa87.PublicKey from github.com/cloudflare/circl, all of which
+// implement sign.PublicKey.
+func (sv *MLDSA) GetPublicKey() (crypto.PublicKey, error) {
+	if sv.kid == "" {
+		return nil, fmt.Errorf(`aws.MLDSA.GetPublicKey() requires the key ID`)
+	}
+
+	// sv.ctx is NOT required, but we will use context.Background here
+	// which means there will not be a (clean) way to interrupt this
+	// operation
+	ctx := sv.getContext()
+
+	info, err := sv.key().info(ctx, types.KeyUsageTypeSignVerify)
+	if err != nil {
+		return nil, fmt.Errorf(`aws.MLDSA.GetPublicKey() %w`, err)
+	}
+
+	pubkey, ok := info.publicKey.(sign.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf(`aws.MLDSA.GetPublicKey() expected ML-DSA public key, got %T`, info.publicKey)
+	}
+	return pubkey, nil
+}
+
+var mldsaSchemes = []sign.Scheme{
+	mldsa44.Scheme(),
+	mldsa65.Scheme(),
+	mldsa87.Scheme(),
+}
+
+// oidScheme is implemented by the ML-DSA schemes in circl
+type oidScheme interface {
+	Oid() asn1.ObjectIdentifier
+}
+
+type subjectPublicKeyInfo struct {
+	Algorithm pkix.AlgorithmIdentifier
+	PublicKey asn1.BitString
+}
+
+// ParseMLDSAPublicKey parses a DER encoded ML-DSA SubjectPublicKeyInfo,
+// such as the one returned by the KMS GetPublicKey API for ML-DSA keys.
+// crypto/x509 cannot parse these keys as of this writing.
+func ParseMLDSAPublicKey(der []byte) (sign.PublicKey, error) {
+	var spki subjectPublicKeyInfo
+	rest, err := asn1.Unmarshal(der, &spki)
+	if err != nil {
+		return nil, fmt.Errorf(`failed to parse SubjectPublicKeyInfo: %w`, err)
+	}
+	if len(rest) > 0 {
+		return nil, fmt.Errorf(`trailing data after SubjectPublicKeyInfo`)
+	}
+
+	// The parameters field MUST be absent for ML-DSA
+	if len(spki.Algorithm.Parameters.FullBytes) > 0 {
+		return nil, fmt.Errorf(`unexpected parameters in ML-DSA algorithm identifier`)
+	}
+
+	for _, scheme := range mldsaSchemes {
+		if !scheme.(oidScheme).Oid().Equal(spki.Algorithm.Algorithm) {
+			continue
+		}
+		return scheme.UnmarshalBinaryPublicKey(spki.PublicKey.RightAlign())
+	}
+	return nil, fmt.Errorf(`unsupported algorithm %s`, spki.Algorithm.Algorithm)
+}
+
+// MLDSAExternalMu computes the ML-DSA message representative (mu) for
+// the given public key and message, using an empty context string.
+//
+// The result can be signed by AWS KMS using types.MessageTypeExternalMu, and
+// the resulting signature verifies against the original message.
+func MLDSAExternalMu(key crypto.PublicKey, message []byte) ([]byte, error) {
+	pubkey, ok := key.(sign.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf(`expected sign.PublicKey, got %T`, key)
+	}
+	pkbytes, err := pubkey.MarshalBinary()
+	if err != nil {
+		return nil, fmt.Errorf(`failed to marshal public key: %w`, err)
+	}
+
+	// tr = H(pk, 64)
+	tr := make([]byte, 64)
+	h := sha3.NewShake256()
+	_, _ = h.Write(pkbytes)
+	_, _ = h.Read(tr)
+
+	// mu = H(tr || M', 64), where M' = 0 || len(ctx) || ctx || M
+	mu := make([]byte, 64)
+	h = sha3.NewShake256()
+	_, _ = h.Write(tr)
+	_, _ = h.Write([]byte{0, 0})
+	_, _ = h.Write(message)
+	_, _ = h.Read(mu)
+	return mu, nil
+}
+
+// VerifyMLDSA verifies an ML-DSA signature over message using the given
+// public key, which must be one of the keys returned by ParseMLDSAPublicKey
+// (or MLDSA.GetPublicKey). An empty context string is assumed.
+func VerifyMLDSA(key crypto.PublicKey, message, signature []byte) error {
+	pubkey, ok := key.(sign.PublicKey)
+	if !ok {
+		return fmt.Errorf(`expected sign.PublicKey, got %T`, key)
+	}
+	if !pubkey.Scheme().Verify(pubkey, message, signature, nil) {
+		return fmt.Errorf(`failed to verify ML-DSA signature`)
+	}
+	return nil
+}
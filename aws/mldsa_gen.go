@@ -0,0 +1,233 @@
+package awssigner
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+)
+
+// WithAliasRefreshInterval specifies how long an alias is considered to
+// point to the same key. This only has an effect when a Cache is provided:
+// aliases are then resolved to the key ARN using KMS DescribeKey, and the
+// cached items are stored under the key ARN, so that they do not become
+// stale when the alias is updated to point to a different key.
+//
+// If it is not specified, DefaultAliasRefreshInterval is used.
+func (cs *MLDSA) WithAliasRefreshInterval(v time.Duration) *MLDSA {
+	return &MLDSA{
+		client:       cs.client,
+		memo:         cs.memo,
+		aliasRefresh: v,
+		cache:        cs.cache,
+		checkState:   cs.checkState,
+		ctx:          cs.ctx,
+		grantTokens:  cs.grantTokens,
+		kid:          cs.kid,
+		limiter:      cs.limiter,
+		mismatchHook: cs.mismatchHook,
+		mt:           cs.mt,
+		selfVerify:   cs.selfVerify,
+	}
+}
+
+// WithCache specifies the cache storage for frequently used items.
+// Currently only the public key is cached, so that it can be shared
+// with other objects.
+//
+// If it is not specified, the public key is only remembered by this
+// object, and by the objects derived from it.
+//
+// Since it would be rather easy for the key in AWS KMS and the cache
+// to be out of sync, make sure to either purge the cache periodically
+// or use a cache with some sort of auto-eviction mechanism.
+func (cs *MLDSA) WithCache(v Cache) *MLDSA {
+	return &MLDSA{
+		client:       cs.client,
+		memo:         cs.memo,
+		aliasRefresh: cs.aliasRefresh,
+		cache:        v,
+		checkState:   cs.checkState,
+		ctx:          cs.ctx,
+		grantTokens:  cs.grantTokens,
+		kid:          cs.kid,
+		limiter:      cs.limiter,
+		mismatchHook: cs.mismatchHook,
+		mt:           cs.mt,
+		selfVerify:   cs.selfVerify,
+	}
+}
+
+// WithKeyStateCheck specifies whether the state of the key should be checked
+// using KMS DescribeKey before each signature. If enabled, Sign() refuses to
+// use keys that are not enabled, or that do not have the SIGN_VERIFY key
+// usage, and returns an error wrapping ErrKeyDisabled, ErrKeyPendingDeletion,
+// or ErrWrongKeyUsage.
+//
+// This costs an extra request to KMS per signature, and is disabled by default.
+func (cs *MLDSA) WithKeyStateCheck(v bool) *MLDSA {
+	return &MLDSA{
+		client:       cs.client,
+		memo:         cs.memo,
+		aliasRefresh: cs.aliasRefresh,
+		cache:        cs.cache,
+		checkState:   v,
+		ctx:          cs.ctx,
+		grantTokens:  cs.grantTokens,
+		kid:          cs.kid,
+		limiter:      cs.limiter,
+		mismatchHook: cs.mismatchHook,
+		mt:           cs.mt,
+		selfVerify:   cs.selfVerify,
+	}
+}
+
+// WithContext associates a new context.Context with the object, which will be used for Sign() and Public()
+func (cs *MLDSA) WithContext(v context.Context) *MLDSA {
+	return &MLDSA{
+		client:       cs.client,
+		memo:         cs.memo,
+		aliasRefresh: cs.aliasRefresh,
+		cache:        cs.cache,
+		checkState:   cs.checkState,
+		ctx:          v,
+		grantTokens:  cs.grantTokens,
+		kid:          cs.kid,
+		limiter:      cs.limiter,
+		mismatchHook: cs.mismatchHook,
+		mt:           cs.mt,
+		selfVerify:   cs.selfVerify,
+	}
+}
+
+// WithGrantTokens specifies the grant tokens to send along with each request
+// to KMS. Grant tokens allow you to use the permissions granted by a
+// grant before the grant has achieved eventual consistency.
+//
+// See https://docs.aws.amazon.com/kms/latest/developerguide/grant-manage.html#using-grant-token
+func (cs *MLDSA) WithGrantTokens(v []string) *MLDSA {
+	return &MLDSA{
+		client:       cs.client,
+		memo:         cs.memo,
+		aliasRefresh: cs.aliasRefresh,
+		cache:        cs.cache,
+		checkState:   cs.checkState,
+		ctx:          cs.ctx,
+		grantTokens:  v,
+		kid:          cs.kid,
+		limiter:      cs.limiter,
+		mismatchHook: cs.mismatchHook,
+		mt:           cs.mt,
+		selfVerify:   cs.selfVerify,
+	}
+}
+
+// WithKeyID associates a new string with the object, which will be used for Sign() and Public()
+func (cs *MLDSA) WithKeyID(v string) *MLDSA {
+	return &MLDSA{
+		client:       cs.client,
+		memo:         cs.memo,
+		aliasRefresh: cs.aliasRefresh,
+		cache:        cs.cache,
+		checkState:   cs.checkState,
+		ctx:          cs.ctx,
+		grantTokens:  cs.grantTokens,
+		kid:          v,
+		limiter:      cs.limiter,
+		mismatchHook: cs.mismatchHook,
+		mt:           cs.mt,
+		selfVerify:   cs.selfVerify,
+	}
+}
+
+// WithLimiter specifies the Limiter that limits the rate of Sign() requests
+// sent to KMS for the key, and retries requests that KMS throttles.
+//
+// If it is not specified, requests are sent as soon as Sign() is called,
+// and throttling errors are returned as they are.
+func (cs *MLDSA) WithLimiter(v *Limiter) *MLDSA {
+	return &MLDSA{
+		client:       cs.client,
+		memo:         cs.memo,
+		aliasRefresh: cs.aliasRefresh,
+		cache:        cs.cache,
+		checkState:   cs.checkState,
+		ctx:          cs.ctx,
+		grantTokens:  cs.grantTokens,
+		kid:          cs.kid,
+		limiter:      v,
+		mismatchHook: cs.mismatchHook,
+		mt:           cs.mt,
+		selfVerify:   cs.selfVerify,
+	}
+}
+
+// WithMismatchHook specifies a function that is called with the key ID
+// and the error whenever self-verification (see WithSelfVerify()) fails.
+// Use it to alert on signatures that do not match the public key.
+func (cs *MLDSA) WithMismatchHook(v func(string, error)) *MLDSA {
+	return &MLDSA{
+		client:       cs.client,
+		memo:         cs.memo,
+		aliasRefresh: cs.aliasRefresh,
+		cache:        cs.cache,
+		checkState:   cs.checkState,
+		ctx:          cs.ctx,
+		grantTokens:  cs.grantTokens,
+		kid:          cs.kid,
+		limiter:      cs.limiter,
+		mismatchHook: v,
+		mt:           cs.mt,
+		selfVerify:   cs.selfVerify,
+	}
+}
+
+// WithMessageType specifies the message type to use when signing.
+// Only types.MessageTypeRaw (the default) and types.MessageTypeExternalMu
+// are supported.
+func (cs *MLDSA) WithMessageType(v types.MessageType) *MLDSA {
+	return &MLDSA{
+		client:       cs.client,
+		memo:         cs.memo,
+		aliasRefresh: cs.aliasRefresh,
+		cache:        cs.cache,
+		checkState:   cs.checkState,
+		ctx:          cs.ctx,
+		grantTokens:  cs.grantTokens,
+		kid:          cs.kid,
+		limiter:      cs.limiter,
+		mismatchHook: cs.mismatchHook,
+		mt:           v,
+		selfVerify:   cs.selfVerify,
+	}
+}
+
+// WithSelfVerify specifies whether each signature returned by KMS is
+// verified locally against the public key before it is returned. When
+// the verification fails, the signature is discarded, an error wrapping
+// ErrSignatureMismatch is returned, and the function specified via
+// WithMismatchHook() is called.
+//
+// This guards against corrupted responses, and against keys that no
+// longer match the public key that verifiers use. The public key is
+// retrieved from KMS only once per key: without a Cache, an alias is
+// pinned to the key that it pointed to when the public key was first
+// retrieved, so signatures made after the alias was re-pointed fail
+// the verification. With a Cache, the alias is followed instead (see
+// WithAliasRefreshInterval()).
+func (cs *MLDSA) WithSelfVerify(v bool) *MLDSA {
+	return &MLDSA{
+		client:       cs.client,
+		memo:         cs.memo,
+		aliasRefresh: cs.aliasRefresh,
+		cache:        cs.cache,
+		checkState:   cs.checkState,
+		ctx:          cs.ctx,
+		grantTokens:  cs.grantTokens,
+		kid:          cs.kid,
+		limiter:      cs.limiter,
+		mismatchHook: cs.mismatchHook,
+		mt:           cs.mt,
+		selfVerify:   v,
+	}
+}
@@ -0,0 +1,84 @@
+package awssigner_test
+
+import (
+	"bytes"
+	"crypto"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+	"github.com/cloudflare/circl/sign/mldsa/mldsa65"
+	awssigner "github.com/jwx-go/crypto-signer/v2/aws"
+)
+
+var _ crypto.Signer = &awssigner.MLDSA{}
+
+func TestMLDSA(t *testing.T) {
+	keys, kid := newTestKey(t, types.KeySpecMlDsa65)
+
+	sv := awssigner.NewMLDSA(keys).
+		WithKeyID(kid).
+		WithCache(NewDumbCache())
+
+	key, err := sv.GetPublicKey()
+	if err != nil {
+		t.Fatalf("failed to get public key: %s", err)
+	}
+	if _, ok := key.(*mldsa65.PublicKey); !ok {
+		t.Fatalf("expected *mldsa65.PublicKey, got %T", key)
+	}
+
+	t.Run("RAW", func(t *testing.T) {
+		payload := []byte("obla-di-obla-da")
+		signature, err := sv.Sign(nil, payload, crypto.Hash(0))
+		if err != nil {
+			t.Fatalf("failed to sign: %s", err)
+		}
+		if err := awssigner.VerifyMLDSA(sv.Public(), payload, signature); err != nil {
+			t.Fatalf("failed to verify: %s", err)
+		}
+		if err := awssigner.VerifyMLDSA(sv.Public(), []byte("wrong payload"), signature); err == nil {
+			t.Fatalf("verification should have failed")
+		}
+	})
+	t.Run("RAW with large message", func(t *testing.T) {
+		if _, err := sv.Sign(nil, make([]byte, 8192), crypto.Hash(0)); err == nil {
+			t.Fatalf("signing a large message with RAW should fail")
+		}
+	})
+	t.Run("EXTERNAL_MU", func(t *testing.T) {
+		// larger than what KMS accepts with RAW, so mu must be computed
+		// locally
+		message := make([]byte, 8192)
+		for i := range message {
+			message[i] = byte(i)
+		}
+
+		signature, err := sv.WithMessageType(types.MessageTypeExternalMu).Sign(nil, message, crypto.Hash(0))
+		var unsupported *types.UnsupportedOperationException
+		if errors.As(err, &unsupported) {
+			t.Skipf("kmstest cannot sign mu: %s", err)
+		}
+		if err != nil {
+			t.Fatalf("failed to sign: %s", err)
+		}
+		mu, err := awssigner.MLDSAExternalMu(sv.Public(), message)
+		if err != nil {
+			t.Fatalf("failed to compute mu: %s", err)
+		}
+		if keys.lastMessageType != types.MessageTypeExternalMu || !bytes.Equal(keys.lastMessage, mu) {
+			t.Fatalf("expected mu to be sent with EXTERNAL_MU, got %d bytes with %q", len(keys.lastMessage), keys.lastMessageType)
+		}
+		if err := awssigner.VerifyMLDSA(sv.Public(), message, signature); err != nil {
+			t.Fatalf("failed to verify: %s", err)
+		}
+		if err := awssigner.VerifyMLDSA(sv.Public(), message[:4096], signature); err == nil {
+			t.Fatalf("verification should have failed")
+		}
+	})
+	t.Run("pre-hashed message", func(t *testing.T) {
+		if _, err := sv.Sign(nil, []byte("obla-di-obla-da"), crypto.SHA256); err == nil {
+			t.Fatalf("signing with a hash function should fail")
+		}
+	})
+}
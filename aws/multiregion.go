@@ -0,0 +1,426 @@
+package awssigner
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+	"github.com/aws/smithy-go"
+)
+
+// Replica describes one of the replicas of a KMS multi-Region key.
+type Replica struct {
+	// Client is the KMS client for the region that the replica lives in.
+	Client Client
+	// ARN is the key ARN of the replica. The region that is reported
+	// by MultiRegion is taken from it.
+	ARN string
+}
+
+// MultiRegion is a crypto.Signer for KMS multi-Region keys. It is
+// configured with an ordered list of replicas of the same key, and
+// signs using the first replica that is available.
+//
+// If a replica fails with a throttling error, a server side (5xx)
+// error, or a timeout, the next replica in the list is tried. Any other
+// error, such as a disabled key or missing permissions, is returned
+// immediately, as the other replicas are unlikely to behave differently.
+//
+// Before a replica is used for the first time, its public key is
+// retrieved and compared against the public keys of the other replicas.
+// A replica whose public key does not match is a configuration error, and
+// causes all signing operations to fail. A replica which is unavailable
+// when the public keys are compared is skipped until it can be checked.
+//
+// The key spec is detected in the same way as Signer does, and the
+// options that Signer provides for signing (such as self-verification,
+// rate limiting, and the signature encoding) apply to each replica.
+type MultiRegion struct {
+	attemptTimeout time.Duration
+	cache          Cache
+	ctx            context.Context
+	encoding       SignatureEncoding
+	grantTokens    []string
+	limiter        *Limiter
+	lowS           bool
+	mismatchHook   func(string, error)
+	mt             types.MessageType
+	regionHook     func(string)
+	replicas       []replica
+	selfVerify     bool
+	state          *multiRegionState
+}
+
+type replica struct {
+	client Client
+	arn    string
+	region string
+}
+
+// multiRegionState is shared between all the objects derived from
+// the same MultiRegion, so that the replicas are only compared once
+type multiRegionState struct {
+	mu        sync.Mutex
+	publicKey crypto.PublicKey
+	checked   bool
+	infos     []*keyInfo // nil until the replica has been checked
+	err       error      // set if the replicas do not agree
+}
+
+// NewMultiRegion creates a new MultiRegion object from the given replicas,
+// in the order in which they should be tried. The key ARNs of the replicas
+// must be valid.
+//
+// Like the other objects in this package, the MultiRegion object can be
+// configured further using a context.Context, a Cache, and grant tokens.
+func NewMultiRegion(replicas ...Replica) (*MultiRegion, error) {
+	if len(replicas) == 0 {
+		return nil, fmt.Errorf(`aws.NewMultiRegion() requires at least one replica`)
+	}
+
+	list := make([]replica, len(replicas))
+	for i, r := range replicas {
+		if r.Client == nil {
+			return nil, fmt.Errorf(`aws.NewMultiRegion() requires a client for replica %q`, r.ARN)
+		}
+		parsed, err := arn.Parse(r.ARN)
+		if err != nil {
+			return nil, fmt.Errorf(`aws.NewMultiRegion() failed to parse ARN %q: %w`, r.ARN, err)
+		}
+		list[i] = replica{
+			client: r.Client,
+			arn:    r.ARN,
+			region: parsed.Region,
+		}
+	}
+
+	return &MultiRegion{
+		replicas: list,
+		state: &multiRegionState{
+			infos: make([]*keyInfo, len(list)),
+		},
+	}, nil
+}
+
+func (sv *MultiRegion) getContext() context.Context {
+	ctx := sv.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return ctx
+}
+
+// Regions returns the regions of the replicas, in the order in which
+// they are tried.
+func (sv *MultiRegion) Regions() []string {
+	regions := make([]string, len(sv.replicas))
+	for i, r := range sv.replicas {
+		regions[i] = r.region
+	}
+	return regions
+}
+
+// Sign generates a signature from the given digest, or from the given
+// message in the case of Ed25519 keys. The signing algorithm is chosen
+// in the same way as Signer.Sign() does.
+//
+// The region that served the signature is passed to the function
+// specified via WithRegionHook(), if any. Use SignWithRegion() if you
+// are calling Sign() directly.
+func (sv *MultiRegion) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	signed, _, err := sv.SignWithRegion(digest, opts)
+	return signed, err
+}
+
+// SignWithRegion works like Sign(), but additionally returns the region
+// of the replica that served the signature.
+func (sv *MultiRegion) SignWithRegion(digest []byte, opts crypto.SignerOpts) ([]byte, string, error) {
+	return sv.sign(`aws.MultiRegion.Sign()`, func(info *keyInfo) ([]byte, types.MessageType, types.SigningAlgorithmSpec, error) {
+		alg, mt, err := info.signingAlgorithm(digest, opts)
+		return digest, mt, alg, err
+	})
+}
+
+// SignMessage generates a signature from the given message, and
+// implements crypto.MessageSigner. The message is hashed (or not) in the
+// same way as Signer.SignMessage() does.
+func (sv *MultiRegion) SignMessage(_ io.Reader, message []byte, opts crypto.SignerOpts) ([]byte, error) {
+	signed, _, err := sv.sign(`aws.MultiRegion.SignMessage()`, func(info *keyInfo) ([]byte, types.MessageType, types.SigningAlgorithmSpec, error) {
+		alg, hash, err := info.messageSigningAlgorithm(opts)
+		if err != nil {
+			return nil, "", "", err
+		}
+		message, mt, err := messageForSigning(bytes.NewReader(message), hash, sv.mt)
+		return message, mt, alg, err
+	})
+	return signed, err
+}
+
+// sign tries each replica in turn. prepare returns the message, the
+// message type, and the signing algorithm to send to KMS for the given
+// key information. op is the name of the operation, for error messages.
+func (sv *MultiRegion) sign(op string, prepare func(*keyInfo) ([]byte, types.MessageType, types.SigningAlgorithmSpec, error)) ([]byte, string, error) {
+	// sv.ctx is NOT required, but we will use context.Background here
+	// which means there will not be a (clean) way to interrupt this
+	// operation
+	ctx := sv.getContext()
+
+	if err := sv.checkReplicas(ctx); err != nil {
+		return nil, "", fmt.Errorf(`%s %w`, op, err)
+	}
+
+	var errs []error
+	for i, r := range sv.replicas {
+		info, err := sv.replicaInfo(ctx, i)
+		if err != nil {
+			if !shouldFailover(ctx, err) {
+				return nil, "", fmt.Errorf(`%s %w`, op, err)
+			}
+			errs = append(errs, err)
+			continue
+		}
+
+		message, mt, alg, err := prepare(info)
+		if err != nil {
+			return nil, "", fmt.Errorf(`%s %w`, op, err)
+		}
+
+		actx, cancel := sv.attemptContext(ctx)
+		signed, err := kmsSignLimited(actx, sv.limiter, r.client, r.arn, sv.grantTokens, message, mt, alg)
+		cancel()
+		if err != nil {
+			if !shouldFailover(ctx, err) {
+				return nil, "", fmt.Errorf(`%s failed in region %q: %w`, op, r.region, err)
+			}
+			errs = append(errs, fmt.Errorf(`region %q: %w`, r.region, err))
+			continue
+		}
+
+		if sv.selfVerify {
+			if err := reportMismatch(r.arn, sv.mismatchHook, verifySignature(info.publicKey, alg, message, mt, signed)); err != nil {
+				return nil, "", fmt.Errorf(`%s %w`, op, err)
+			}
+		}
+		if pubkey, ok := info.publicKey.(*ecdsa.PublicKey); ok && (sv.encoding != SignatureEncodingDER || sv.lowS) {
+			signed, err = encodeECDSASignature(pubkey, signed, sv.encoding, sv.lowS)
+			if err != nil {
+				return nil, "", fmt.Errorf(`%s %w`, op, err)
+			}
+		}
+
+		if hook := sv.regionHook; hook != nil {
+			hook(r.region)
+		}
+		return signed, r.region, nil
+	}
+	return nil, "", fmt.Errorf(`%s failed in all regions: %w`, op, errors.Join(errs...))
+}
+
+// CheckAccess makes sure that the key can be used for signing in every
+// region, by calling the KMS Sign API with DryRun enabled for each
+// replica. Like CheckReplicas(), it fails if any of the replicas is
+// unavailable, as a replica that can only be used after a failover is
+// exactly what CheckAccess() is meant to find.
+func (sv *MultiRegion) CheckAccess(ctx context.Context) error {
+	for i, r := range sv.replicas {
+		info, err := sv.replicaInfo(ctx, i)
+		if err != nil {
+			return fmt.Errorf(`aws.MultiRegion.CheckAccess() %w`, err)
+		}
+		if len(info.algs) == 0 {
+			return fmt.Errorf(`aws.MultiRegion.CheckAccess() found no signing algorithms for key spec %q`, info.spec)
+		}
+
+		actx, cancel := sv.attemptContext(ctx)
+		err = kmsCheckSign(actx, r.client, r.arn, sv.grantTokens, info.algs[0])
+		cancel()
+		if err != nil {
+			return fmt.Errorf(`aws.MultiRegion.CheckAccess() failed in region %q: %w`, r.region, err)
+		}
+	}
+	return nil
+}
+
+// CheckReplicas retrieves the public keys of all the replicas, and makes
+// sure that they are the same. Unlike Sign(), it fails if any of the
+// replicas is unavailable. Use it at startup to find out about
+// misconfigured replicas before the first signature is actually required.
+func (sv *MultiRegion) CheckReplicas(ctx context.Context) error {
+	for i := range sv.replicas {
+		if _, err := sv.replicaInfo(ctx, i); err != nil {
+			return fmt.Errorf(`aws.MultiRegion.CheckReplicas() %w`, err)
+		}
+	}
+	return nil
+}
+
+// Public returns the corresponding public key.
+//
+// Because the crypto.Signer API does not allow for an error to be returned,
+// the return value from this function cannot describe what kind of error
+// occurred.
+func (sv *MultiRegion) Public() crypto.PublicKey {
+	pubkey, _ := sv.GetPublicKey()
+	return pubkey
+}
+
+// This method is an escape hatch for those cases where the user needs
+// to debug what went wrong during the GetPublicKey operation.
+func (sv *MultiRegion) GetPublicKey() (crypto.PublicKey, error) {
+	if err := sv.checkReplicas(sv.getContext()); err != nil {
+		return nil, err
+	}
+
+	st := sv.state
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.publicKey == nil {
+		return nil, fmt.Errorf(`aws.MultiRegion.GetPublicKey() failed to retrieve public key from all regions`)
+	}
+	return st.publicKey, nil
+}
+
+// checkReplicas compares the public keys of all the replicas, the first
+// time that it is called. Replicas that are unavailable are skipped, and
+// are checked by replicaInfo when they are required. It is an error if
+// none of the replicas are available.
+func (sv *MultiRegion) checkReplicas(ctx context.Context) error {
+	st := sv.state
+	st.mu.Lock()
+	checked := st.checked
+	st.checked = true
+	st.mu.Unlock()
+	if checked {
+		return nil
+	}
+
+	var errs []error
+	for i := range sv.replicas {
+		if _, err := sv.replicaInfo(ctx, i); err != nil {
+			if !shouldFailover(ctx, err) {
+				st.mu.Lock()
+				st.checked = false
+				st.mu.Unlock()
+				return err
+			}
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) == len(sv.replicas) {
+		st.mu.Lock()
+		st.checked = false
+		st.mu.Unlock()
+		return fmt.Errorf(`failed to retrieve public key from all regions: %w`, errors.Join(errs...))
+	}
+	return nil
+}
+
+// replicaInfo returns the key information for the i-th replica. The
+// information is retrieved and checked against the other replicas the
+// first time that the replica is used.
+func (sv *MultiRegion) replicaInfo(ctx context.Context, i int) (*keyInfo, error) {
+	st := sv.state
+	st.mu.Lock()
+	err := st.err
+	info := st.infos[i]
+	st.mu.Unlock()
+
+	if err != nil {
+		return nil, err
+	}
+	if info != nil {
+		return info, nil
+	}
+
+	// The public key is retrieved without holding the lock, so that a slow
+	// replica does not hold up the requests that are sent to the others
+	r := sv.replicas[i]
+	actx, cancel := sv.attemptContext(ctx)
+	defer cancel()
+
+	info, err = New(r.client).
+		WithKeyID(r.arn).
+		WithCache(sv.cache).
+		WithGrantTokens(sv.grantTokens).
+		WithContext(actx).
+		getKeyInfo()
+	if err != nil {
+		return nil, fmt.Errorf(`failed to retrieve public key in region %q: %w`, r.region, err)
+	}
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.err != nil {
+		return nil, st.err
+	}
+	if st.publicKey == nil {
+		st.publicKey = info.publicKey
+	} else if !publicKeyEqual(st.publicKey, info.publicKey) {
+		// this is a configuration error, and retrying will not help
+		st.err = fmt.Errorf(`public key in region %q does not match the other replicas`, r.region)
+		return nil, st.err
+	}
+	if st.infos[i] == nil {
+		st.infos[i] = info
+	}
+	return st.infos[i], nil
+}
+
+// attemptContext returns the context to use for a single request to
+// a replica
+func (sv *MultiRegion) attemptContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if sv.attemptTimeout > 0 {
+		return context.WithTimeout(ctx, sv.attemptTimeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+func publicKeyEqual(a, b crypto.PublicKey) bool {
+	eq, ok := a.(interface{ Equal(crypto.PublicKey) bool })
+	return ok && eq.Equal(b)
+}
+
+// shouldFailover returns true if err was caused by a throttling error,
+// a server side error, or a timeout, and the next replica should be
+// tried. ctx is the context of the whole operation: if it is done, there
+// is no point in trying another replica.
+func shouldFailover(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	// the attempt timed out, but the operation as a whole did not
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	if errors.Is(err, ErrThrottled) {
+		return true
+	}
+
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) && apiErr.ErrorFault() == smithy.FaultServer {
+		return true
+	}
+
+	var respErr interface{ HTTPStatusCode() int }
+	if errors.As(err, &respErr) && respErr.HTTPStatusCode() >= 500 {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return false
+}
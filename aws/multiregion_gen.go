@@ -0,0 +1,251 @@
+package awssigner
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+)
+
+// WithAttemptTimeout specifies the maximum amount of time to wait for
+// each replica. When it expires, the next replica is tried.
+//
+// If it is not specified, only the deadline of the context.Context
+// applies, and therefore a replica that does not respond cannot be
+// failed over.
+func (cs *MultiRegion) WithAttemptTimeout(v time.Duration) *MultiRegion {
+	return &MultiRegion{
+		replicas:       cs.replicas,
+		state:          cs.state,
+		attemptTimeout: v,
+		cache:          cs.cache,
+		ctx:            cs.ctx,
+		encoding:       cs.encoding,
+		grantTokens:    cs.grantTokens,
+		limiter:        cs.limiter,
+		lowS:           cs.lowS,
+		mismatchHook:   cs.mismatchHook,
+		mt:             cs.mt,
+		regionHook:     cs.regionHook,
+		selfVerify:     cs.selfVerify,
+	}
+}
+
+// WithCache specifies the cache storage for frequently used items.
+// Currently the public key, the key spec, and the signing algorithms
+// of each replica are cached, keyed by the replica ARN, so that they
+// can be shared with other objects.
+//
+// If it is not specified, they are only remembered by the MultiRegion
+// object, and by the objects derived from it.
+func (cs *MultiRegion) WithCache(v Cache) *MultiRegion {
+	return &MultiRegion{
+		replicas:       cs.replicas,
+		state:          cs.state,
+		attemptTimeout: cs.attemptTimeout,
+		cache:          v,
+		ctx:            cs.ctx,
+		encoding:       cs.encoding,
+		grantTokens:    cs.grantTokens,
+		limiter:        cs.limiter,
+		lowS:           cs.lowS,
+		mismatchHook:   cs.mismatchHook,
+		mt:             cs.mt,
+		regionHook:     cs.regionHook,
+		selfVerify:     cs.selfVerify,
+	}
+}
+
+// WithContext associates a new context.Context with the object, which will be used for Sign() and Public()
+func (cs *MultiRegion) WithContext(v context.Context) *MultiRegion {
+	return &MultiRegion{
+		replicas:       cs.replicas,
+		state:          cs.state,
+		attemptTimeout: cs.attemptTimeout,
+		cache:          cs.cache,
+		ctx:            v,
+		encoding:       cs.encoding,
+		grantTokens:    cs.grantTokens,
+		limiter:        cs.limiter,
+		lowS:           cs.lowS,
+		mismatchHook:   cs.mismatchHook,
+		mt:             cs.mt,
+		regionHook:     cs.regionHook,
+		selfVerify:     cs.selfVerify,
+	}
+}
+
+// WithSignatureEncoding specifies how ECDSA signatures are encoded, in
+// the same way as Signer.WithSignatureEncoding().
+//
+// Do not use SignatureEncodingRS with jwx, which expects crypto.Signer
+// implementations to return ASN.1 DER.
+func (cs *MultiRegion) WithSignatureEncoding(v SignatureEncoding) *MultiRegion {
+	return &MultiRegion{
+		replicas:       cs.replicas,
+		state:          cs.state,
+		attemptTimeout: cs.attemptTimeout,
+		cache:          cs.cache,
+		ctx:            cs.ctx,
+		encoding:       v,
+		grantTokens:    cs.grantTokens,
+		limiter:        cs.limiter,
+		lowS:           cs.lowS,
+		mismatchHook:   cs.mismatchHook,
+		mt:             cs.mt,
+		regionHook:     cs.regionHook,
+		selfVerify:     cs.selfVerify,
+	}
+}
+
+// WithGrantTokens specifies the grant tokens to send along with each request
+// to KMS. Grant tokens allow you to use the permissions granted by a
+// grant before the grant has achieved eventual consistency.
+//
+// See https://docs.aws.amazon.com/kms/latest/developerguide/grant-manage.html#using-grant-token
+func (cs *MultiRegion) WithGrantTokens(v []string) *MultiRegion {
+	return &MultiRegion{
+		replicas:       cs.replicas,
+		state:          cs.state,
+		attemptTimeout: cs.attemptTimeout,
+		cache:          cs.cache,
+		ctx:            cs.ctx,
+		encoding:       cs.encoding,
+		grantTokens:    v,
+		limiter:        cs.limiter,
+		lowS:           cs.lowS,
+		mismatchHook:   cs.mismatchHook,
+		mt:             cs.mt,
+		regionHook:     cs.regionHook,
+		selfVerify:     cs.selfVerify,
+	}
+}
+
+// WithLimiter specifies the Limiter that limits the rate of Sign() requests
+// sent to KMS. Each replica has a bucket of its own, as KMS quotas
+// apply to each region separately.
+//
+// Requests that are still throttled after the retries of the Limiter are
+// sent to the next replica.
+func (cs *MultiRegion) WithLimiter(v *Limiter) *MultiRegion {
+	return &MultiRegion{
+		replicas:       cs.replicas,
+		state:          cs.state,
+		attemptTimeout: cs.attemptTimeout,
+		cache:          cs.cache,
+		ctx:            cs.ctx,
+		encoding:       cs.encoding,
+		grantTokens:    cs.grantTokens,
+		limiter:        v,
+		lowS:           cs.lowS,
+		mismatchHook:   cs.mismatchHook,
+		mt:             cs.mt,
+		regionHook:     cs.regionHook,
+		selfVerify:     cs.selfVerify,
+	}
+}
+
+// WithLowS specifies whether ECDSA signatures are normalized to low-S
+// form, in the same way as Signer.WithLowS().
+func (cs *MultiRegion) WithLowS(v bool) *MultiRegion {
+	return &MultiRegion{
+		replicas:       cs.replicas,
+		state:          cs.state,
+		attemptTimeout: cs.attemptTimeout,
+		cache:          cs.cache,
+		ctx:            cs.ctx,
+		encoding:       cs.encoding,
+		grantTokens:    cs.grantTokens,
+		limiter:        cs.limiter,
+		lowS:           v,
+		mismatchHook:   cs.mismatchHook,
+		mt:             cs.mt,
+		regionHook:     cs.regionHook,
+		selfVerify:     cs.selfVerify,
+	}
+}
+
+// WithMismatchHook specifies a function that is called with the ARN of
+// the replica and the error whenever self-verification (see
+// WithSelfVerify()) fails.
+func (cs *MultiRegion) WithMismatchHook(v func(string, error)) *MultiRegion {
+	return &MultiRegion{
+		replicas:       cs.replicas,
+		state:          cs.state,
+		attemptTimeout: cs.attemptTimeout,
+		cache:          cs.cache,
+		ctx:            cs.ctx,
+		encoding:       cs.encoding,
+		grantTokens:    cs.grantTokens,
+		limiter:        cs.limiter,
+		lowS:           cs.lowS,
+		mismatchHook:   v,
+		mt:             cs.mt,
+		regionHook:     cs.regionHook,
+		selfVerify:     cs.selfVerify,
+	}
+}
+
+// WithMessageType specifies the message type to use in SignMessage(), in
+// the same way as Signer.WithMessageType().
+func (cs *MultiRegion) WithMessageType(v types.MessageType) *MultiRegion {
+	return &MultiRegion{
+		replicas:       cs.replicas,
+		state:          cs.state,
+		attemptTimeout: cs.attemptTimeout,
+		cache:          cs.cache,
+		ctx:            cs.ctx,
+		encoding:       cs.encoding,
+		grantTokens:    cs.grantTokens,
+		limiter:        cs.limiter,
+		lowS:           cs.lowS,
+		mismatchHook:   cs.mismatchHook,
+		mt:             v,
+		regionHook:     cs.regionHook,
+		selfVerify:     cs.selfVerify,
+	}
+}
+
+// WithRegionHook specifies a function that is called with the region
+// of the replica that served each signature. Use it to record metrics,
+// or to find out when failovers happen.
+func (cs *MultiRegion) WithRegionHook(v func(string)) *MultiRegion {
+	return &MultiRegion{
+		replicas:       cs.replicas,
+		state:          cs.state,
+		attemptTimeout: cs.attemptTimeout,
+		cache:          cs.cache,
+		ctx:            cs.ctx,
+		encoding:       cs.encoding,
+		grantTokens:    cs.grantTokens,
+		limiter:        cs.limiter,
+		lowS:           cs.lowS,
+		mismatchHook:   cs.mismatchHook,
+		mt:             cs.mt,
+		regionHook:     v,
+		selfVerify:     cs.selfVerify,
+	}
+}
+
+// WithSelfVerify specifies whether each signature returned by KMS is
+// verified locally before it is returned, in the same way as
+// Signer.WithSelfVerify(). The public key that all the replicas agreed
+// on is used, so a replica that signs with a different key is caught
+// even after it has been checked.
+func (cs *MultiRegion) WithSelfVerify(v bool) *MultiRegion {
+	return &MultiRegion{
+		replicas:       cs.replicas,
+		state:          cs.state,
+		attemptTimeout: cs.attemptTimeout,
+		cache:          cs.cache,
+		ctx:            cs.ctx,
+		encoding:       cs.encoding,
+		grantTokens:    cs.grantTokens,
+		limiter:        cs.limiter,
+		lowS:           cs.lowS,
+		mismatchHook:   cs.mismatchHook,
+		mt:             cs.mt,
+		regionHook:     cs.regionHook,
+		selfVerify:     v,
+	}
+}
@@ -0,0 +1,390 @@
+package awssigner_test
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+	"github.com/aws/smithy-go"
+	awssigner "github.com/jwx-go/crypto-signer/v2/aws"
+	"github.com/jwx-go/crypto-signer/v2/aws/kmstest"
+)
+
+// faultyClient wraps the in-memory fake KMS, and fails Sign and
+// GetPublicKey requests with err while it is set. If hang is true,
+// requests block until the context is done instead.
+type faultyClient struct {
+	*kmstest.KMS
+	mu   sync.Mutex
+	err  error
+	hang bool
+}
+
+func (c *faultyClient) setFault(err error, hang bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.err = err
+	c.hang = hang
+}
+
+func (c *faultyClient) fault(ctx context.Context) error {
+	c.mu.Lock()
+	err, hang := c.err, c.hang
+	c.mu.Unlock()
+	if hang {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	return err
+}
+
+func (c *faultyClient) Sign(ctx context.Context, in *kms.SignInput, options ...func(*kms.Options)) (*kms.SignOutput, error) {
+	if err := c.fault(ctx); err != nil {
+		return nil, err
+	}
+	return c.KMS.Sign(ctx, in, options...)
+}
+
+func (c *faultyClient) GetPublicKey(ctx context.Context, in *kms.GetPublicKeyInput, options ...func(*kms.Options)) (*kms.GetPublicKeyOutput, error) {
+	if err := c.fault(ctx); err != nil {
+		return nil, err
+	}
+	return c.KMS.GetPublicKey(ctx, in, options...)
+}
+
+// newMultiRegionKey creates a multi-Region key in the first region, and
+// replicates it to the other regions
+func newMultiRegionKey(t *testing.T, spec types.KeySpec, regions ...string) ([]*faultyClient, []awssigner.Replica) {
+	t.Helper()
+
+	var clients []*faultyClient
+	var replicas []awssigner.Replica
+	var keyID string
+	for i, region := range regions {
+		client := &faultyClient{KMS: kmstest.NewInRegion(region)}
+		var metadata *types.KeyMetadata
+		if i == 0 {
+			output, err := client.CreateKey(context.Background(), &kms.CreateKeyInput{
+				KeySpec:     spec,
+				KeyUsage:    types.KeyUsageTypeSignVerify,
+				MultiRegion: aws.Bool(true),
+			})
+			if err != nil {
+				t.Fatalf("failed to create key: %s", err)
+			}
+			metadata = output.KeyMetadata
+			keyID = aws.ToString(metadata.KeyId)
+		} else {
+			var err error
+			metadata, err = clients[0].Replicate(keyID, client.KMS)
+			if err != nil {
+				t.Fatalf("failed to replicate key: %s", err)
+			}
+		}
+		clients = append(clients, client)
+		replicas = append(replicas, awssigner.Replica{Client: client, ARN: aws.ToString(metadata.Arn)})
+	}
+	return clients, replicas
+}
+
+func TestMultiRegion(t *testing.T) {
+	digest := sha256.Sum256([]byte("obla-di-obla-da"))
+
+	verify := func(t *testing.T, sv crypto.Signer, signed []byte) {
+		t.Helper()
+		pubkey, ok := sv.Public().(*ecdsa.PublicKey)
+		if !ok {
+			t.Fatalf("expected *ecdsa.PublicKey, got %T", sv.Public())
+		}
+		if !ecdsa.VerifyASN1(pubkey, digest[:], signed) {
+			t.Fatalf("failed to verify signature")
+		}
+	}
+
+	testcases := []struct {
+		Name     string
+		Err      error
+		Hang     bool
+		Failover bool
+	}{
+		{Name: "available"},
+		{
+			Name:     "throttled",
+			Err:      &smithy.GenericAPIError{Code: `ThrottlingException`, Message: `Rate exceeded`},
+			Failover: true,
+		},
+		{
+			Name:     "server error",
+			Err:      &types.KMSInternalException{Message: aws.String(`internal error`)},
+			Failover: true,
+		},
+		{
+			Name:     "timeout",
+			Hang:     true,
+			Failover: true,
+		},
+		{
+			Name: "disabled",
+			Err:  &types.DisabledException{Message: aws.String(`key is disabled`)},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.Name, func(t *testing.T) {
+			clients, replicas := newMultiRegionKey(t, types.KeySpecEccNistP256, `us-east-1`, `us-west-2`)
+
+			sv, err := awssigner.NewMultiRegion(replicas...)
+			if err != nil {
+				t.Fatalf("failed to create signer: %s", err)
+			}
+			var reported []string
+			sv = sv.
+				WithAttemptTimeout(100 * time.Millisecond).
+				WithRegionHook(func(region string) { reported = append(reported, region) })
+
+			// the replicas are compared before the fault is injected
+			if err := sv.CheckReplicas(context.Background()); err != nil {
+				t.Fatalf("failed to check replicas: %s", err)
+			}
+			clients[0].setFault(tc.Err, tc.Hang)
+
+			signed, region, err := sv.SignWithRegion(digest[:], crypto.SHA256)
+			if tc.Err != nil && !tc.Failover {
+				if !errors.Is(err, tc.Err) {
+					t.Fatalf("expected %v, got %v", tc.Err, err)
+				}
+				if len(reported) != 0 {
+					t.Fatalf("expected no region to be reported, got %v", reported)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to sign: %s", err)
+			}
+
+			expected := `us-east-1`
+			if tc.Failover {
+				expected = `us-west-2`
+			}
+			if region != expected {
+				t.Fatalf("expected signature from %q, got %q", expected, region)
+			}
+			if len(reported) != 1 || reported[0] != expected {
+				t.Fatalf("expected %q to be reported, got %v", expected, reported)
+			}
+			verify(t, sv, signed)
+		})
+	}
+
+	t.Run("all regions unavailable", func(t *testing.T) {
+		clients, replicas := newMultiRegionKey(t, types.KeySpecEccNistP256, `us-east-1`, `us-west-2`)
+		sv, err := awssigner.NewMultiRegion(replicas...)
+		if err != nil {
+			t.Fatalf("failed to create signer: %s", err)
+		}
+
+		throttled := &smithy.GenericAPIError{Code: `ThrottlingException`, Message: `Rate exceeded`}
+		for _, client := range clients {
+			client.setFault(throttled, false)
+		}
+		if _, err := sv.Sign(rand.Reader, digest[:], crypto.SHA256); !errors.Is(err, throttled) {
+			t.Fatalf("expected %v, got %v", throttled, err)
+		}
+	})
+
+	t.Run("unavailable when checking replicas", func(t *testing.T) {
+		clients, replicas := newMultiRegionKey(t, types.KeySpecEccNistP256, `us-east-1`, `us-west-2`)
+		sv, err := awssigner.NewMultiRegion(replicas...)
+		if err != nil {
+			t.Fatalf("failed to create signer: %s", err)
+		}
+
+		clients[0].setFault(&types.KMSInternalException{Message: aws.String(`internal error`)}, false)
+		if err := sv.CheckReplicas(context.Background()); err == nil {
+			t.Fatalf("expected CheckReplicas to fail")
+		}
+		signed, region, err := sv.SignWithRegion(digest[:], crypto.SHA256)
+		if err != nil {
+			t.Fatalf("failed to sign: %s", err)
+		}
+		if region != `us-west-2` {
+			t.Fatalf("expected signature from us-west-2, got %q", region)
+		}
+		verify(t, sv, signed)
+
+		// once the region is back, it is checked and used again
+		clients[0].setFault(nil, false)
+		if _, region, err = sv.SignWithRegion(digest[:], crypto.SHA256); err != nil || region != `us-east-1` {
+			t.Fatalf("expected signature from us-east-1, got %q (%v)", region, err)
+		}
+	})
+
+	t.Run("slow replica does not block others", func(t *testing.T) {
+		clients, replicas := newMultiRegionKey(t, types.KeySpecEccNistP256, `us-east-1`, `us-west-2`)
+		sv, err := awssigner.NewMultiRegion(replicas...)
+		if err != nil {
+			t.Fatalf("failed to create signer: %s", err)
+		}
+		clients[0].setFault(nil, true)
+
+		// the replicas are checked without an attempt timeout, so the
+		// request to us-east-1 hangs until ctx is done
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			_ = sv.CheckReplicas(ctx)
+		}()
+		time.Sleep(50 * time.Millisecond)
+
+		start := time.Now()
+		_, region, err := sv.WithAttemptTimeout(100*time.Millisecond).SignWithRegion(digest[:], crypto.SHA256)
+		if err != nil {
+			t.Fatalf("failed to sign: %s", err)
+		}
+		if region != `us-west-2` {
+			t.Fatalf("expected signature from us-west-2, got %q", region)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Fatalf("expected the signature within the attempt timeout, took %s", elapsed)
+		}
+
+		cancel()
+		<-done
+	})
+
+	t.Run("mismatched public keys", func(t *testing.T) {
+		_, replicas := newMultiRegionKey(t, types.KeySpecEccNistP256, `us-east-1`)
+		_, others := newMultiRegionKey(t, types.KeySpecEccNistP256, `us-west-2`)
+
+		sv, err := awssigner.NewMultiRegion(append(replicas, others...)...)
+		if err != nil {
+			t.Fatalf("failed to create signer: %s", err)
+		}
+		if err := sv.CheckReplicas(context.Background()); err == nil {
+			t.Fatalf("expected CheckReplicas to fail")
+		}
+		if _, err := sv.Sign(rand.Reader, digest[:], crypto.SHA256); err == nil {
+			t.Fatalf("expected Sign to fail")
+		}
+	})
+
+	t.Run("invalid ARN", func(t *testing.T) {
+		if _, err := awssigner.NewMultiRegion(awssigner.Replica{Client: kmstest.New(), ARN: `mrk-1234`}); err == nil {
+			t.Fatalf("expected NewMultiRegion to fail")
+		}
+	})
+}
+
+func TestMultiRegionOptions(t *testing.T) {
+	digest := sha256.Sum256([]byte("obla-di-obla-da"))
+
+	t.Run("SignMessage", func(t *testing.T) {
+		clients, replicas := newMultiRegionKey(t, types.KeySpecEccNistP256, `us-east-1`, `eu-west-1`)
+		sv, err := awssigner.NewMultiRegion(replicas...)
+		if err != nil {
+			t.Fatalf("failed to create signer: %s", err)
+		}
+
+		clients[0].setFault(&types.KMSInternalException{Message: aws.String(`internal error`)}, false)
+		signed, err := sv.SignMessage(rand.Reader, []byte("obla-di-obla-da"), crypto.SHA256)
+		if err != nil {
+			t.Fatalf("failed to sign: %s", err)
+		}
+		if !ecdsa.VerifyASN1(sv.Public().(*ecdsa.PublicKey), digest[:], signed) {
+			t.Fatalf("failed to verify signature")
+		}
+	})
+	t.Run("self-verify", func(t *testing.T) {
+		_, replicas := newMultiRegionKey(t, types.KeySpecEccNistP256, `us-east-1`, `eu-west-1`)
+		replicas[1].Client = &corruptingClient{KMS: replicas[1].Client.(*faultyClient).KMS, corrupt: true}
+
+		var mismatches []string
+		sv, err := awssigner.NewMultiRegion(replicas[1], replicas[0])
+		if err != nil {
+			t.Fatalf("failed to create signer: %s", err)
+		}
+		sv = sv.WithSelfVerify(true).
+			WithMismatchHook(func(arn string, _ error) {
+				mismatches = append(mismatches, arn)
+			})
+
+		// a mismatch is not a reason to fail over
+		if _, err := sv.Sign(rand.Reader, digest[:], crypto.SHA256); !errors.Is(err, awssigner.ErrSignatureMismatch) {
+			t.Fatalf("expected ErrSignatureMismatch, got %v", err)
+		}
+		if len(mismatches) != 1 || mismatches[0] != replicas[1].ARN {
+			t.Fatalf("expected the hook to be called with %q, got %v", replicas[1].ARN, mismatches)
+		}
+	})
+	t.Run("signature encoding", func(t *testing.T) {
+		_, replicas := newMultiRegionKey(t, types.KeySpecEccNistP256, `us-east-1`, `eu-west-1`)
+		sv, err := awssigner.NewMultiRegion(replicas...)
+		if err != nil {
+			t.Fatalf("failed to create signer: %s", err)
+		}
+		sv = sv.WithSignatureEncoding(awssigner.SignatureEncodingRS).WithLowS(true)
+
+		signed, err := sv.Sign(rand.Reader, digest[:], crypto.SHA256)
+		if err != nil {
+			t.Fatalf("failed to sign: %s", err)
+		}
+		if len(signed) != 64 {
+			t.Fatalf("expected a 64 byte r||s signature, got %d bytes", len(signed))
+		}
+	})
+	t.Run("limiter", func(t *testing.T) {
+		clients, replicas := newMultiRegionKey(t, types.KeySpecEccNistP256, `us-east-1`, `eu-west-1`)
+		limiter := awssigner.NewLimiter(0).WithMaxRetries(1)
+		sv, err := awssigner.NewMultiRegion(replicas...)
+		if err != nil {
+			t.Fatalf("failed to create signer: %s", err)
+		}
+		sv = sv.WithLimiter(limiter)
+
+		if _, err := sv.Sign(rand.Reader, digest[:], crypto.SHA256); err != nil {
+			t.Fatalf("failed to sign: %s", err)
+		}
+
+		// the retries of the Limiter are used up before failing over
+		clients[0].setFault(&smithy.GenericAPIError{Code: `ThrottlingException`, Message: `Rate exceeded`}, false)
+		_, region, err := sv.SignWithRegion(digest[:], crypto.SHA256)
+		if err != nil {
+			t.Fatalf("failed to sign: %s", err)
+		}
+		if region != `eu-west-1` {
+			t.Fatalf("expected eu-west-1, got %q", region)
+		}
+		stats := limiter.Stats()
+		if stats.Requests != 3 || stats.Retries != 1 || stats.Exhausted != 1 {
+			t.Fatalf("unexpected stats: %+v", stats)
+		}
+	})
+	t.Run("CheckAccess", func(t *testing.T) {
+		clients, replicas := newMultiRegionKey(t, types.KeySpecEccNistP256, `us-east-1`, `eu-west-1`)
+		sv, err := awssigner.NewMultiRegion(replicas...)
+		if err != nil {
+			t.Fatalf("failed to create signer: %s", err)
+		}
+		if err := sv.CheckAccess(context.Background()); err != nil {
+			t.Fatalf("failed to check access: %s", err)
+		}
+
+		if _, err := clients[1].DisableKey(context.Background(), &kms.DisableKeyInput{KeyId: aws.String(replicas[1].ARN)}); err != nil {
+			t.Fatalf("failed to disable key: %s", err)
+		}
+		if err := sv.CheckAccess(context.Background()); !errors.Is(err, awssigner.ErrKeyDisabled) {
+			t.Fatalf("expected ErrKeyDisabled, got %v", err)
+		}
+	})
+}
@@ -1,32 +1,45 @@
 package awssigner
 
 import (
+	"bytes"
 	"context"
 	"crypto"
-	"crypto/x509"
+	"crypto/rsa"
 	"fmt"
 	"io"
+	"time"
 
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/service/kms"
 	"github.com/aws/aws-sdk-go-v2/service/kms/types"
 )
 
 type RSA struct {
-	alg    types.SigningAlgorithmSpec
-	client *kms.Client
-	ctx    context.Context
-	kid    string
+	alg          types.SigningAlgorithmSpec
+	aliasRefresh time.Duration
+	checkState   bool
+	client       Client
+	cache        Cache
+	ctx          context.Context
+	grantTokens  []string
+	kid          string
+	limiter      *Limiter
+	memo         *keyMemo
+	mt           types.MessageType
+	mismatchHook func(string, error)
+	selfVerify   bool
 }
 
 // NewRSA creates a new RSA object. This object isnot complete by itself -- it
-// needs to be setup with the algorithm name to use (see
-// https://pkg.go.dev/github.com/aws/aws-sdk-go-v2/service/kms/types#SigningAlgorithmSpec),
-// a key ID, and a context.Context object to use while the AWS SDK makes network
-// requests.
-func NewRSA(client *kms.Client) *RSA {
+// needs to be setup with a key ID, and a context.Context object to use while
+// the AWS SDK makes network requests.
+//
+// The algorithm name to use (see
+// https://pkg.go.dev/github.com/aws/aws-sdk-go-v2/service/kms/types#SigningAlgorithmSpec)
+// is derived from the crypto.SignerOpts passed to Sign(), but it can also
+// be fixed using WithAlgorithm().
+func NewRSA(client Client) *RSA {
 	return &RSA{
 		client: client,
+		memo:   &keyMemo{},
 	}
 }
 
@@ -38,32 +51,160 @@ func (sv *RSA) getContext() context.Context {
 	return ctx
 }
 
+func (sv *RSA) key() kmsKey {
+	return kmsKey{
+		aliasRefresh: sv.aliasRefresh,
+		cache:        sv.cache,
+		client:       sv.client,
+		grantTokens:  sv.grantTokens,
+		kid:          sv.kid,
+		memo:         sv.memo,
+	}
+}
+
 // Sign generates a signature from the given digest.
+//
+// The signing algorithm is derived from opts.HashFunc() and whether opts
+// is an *rsa.PSSOptions. If the algorithm was also explicitly specified
+// via WithAlgorithm(), the two must agree. If opts does not specify a
+// hash function, the algorithm specified via WithAlgorithm() is used.
+//
+// AWS KMS only supports PSS salt lengths equal to the hash length, so
+// opts.SaltLength must be rsa.PSSSaltLengthEqualsHash or the hash length.
 func (sv *RSA) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
-	if sv.alg == "" {
-		return nil, fmt.Errorf(`aws.RSA.Sign() requires the types.SigningAlgorithmSpec`)
-	}
 	if sv.kid == "" {
 		return nil, fmt.Errorf(`aws.RSA.Sign() requires the key ID`)
 	}
 
+	alg, err := chooseSigningAlgorithm(sv.alg, rsaSigningAlgorithm, digest, opts)
+	if err != nil {
+		return nil, fmt.Errorf(`aws.RSA.Sign() failed to determine signing algorithm: %w`, err)
+	}
+
 	// sv.ctx is NOT required, but we will use context.Background here
 	// which means there will not be a (clean) way to interrupt this
 	// operation
 	ctx := sv.getContext()
 
-	input := kms.SignInput{
-		KeyId:            aws.String(sv.kid),
-		Message:          digest,
-		MessageType:      types.MessageTypeDigest,
-		SigningAlgorithm: sv.alg,
+	kid, err := sv.key().resolve(ctx)
+	if err != nil {
+		return nil, fmt.Errorf(`aws.RSA.Sign() %w`, err)
+	}
+
+	if sv.checkState {
+		if err := kmsCheckKeyState(ctx, sv.client, kid, sv.grantTokens, types.KeyUsageTypeSignVerify); err != nil {
+			return nil, fmt.Errorf(`aws.RSA.Sign() refused to use the key: %w`, err)
+		}
 	}
-	signed, err := sv.client.Sign(ctx, &input)
+
+	signed, err := kmsSignLimited(ctx, sv.limiter, sv.client, kid, sv.grantTokens, digest, types.MessageTypeDigest, alg)
 	if err != nil {
-		return nil, fmt.Errorf(`failed to sign via KMS: %w`, err)
+		return nil, err
+	}
+	if sv.selfVerify {
+		if err := sv.key().selfVerify(ctx, kid, sv.mismatchHook, digest, types.MessageTypeDigest, alg, signed); err != nil {
+			return nil, fmt.Errorf(`aws.RSA.Sign() %w`, err)
+		}
 	}
+	return signed, nil
+}
 
-	return signed.Signature, nil
+// SignMessage generates a signature from the given message, and
+// implements crypto.MessageSigner.
+//
+// The signing algorithm is chosen in the same way as Sign(). Messages up
+// to 4096 bytes are sent to KMS as is, and larger messages are hashed
+// locally, unless specified otherwise using WithMessageType().
+func (sv *RSA) SignMessage(_ io.Reader, message []byte, opts crypto.SignerOpts) ([]byte, error) {
+	return sv.SignStream(bytes.NewReader(message), opts)
+}
+
+// SignStream works like SignMessage(), but reads the message from r.
+// Messages that are hashed locally are hashed as they are read, so they
+// are never held in memory as a whole.
+func (sv *RSA) SignStream(r io.Reader, opts crypto.SignerOpts) ([]byte, error) {
+	if sv.kid == "" {
+		return nil, fmt.Errorf(`aws.RSA.SignStream() requires the key ID`)
+	}
+
+	alg, err := selectSigningAlgorithm(sv.alg, rsaSigningAlgorithm, opts)
+	if err != nil {
+		return nil, fmt.Errorf(`aws.RSA.SignStream() failed to determine signing algorithm: %w`, err)
+	}
+
+	message, mt, err := messageForSigning(r, signingAlgorithmHash(alg), sv.mt)
+	if err != nil {
+		return nil, fmt.Errorf(`aws.RSA.SignStream() %w`, err)
+	}
+
+	// sv.ctx is NOT required, but we will use context.Background here
+	// which means there will not be a (clean) way to interrupt this
+	// operation
+	ctx := sv.getContext()
+
+	kid, err := sv.key().resolve(ctx)
+	if err != nil {
+		return nil, fmt.Errorf(`aws.RSA.SignStream() %w`, err)
+	}
+
+	if sv.checkState {
+		if err := kmsCheckKeyState(ctx, sv.client, kid, sv.grantTokens, types.KeyUsageTypeSignVerify); err != nil {
+			return nil, fmt.Errorf(`aws.RSA.SignStream() refused to use the key: %w`, err)
+		}
+	}
+
+	signed, err := kmsSignLimited(ctx, sv.limiter, sv.client, kid, sv.grantTokens, message, mt, alg)
+	if err != nil {
+		return nil, err
+	}
+	if sv.selfVerify {
+		if err := sv.key().selfVerify(ctx, kid, sv.mismatchHook, message, mt, alg, signed); err != nil {
+			return nil, fmt.Errorf(`aws.RSA.SignStream() %w`, err)
+		}
+	}
+	return signed, nil
+}
+
+// CheckAccess makes sure that the key can be used for signing, by calling
+// the KMS Sign API with DryRun enabled. Use it at startup to find out
+// about missing permissions or disabled keys before the first signature
+// is actually required.
+func (sv *RSA) CheckAccess(ctx context.Context) error {
+	if sv.kid == "" {
+		return fmt.Errorf(`aws.RSA.CheckAccess() requires the key ID`)
+	}
+
+	// all RSA keys support RSASSA_PKCS1_V1_5_SHA_256
+	alg := sv.alg
+	if alg == "" {
+		alg = types.SigningAlgorithmSpecRsassaPkcs1V15Sha256
+	}
+
+	if err := sv.key().checkSign(ctx, alg); err != nil {
+		return fmt.Errorf(`aws.RSA.CheckAccess() %w`, err)
+	}
+	return nil
+}
+
+// KeyARN returns the ARN of the key. If the key ID is an alias, it is
+// resolved using KMS DescribeKey. When a Cache is provided, the result
+// is cached, and the alias is resolved again after the interval
+// specified via WithAliasRefreshInterval() has elapsed, so that the ARN
+// follows the key that the alias points to.
+//
+// Use it as the "kid" header of JWS messages, so that verifiers can tell
+// the keys apart when an alias is rotated to a new key.
+func (sv *RSA) KeyARN() (string, error) {
+	if sv.kid == "" {
+		return "", fmt.Errorf(`aws.RSA.KeyARN() requires the key ID`)
+	}
+
+	// sv.ctx is NOT required, but we will use context.Background here
+	// which means there will not be a (clean) way to interrupt this
+	// operation
+	ctx := sv.getContext()
+
+	return sv.key().arn(ctx)
 }
 
 // Public returns the corresponding public key.
@@ -88,22 +229,14 @@ func (sv *RSA) GetPublicKey() (crypto.PublicKey, error) {
 	// operation
 	ctx := sv.getContext()
 
-	input := kms.GetPublicKeyInput{
-		KeyId: aws.String(sv.kid),
-	}
-	output, err := sv.client.GetPublicKey(ctx, &input)
+	info, err := sv.key().info(ctx, types.KeyUsageTypeSignVerify)
 	if err != nil {
-		return nil, fmt.Errorf(`failed to get public key from KMS: %w`, err)
+		return nil, fmt.Errorf(`aws.RSA.GetPublicKey() %w`, err)
 	}
 
-	if output.KeyUsage != types.KeyUsageTypeSignVerify {
-		return nil, fmt.Errorf(`invalid key usage. expected SIGN_VERIFY, got %q`, output.KeyUsage)
+	pubkey, ok := info.publicKey.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf(`aws.RSA.GetPublicKey() expected *rsa.PublicKey, got %T`, info.publicKey)
 	}
-
-	key, err := x509.ParsePKIXPublicKey(output.PublicKey)
-	if err != nil {
-		return nil, fmt.Errorf(`failed to parse key: %w`, err)
-	}
-
-	return key, nil
+	return pubkey, nil
 }
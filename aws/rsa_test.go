@@ -0,0 +1,107 @@
+package awssigner_test
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+	awssigner "github.com/jwx-go/crypto-signer/v2/aws"
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jws"
+)
+
+func TestRSASigningAlgorithm(t *testing.T) {
+	client, kid := newTestKey(t, types.KeySpecRsa2048)
+
+	sv := awssigner.NewRSA(client).
+		WithKeyID(kid)
+
+	t.Run("derived from opts", func(t *testing.T) {
+		testcases := map[jwa.SignatureAlgorithm]types.SigningAlgorithmSpec{
+			jwa.RS256: types.SigningAlgorithmSpecRsassaPkcs1V15Sha256,
+			jwa.RS384: types.SigningAlgorithmSpecRsassaPkcs1V15Sha384,
+			jwa.RS512: types.SigningAlgorithmSpecRsassaPkcs1V15Sha512,
+			jwa.PS256: types.SigningAlgorithmSpecRsassaPssSha256,
+			jwa.PS384: types.SigningAlgorithmSpecRsassaPssSha384,
+			jwa.PS512: types.SigningAlgorithmSpecRsassaPssSha512,
+		}
+		payload := []byte("obla-di-obla-da")
+		for jwsalg, kmsalg := range testcases {
+			t.Run(jwsalg.String(), func(t *testing.T) {
+				signed, err := jws.Sign(payload, jws.WithKey(jwsalg, sv))
+				if err != nil {
+					t.Fatalf("failed to sign: %s", err)
+				}
+				if client.lastAlgorithm != kmsalg {
+					t.Fatalf("expected %q, got %q", kmsalg, client.lastAlgorithm)
+				}
+				verified, err := jws.Verify(signed, jws.WithKey(jwsalg, sv))
+				if err != nil {
+					t.Fatalf("failed to verify: %s", err)
+				}
+				if !bytes.Equal(payload, verified) {
+					t.Fatalf("payload and verified does not match")
+				}
+			})
+		}
+	})
+
+	digest := sha256.Sum256([]byte("obla-di-obla-da"))
+	t.Run("explicit algorithm", func(t *testing.T) {
+		fixed := sv.WithAlgorithm(types.SigningAlgorithmSpecRsassaPssSha256)
+		if _, err := fixed.Sign(rand.Reader, digest[:], nil); err != nil {
+			t.Fatalf("failed to sign: %s", err)
+		}
+		if client.lastAlgorithm != types.SigningAlgorithmSpecRsassaPssSha256 {
+			t.Fatalf("expected %q, got %q", types.SigningAlgorithmSpecRsassaPssSha256, client.lastAlgorithm)
+		}
+		if _, err := fixed.Sign(rand.Reader, digest[:], &rsa.PSSOptions{Hash: crypto.SHA256, SaltLength: rsa.PSSSaltLengthEqualsHash}); err != nil {
+			t.Fatalf("failed to sign: %s", err)
+		}
+	})
+	t.Run("conflicting algorithm", func(t *testing.T) {
+		fixed := sv.WithAlgorithm(types.SigningAlgorithmSpecRsassaPssSha256)
+		if _, err := fixed.Sign(rand.Reader, digest[:], crypto.SHA256); err == nil {
+			t.Fatalf("conflicting algorithms should fail")
+		}
+	})
+	t.Run("unsupported salt length", func(t *testing.T) {
+		for _, saltLength := range []int{rsa.PSSSaltLengthAuto, 20} {
+			if _, err := sv.Sign(rand.Reader, digest[:], &rsa.PSSOptions{Hash: crypto.SHA256, SaltLength: saltLength}); err == nil {
+				t.Fatalf("salt length %d should fail", saltLength)
+			}
+		}
+	})
+	t.Run("digest length mismatch", func(t *testing.T) {
+		if _, err := sv.Sign(rand.Reader, digest[:], crypto.SHA384); err == nil {
+			t.Fatalf("digest length mismatch should fail")
+		}
+	})
+	t.Run("no algorithm", func(t *testing.T) {
+		if _, err := sv.Sign(rand.Reader, digest[:], nil); err == nil {
+			t.Fatalf("missing algorithm should fail")
+		}
+	})
+}
+
+func TestRSASelfVerifyCache(t *testing.T) {
+	client, kid := newTestKey(t, types.KeySpecRsa2048)
+	sv := awssigner.NewRSA(client).
+		WithKeyID(kid).
+		WithCache(NewDumbCache()).
+		WithSelfVerify(true)
+
+	digest := sha256.Sum256([]byte("obla-di-obla-da"))
+	for range 3 {
+		if _, err := sv.Sign(rand.Reader, digest[:], crypto.SHA256); err != nil {
+			t.Fatalf("failed to sign: %s", err)
+		}
+	}
+	if client.publicKeyCalls != 1 {
+		t.Fatalf("expected the public key to be retrieved once, got %d", client.publicKeyCalls)
+	}
+}
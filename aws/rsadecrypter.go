@@ -0,0 +1,152 @@
+package awssigner
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+)
+
+// RSADecrypter is a crypto.Decrypter for AWS KMS RSA keys with the
+// ENCRYPT_DECRYPT key usage. The private key never leaves KMS: the
+// ciphertext is sent to KMS, and the plaintext is returned.
+//
+// AWS KMS only supports RSAES-OAEP with SHA-1 or SHA-256, so the
+// options passed to Decrypt() must be *rsa.OAEPOptions using either of
+// these hash functions, with no label.
+type RSADecrypter struct {
+	alg          types.EncryptionAlgorithmSpec
+	aliasRefresh time.Duration
+	cache        Cache
+	client       Client
+	ctx          context.Context
+	grantTokens  []string
+	kid          string
+}
+
+// NewRSADecrypter creates a new RSADecrypter object. This object isnot complete by itself -- it
+// needs to be setup with a key ID, and a context.Context object to use while
+// the AWS SDK makes network requests.
+//
+// The algorithm name to use (see
+// https://pkg.go.dev/github.com/aws/aws-sdk-go-v2/service/kms/types#EncryptionAlgorithmSpec)
+// is derived from the crypto.DecrypterOpts passed to Decrypt(), but it can also
+// be fixed using WithAlgorithm().
+func NewRSADecrypter(client Client) *RSADecrypter {
+	return &RSADecrypter{
+		client: client,
+	}
+}
+
+func (sv *RSADecrypter) getContext() context.Context {
+	ctx := sv.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return ctx
+}
+
+func (sv *RSADecrypter) key() kmsKey {
+	return kmsKey{
+		aliasRefresh: sv.aliasRefresh,
+		cache:        sv.cache,
+		client:       sv.client,
+		grantTokens:  sv.grantTokens,
+		kid:          sv.kid,
+	}
+}
+
+// Decrypt decrypts the given ciphertext using the KMS Decrypt API.
+//
+// The encryption algorithm is derived from opts, which must be either
+// nil or an *rsa.OAEPOptions. If the algorithm was also explicitly
+// specified via WithAlgorithm(), the two must agree. If opts is nil,
+// the algorithm specified via WithAlgorithm() is used.
+func (sv *RSADecrypter) Decrypt(_ io.Reader, ciphertext []byte, opts crypto.DecrypterOpts) ([]byte, error) {
+	if sv.kid == "" {
+		return nil, fmt.Errorf(`aws.RSADecrypter.Decrypt() requires the key ID`)
+	}
+
+	alg, err := rsaEncryptionAlgorithm(opts)
+	if err != nil {
+		return nil, fmt.Errorf(`aws.RSADecrypter.Decrypt() failed to determine encryption algorithm: %w`, err)
+	}
+	if alg == "" {
+		alg = sv.alg
+	} else if sv.alg != "" && sv.alg != alg {
+		return nil, fmt.Errorf(`aws.RSADecrypter.Decrypt() failed to determine encryption algorithm: encryption algorithm %q was explicitly configured, but opts requires %q`, sv.alg, alg)
+	}
+	if alg == "" {
+		return nil, fmt.Errorf(`aws.RSADecrypter.Decrypt() requires either the types.EncryptionAlgorithmSpec or *rsa.OAEPOptions`)
+	}
+
+	// sv.ctx is NOT required, but we will use context.Background here
+	// which means there will not be a (clean) way to interrupt this
+	// operation
+	ctx := sv.getContext()
+
+	kid, err := sv.key().resolve(ctx)
+	if err != nil {
+		return nil, fmt.Errorf(`aws.RSADecrypter.Decrypt() %w`, err)
+	}
+
+	return kmsDecrypt(ctx, sv.client, kid, sv.grantTokens, ciphertext, alg)
+}
+
+// KeyARN returns the ARN of the key, resolving the alias (if any) in the
+// same way as RSA.KeyARN().
+//
+// Publish it as the "kid" of the public key (e.g. in a JWKS), so that
+// senders put it in the "kid" header of the JWE messages that they
+// encrypt to this key. The header then tells which key is required to
+// decrypt a message, even after the alias has been pointed to a new key.
+func (sv *RSADecrypter) KeyARN() (string, error) {
+	if sv.kid == "" {
+		return "", fmt.Errorf(`aws.RSADecrypter.KeyARN() requires the key ID`)
+	}
+
+	// sv.ctx is NOT required, but we will use context.Background here
+	// which means there will not be a (clean) way to interrupt this
+	// operation
+	ctx := sv.getContext()
+
+	return sv.key().arn(ctx)
+}
+
+// Public returns the corresponding public key.
+//
+// Because the crypto.Decrypter API does not allow for an error to be returned,
+// the return value from this function cannot describe what kind of error
+// occurred.
+func (sv *RSADecrypter) Public() crypto.PublicKey {
+	pubkey, _ := sv.GetPublicKey()
+	return pubkey
+}
+
+// This method is an escape hatch for those cases where the user needs
+// to debug what went wrong during the GetPublicKey operation.
+func (sv *RSADecrypter) GetPublicKey() (crypto.PublicKey, error) {
+	if sv.kid == "" {
+		return nil, fmt.Errorf(`aws.RSADecrypter requires the key ID`)
+	}
+
+	// sv.ctx is NOT required, but we will use context.Background here
+	// which means there will not be a (clean) way to interrupt this
+	// operation
+	ctx := sv.getContext()
+
+	info, err := sv.key().info(ctx, types.KeyUsageTypeEncryptDecrypt)
+	if err != nil {
+		return nil, fmt.Errorf(`aws.RSADecrypter.GetPublicKey() %w`, err)
+	}
+
+	pubkey, ok := info.publicKey.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf(`aws.RSADecrypter.GetPublicKey() expected *rsa.PublicKey, got %T`, info.publicKey)
+	}
+	return pubkey, nil
+}
@@ -0,0 +1,89 @@
+package awssigner_test
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+	awssigner "github.com/jwx-go/crypto-signer/v2/aws"
+	"github.com/jwx-go/crypto-signer/v2/aws/kmstest"
+)
+
+var _ crypto.Decrypter = &awssigner.RSADecrypter{}
+
+func TestRSADecrypter(t *testing.T) {
+	client := kmstest.New()
+	output, err := client.CreateKey(context.Background(), &kms.CreateKeyInput{
+		KeySpec:  types.KeySpecRsa2048,
+		KeyUsage: types.KeyUsageTypeEncryptDecrypt,
+	})
+	if err != nil {
+		t.Fatalf("failed to create key: %s", err)
+	}
+
+	dec := awssigner.NewRSADecrypter(client).
+		WithKeyID(aws.ToString(output.KeyMetadata.KeyId)).
+		WithCache(NewDumbCache())
+
+	pubkey, ok := dec.Public().(*rsa.PublicKey)
+	if !ok {
+		t.Fatalf("expected *rsa.PublicKey, got %T", dec.Public())
+	}
+
+	plaintext := []byte("obla-di-obla-da")
+	ciphertext, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, pubkey, plaintext, nil)
+	if err != nil {
+		t.Fatalf("failed to encrypt: %s", err)
+	}
+
+	t.Run("derived from opts", func(t *testing.T) {
+		decrypted, err := dec.Decrypt(rand.Reader, ciphertext, &rsa.OAEPOptions{Hash: crypto.SHA256})
+		if err != nil {
+			t.Fatalf("failed to decrypt: %s", err)
+		}
+		if !bytes.Equal(plaintext, decrypted) {
+			t.Fatalf("plaintext and decrypted does not match")
+		}
+	})
+	t.Run("explicit algorithm", func(t *testing.T) {
+		decrypted, err := dec.WithAlgorithm(types.EncryptionAlgorithmSpecRsaesOaepSha256).Decrypt(rand.Reader, ciphertext, nil)
+		if err != nil {
+			t.Fatalf("failed to decrypt: %s", err)
+		}
+		if !bytes.Equal(plaintext, decrypted) {
+			t.Fatalf("plaintext and decrypted does not match")
+		}
+	})
+	t.Run("wrong hash", func(t *testing.T) {
+		if _, err := dec.Decrypt(rand.Reader, ciphertext, &rsa.OAEPOptions{Hash: crypto.SHA1}); err == nil {
+			t.Fatalf("decrypting with the wrong hash should fail")
+		}
+	})
+
+	testcases := []struct {
+		Name      string
+		Decrypter *awssigner.RSADecrypter
+		Opts      crypto.DecrypterOpts
+	}{
+		{Name: "no algorithm", Decrypter: dec},
+		{Name: "conflicting algorithm", Decrypter: dec.WithAlgorithm(types.EncryptionAlgorithmSpecRsaesOaepSha1), Opts: &rsa.OAEPOptions{Hash: crypto.SHA256}},
+		{Name: "unsupported hash", Decrypter: dec, Opts: &rsa.OAEPOptions{Hash: crypto.SHA512}},
+		{Name: "label", Decrypter: dec, Opts: &rsa.OAEPOptions{Hash: crypto.SHA256, Label: []byte("label")}},
+		{Name: "MGF1 hash mismatch", Decrypter: dec, Opts: &rsa.OAEPOptions{Hash: crypto.SHA256, MGFHash: crypto.SHA1}},
+		{Name: "PKCS1v15", Decrypter: dec, Opts: &rsa.PKCS1v15DecryptOptions{}},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.Name, func(t *testing.T) {
+			if _, err := tc.Decrypter.Decrypt(rand.Reader, ciphertext, tc.Opts); err == nil {
+				t.Fatalf("decrypt should have failed")
+			}
+		})
+	}
+}
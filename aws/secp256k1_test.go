@@ -0,0 +1,52 @@
+package awssigner_test
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	awssigner "github.com/jwx-go/crypto-signer/v2/aws"
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jws"
+)
+
+func TestECDSASecp256k1(t *testing.T) {
+	client, kid := newTestKey(t, types.KeySpecEccSecgP256k1)
+
+	sv := awssigner.NewECDSA(client).
+		WithAlgorithm(types.SigningAlgorithmSpecEcdsaSha256).
+		WithKeyID(kid).
+		WithCache(NewDumbCache())
+
+	key, err := sv.GetPublicKey()
+	if err != nil {
+		t.Fatalf("failed to get public key: %s", err)
+	}
+	pubkey, ok := key.(*ecdsa.PublicKey)
+	if !ok {
+		t.Fatalf("expected *ecdsa.PublicKey, got %T", key)
+	}
+	if pubkey.Curve != secp256k1.S256() {
+		t.Fatalf("expected secp256k1 curve, got %s", pubkey.Curve.Params().Name)
+	}
+
+	payload := []byte("obla-di-obla-da")
+	signed, err := jws.Sign(payload, jws.WithKey(jwa.ES256K, sv))
+	if err != nil {
+		t.Fatalf("failed to sign: %s", err)
+	}
+	if client.lastMessageType != types.MessageTypeDigest || len(client.lastMessage) != 32 {
+		t.Fatalf("expected a SHA-256 digest")
+	}
+
+	verified, err := jws.Verify(signed, jws.WithKey(jwa.ES256K, sv))
+	if err != nil {
+		t.Fatalf("failed to verify: %s", err)
+	}
+
+	if !bytes.Equal(payload, verified) {
+		t.Fatalf("payload and verified does not match")
+	}
+}
@@ -0,0 +1,96 @@
+package awssigner
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha512"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+	"github.com/emmansun/gmsm/sm2"
+)
+
+// verifySignature verifies a signature returned by KMS locally, using
+// pubkey. message, mt, and alg are the values that were sent to KMS, except
+// for ML-DSA, which requires the message itself.
+func verifySignature(pubkey crypto.PublicKey, alg types.SigningAlgorithmSpec, message []byte, mt types.MessageType, signature []byte) error {
+	switch alg {
+	case types.SigningAlgorithmSpecEd25519Sha512, types.SigningAlgorithmSpecEd25519PhSha512:
+		pub, ok := pubkey.(ed25519.PublicKey)
+		if !ok {
+			return fmt.Errorf(`expected ed25519.PublicKey, got %T`, pubkey)
+		}
+		var opts ed25519.Options
+		if alg == types.SigningAlgorithmSpecEd25519PhSha512 {
+			opts.Hash = crypto.SHA512
+			if mt == types.MessageTypeRaw {
+				digest := sha512.Sum512(message)
+				message = digest[:]
+			}
+		}
+		return ed25519.VerifyWithOptions(pub, message, signature, &opts)
+	case types.SigningAlgorithmSpecMlDsaShake256:
+		return VerifyMLDSA(pubkey, message, signature)
+	case types.SigningAlgorithmSpecSm2dsa:
+		pub, ok := pubkey.(*ecdsa.PublicKey)
+		if !ok {
+			return fmt.Errorf(`expected *ecdsa.PublicKey, got %T`, pubkey)
+		}
+		// KMS computes RAW SM2 digests using the default ID
+		var valid bool
+		if mt == types.MessageTypeRaw {
+			valid = sm2.VerifyASN1WithSM2(pub, nil, message, signature)
+		} else {
+			valid = sm2.VerifyASN1(pub, message, signature)
+		}
+		if !valid {
+			return fmt.Errorf(`failed to verify SM2 signature`)
+		}
+		return nil
+	}
+
+	hash := signingAlgorithmHash(alg)
+	if hash == crypto.Hash(0) {
+		return fmt.Errorf(`unsupported signing algorithm %q`, alg)
+	}
+	digest := message
+	if mt == types.MessageTypeRaw {
+		h := hash.New()
+		h.Write(message)
+		digest = h.Sum(nil)
+	}
+
+	switch pub := pubkey.(type) {
+	case *rsa.PublicKey:
+		switch alg {
+		case types.SigningAlgorithmSpecRsassaPssSha256, types.SigningAlgorithmSpecRsassaPssSha384, types.SigningAlgorithmSpecRsassaPssSha512:
+			return rsa.VerifyPSS(pub, hash, digest, signature, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		default:
+			return rsa.VerifyPKCS1v15(pub, hash, digest, signature)
+		}
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(pub, digest, signature) {
+			return fmt.Errorf(`failed to verify ECDSA signature`)
+		}
+		return nil
+	default:
+		return fmt.Errorf(`unsupported public key type %T for signing algorithm %q`, pubkey, alg)
+	}
+}
+
+// reportMismatch turns a failed self-verification into an error that
+// wraps ErrSignatureMismatch, and passes it to hook, if any. It returns
+// nil if err is nil.
+func reportMismatch(kid string, hook func(string, error), err error) error {
+	if err == nil {
+		return nil
+	}
+
+	err = fmt.Errorf(`signature from key %q failed self-verification: %w (%w)`, kid, ErrSignatureMismatch, err)
+	if hook != nil {
+		hook(kid, err)
+	}
+	return err
+}
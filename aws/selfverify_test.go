@@ -0,0 +1,192 @@
+package awssigner_test
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+	awssigner "github.com/jwx-go/crypto-signer/v2/aws"
+	"github.com/jwx-go/crypto-signer/v2/aws/kmstest"
+)
+
+// corruptingClient wraps the in-memory fake KMS, and flips a bit in the
+// signatures it returns when corrupt is set
+type corruptingClient struct {
+	*kmstest.KMS
+	corrupt bool
+}
+
+func (c *corruptingClient) Sign(ctx context.Context, in *kms.SignInput, options ...func(*kms.Options)) (*kms.SignOutput, error) {
+	out, err := c.KMS.Sign(ctx, in, options...)
+	if err == nil && c.corrupt {
+		out.Signature[len(out.Signature)-1] ^= 0x01
+	}
+	return out, err
+}
+
+func TestSelfVerify(t *testing.T) {
+	client := &corruptingClient{KMS: kmstest.New()}
+	newKey := func(t *testing.T, spec types.KeySpec) string {
+		t.Helper()
+		output, err := client.CreateKey(context.Background(), &kms.CreateKeyInput{
+			KeySpec:  spec,
+			KeyUsage: types.KeyUsageTypeSignVerify,
+		})
+		if err != nil {
+			t.Fatalf("failed to create key: %s", err)
+		}
+		return aws.ToString(output.KeyMetadata.KeyId)
+	}
+
+	digest := sha256.Sum256([]byte("obla-di-obla-da"))
+	type signFunc func(hook func(string, error)) ([]byte, error)
+	testcases := []struct {
+		Name string
+		Sign signFunc
+	}{
+		{
+			Name: "RSA",
+			Sign: func(hook func(string, error)) ([]byte, error) {
+				sv := awssigner.NewRSA(client).WithKeyID(newKey(t, types.KeySpecRsa2048)).WithSelfVerify(true).WithMismatchHook(hook)
+				return sv.Sign(rand.Reader, digest[:], &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA256})
+			},
+		},
+		{
+			Name: "ECDSA",
+			Sign: func(hook func(string, error)) ([]byte, error) {
+				sv := awssigner.NewECDSA(client).WithKeyID(newKey(t, types.KeySpecEccNistP256)).WithCache(NewDumbCache()).WithSelfVerify(true).WithMismatchHook(hook)
+				return sv.Sign(rand.Reader, digest[:], crypto.SHA256)
+			},
+		},
+		{
+			Name: "ECDSA secp256k1",
+			Sign: func(hook func(string, error)) ([]byte, error) {
+				sv := awssigner.NewECDSA(client).WithKeyID(newKey(t, types.KeySpecEccSecgP256k1)).WithSelfVerify(true).WithMismatchHook(hook)
+				return sv.Sign(rand.Reader, digest[:], crypto.SHA256)
+			},
+		},
+		{
+			Name: "ECDSA SignMessage",
+			Sign: func(hook func(string, error)) ([]byte, error) {
+				sv := awssigner.NewECDSA(client).WithKeyID(newKey(t, types.KeySpecEccNistP384)).WithSelfVerify(true).WithMismatchHook(hook)
+				return sv.SignMessage(rand.Reader, []byte("obla-di-obla-da"), crypto.SHA384)
+			},
+		},
+		{
+			Name: "EdDSA",
+			Sign: func(hook func(string, error)) ([]byte, error) {
+				sv := awssigner.NewEdDSA(client).WithKeyID(newKey(t, types.KeySpecEccNistEdwards25519)).WithSelfVerify(true).WithMismatchHook(hook)
+				return sv.Sign(rand.Reader, []byte("obla-di-obla-da"), crypto.Hash(0))
+			},
+		},
+		{
+			Name: "MLDSA",
+			Sign: func(hook func(string, error)) ([]byte, error) {
+				sv := awssigner.NewMLDSA(client).WithKeyID(newKey(t, types.KeySpecMlDsa65)).WithSelfVerify(true).WithMismatchHook(hook)
+				return sv.Sign(rand.Reader, []byte("obla-di-obla-da"), crypto.Hash(0))
+			},
+		},
+		{
+			Name: "SM2",
+			Sign: func(hook func(string, error)) ([]byte, error) {
+				sv := awssigner.NewSM2(client).WithKeyID(newKey(t, types.KeySpecSm2)).WithSelfVerify(true).WithMismatchHook(hook)
+				return sv.Sign(rand.Reader, []byte("obla-di-obla-da"), crypto.Hash(0))
+			},
+		},
+		{
+			Name: "Signer",
+			Sign: func(hook func(string, error)) ([]byte, error) {
+				sv := awssigner.New(client).WithKeyID(newKey(t, types.KeySpecEccNistP256)).WithSelfVerify(true).WithMismatchHook(hook)
+				return sv.Sign(rand.Reader, digest[:], crypto.SHA256)
+			},
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.Name, func(t *testing.T) {
+			var mismatches []error
+			hook := func(_ string, err error) {
+				mismatches = append(mismatches, err)
+			}
+
+			client.corrupt = false
+			if _, err := tc.Sign(hook); err != nil {
+				t.Fatalf("failed to sign: %s", err)
+			}
+			if len(mismatches) != 0 {
+				t.Fatalf("expected the hook not to be called, got %v", mismatches)
+			}
+
+			client.corrupt = true
+			defer func() { client.corrupt = false }()
+			signed, err := tc.Sign(hook)
+			if !errors.Is(err, awssigner.ErrSignatureMismatch) {
+				t.Fatalf("expected ErrSignatureMismatch, got %v", err)
+			}
+			if signed != nil {
+				t.Fatalf("expected no signature to be returned")
+			}
+			if len(mismatches) != 1 || !errors.Is(mismatches[0], awssigner.ErrSignatureMismatch) {
+				t.Fatalf("expected the hook to be called once with ErrSignatureMismatch, got %v", mismatches)
+			}
+		})
+	}
+}
+
+func TestSelfVerifyRepointedAlias(t *testing.T) {
+	ctx := context.Background()
+	client := &recordingClient{KMS: kmstest.New()}
+	const alias = `alias/jwt-signing`
+
+	var kids []string
+	for range 2 {
+		output, err := client.CreateKey(ctx, &kms.CreateKeyInput{
+			KeySpec:  types.KeySpecEccNistP256,
+			KeyUsage: types.KeyUsageTypeSignVerify,
+		})
+		if err != nil {
+			t.Fatalf("failed to create key: %s", err)
+		}
+		kids = append(kids, aws.ToString(output.KeyMetadata.KeyId))
+	}
+	if _, err := client.CreateAlias(ctx, &kms.CreateAliasInput{AliasName: aws.String(alias), TargetKeyId: aws.String(kids[0])}); err != nil {
+		t.Fatalf("failed to create alias: %s", err)
+	}
+
+	var mismatches []error
+	sv := awssigner.NewECDSA(client).
+		WithKeyID(alias).
+		WithSelfVerify(true).
+		WithMismatchHook(func(_ string, err error) {
+			mismatches = append(mismatches, err)
+		})
+
+	digest := sha256.Sum256([]byte("obla-di-obla-da"))
+	for range 2 {
+		if _, err := sv.Sign(rand.Reader, digest[:], crypto.SHA256); err != nil {
+			t.Fatalf("failed to sign: %s", err)
+		}
+	}
+	if client.publicKeyCalls != 1 {
+		t.Fatalf("expected the public key to be retrieved once, got %d", client.publicKeyCalls)
+	}
+
+	if _, err := client.UpdateAlias(ctx, &kms.UpdateAliasInput{AliasName: aws.String(alias), TargetKeyId: aws.String(kids[1])}); err != nil {
+		t.Fatalf("failed to update alias: %s", err)
+	}
+
+	// KMS now signs with the other key, which does not match the public key
+	// that was pinned when the first signature was made
+	if _, err := sv.Sign(rand.Reader, digest[:], crypto.SHA256); !errors.Is(err, awssigner.ErrSignatureMismatch) {
+		t.Fatalf("expected ErrSignatureMismatch, got %v", err)
+	}
+	if len(mismatches) != 1 {
+		t.Fatalf("expected the hook to be called once, got %v", mismatches)
+	}
+}
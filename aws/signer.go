@@ -0,0 +1,367 @@
+package awssigner
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"fmt"
+	"io"
+	"slices"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+)
+
+// Signer is a crypto.Signer for AWS KMS asymmetric signing keys, which
+// detects the type of the key by itself. Unlike RSA, ECDSA, and EdDSA,
+// all you need to know in order to use it is the key ID.
+//
+// The key spec and the signing algorithms that the key supports are
+// retrieved from KMS along with the public key the first time they are
+// required. They are remembered by the Signer (and the objects derived
+// from it using the With* methods), and are also stored in the Cache if
+// one is provided, so that they can be shared with other objects.
+//
+// Keys with the RSA_2048, RSA_3072, RSA_4096, ECC_NIST_P256, ECC_NIST_P384,
+// ECC_NIST_P521, ECC_SECG_P256K1, and ECC_NIST_EDWARDS25519 key specs
+// are supported.
+type Signer struct {
+	aliasRefresh time.Duration
+	checkState   bool
+	client       Client
+	cache        Cache
+	ctx          context.Context
+	grantTokens  []string
+	kid          string
+	limiter      *Limiter
+	memo         *keyMemo
+	mt           types.MessageType
+	mismatchHook func(string, error)
+	selfVerify   bool
+	encoding     SignatureEncoding
+	lowS         bool
+}
+
+// New creates a new Signer object. This object isnot complete by itself -- it
+// needs to be setup with a key ID, and a context.Context object to use while
+// the AWS SDK makes network requests.
+func New(client Client) *Signer {
+	return &Signer{
+		client: client,
+		memo:   &keyMemo{},
+	}
+}
+
+func (sv *Signer) getContext() context.Context {
+	ctx := sv.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return ctx
+}
+
+func (sv *Signer) key() kmsKey {
+	return kmsKey{
+		aliasRefresh: sv.aliasRefresh,
+		cache:        sv.cache,
+		client:       sv.client,
+		grantTokens:  sv.grantTokens,
+		kid:          sv.kid,
+		memo:         sv.memo,
+	}
+}
+
+// Sign generates a signature from the given digest, or from the given
+// message in the case of Ed25519 keys.
+//
+// The signing algorithm is derived from the key spec and opts, using the
+// same rules as RSA.Sign(), ECDSA.Sign(), and EdDSA.Sign(). It must be
+// one of the signing algorithms that KMS reports for the key.
+func (sv *Signer) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	if sv.kid == "" {
+		return nil, fmt.Errorf(`aws.Signer.Sign() requires the key ID`)
+	}
+
+	info, err := sv.getKeyInfo()
+	if err != nil {
+		return nil, fmt.Errorf(`aws.Signer.Sign() failed to retrieve key information: %w`, err)
+	}
+
+	alg, mt, err := info.signingAlgorithm(digest, opts)
+	if err != nil {
+		return nil, fmt.Errorf(`aws.Signer.Sign() %w`, err)
+	}
+
+	// sv.ctx is NOT required, but we will use context.Background here
+	// which means there will not be a (clean) way to interrupt this
+	// operation
+	ctx := sv.getContext()
+
+	kid, err := sv.key().resolve(ctx)
+	if err != nil {
+		return nil, fmt.Errorf(`aws.Signer.Sign() %w`, err)
+	}
+
+	if sv.checkState {
+		if err := kmsCheckKeyState(ctx, sv.client, kid, sv.grantTokens, types.KeyUsageTypeSignVerify); err != nil {
+			return nil, fmt.Errorf(`aws.Signer.Sign() refused to use the key: %w`, err)
+		}
+	}
+
+	signed, err := kmsSignLimited(ctx, sv.limiter, sv.client, kid, sv.grantTokens, digest, mt, alg)
+	if err != nil {
+		return nil, err
+	}
+	if sv.selfVerify {
+		if err := sv.key().selfVerify(ctx, kid, sv.mismatchHook, digest, mt, alg, signed); err != nil {
+			return nil, fmt.Errorf(`aws.Signer.Sign() %w`, err)
+		}
+	}
+	signed, err = sv.encodeSignature(signed)
+	if err != nil {
+		return nil, fmt.Errorf(`aws.Signer.Sign() %w`, err)
+	}
+	return signed, nil
+}
+
+// SignMessage generates a signature from the given message, and
+// implements crypto.MessageSigner.
+//
+// The signing algorithm is chosen in the same way as Sign(). Messages up
+// to 4096 bytes are sent to KMS as is, and larger messages are hashed
+// locally, unless specified otherwise using WithMessageType(). Ed25519
+// keys can only sign messages up to 4096 bytes, unless opts.HashFunc()
+// is crypto.SHA512 (Ed25519ph).
+func (sv *Signer) SignMessage(_ io.Reader, message []byte, opts crypto.SignerOpts) ([]byte, error) {
+	return sv.SignStream(bytes.NewReader(message), opts)
+}
+
+// SignStream works like SignMessage(), but reads the message from r.
+// Messages that are hashed locally are hashed as they are read, so they
+// are never held in memory as a whole.
+func (sv *Signer) SignStream(r io.Reader, opts crypto.SignerOpts) ([]byte, error) {
+	if sv.kid == "" {
+		return nil, fmt.Errorf(`aws.Signer.SignStream() requires the key ID`)
+	}
+
+	info, err := sv.getKeyInfo()
+	if err != nil {
+		return nil, fmt.Errorf(`aws.Signer.SignStream() failed to retrieve key information: %w`, err)
+	}
+
+	alg, hash, err := info.messageSigningAlgorithm(opts)
+	if err != nil {
+		return nil, fmt.Errorf(`aws.Signer.SignStream() %w`, err)
+	}
+
+	message, mt, err := messageForSigning(r, hash, sv.mt)
+	if err != nil {
+		return nil, fmt.Errorf(`aws.Signer.SignStream() %w`, err)
+	}
+
+	// sv.ctx is NOT required, but we will use context.Background here
+	// which means there will not be a (clean) way to interrupt this
+	// operation
+	ctx := sv.getContext()
+
+	kid, err := sv.key().resolve(ctx)
+	if err != nil {
+		return nil, fmt.Errorf(`aws.Signer.SignStream() %w`, err)
+	}
+
+	if sv.checkState {
+		if err := kmsCheckKeyState(ctx, sv.client, kid, sv.grantTokens, types.KeyUsageTypeSignVerify); err != nil {
+			return nil, fmt.Errorf(`aws.Signer.SignStream() refused to use the key: %w`, err)
+		}
+	}
+
+	signed, err := kmsSignLimited(ctx, sv.limiter, sv.client, kid, sv.grantTokens, message, mt, alg)
+	if err != nil {
+		return nil, err
+	}
+	if sv.selfVerify {
+		if err := sv.key().selfVerify(ctx, kid, sv.mismatchHook, message, mt, alg, signed); err != nil {
+			return nil, fmt.Errorf(`aws.Signer.SignStream() %w`, err)
+		}
+	}
+	signed, err = sv.encodeSignature(signed)
+	if err != nil {
+		return nil, fmt.Errorf(`aws.Signer.SignStream() %w`, err)
+	}
+	return signed, nil
+}
+
+// encodeSignature re-encodes an ECDSA signature returned by KMS, as
+// specified via WithSignatureEncoding() and WithLowS(). Other signatures
+// are returned as they are.
+func (sv *Signer) encodeSignature(signature []byte) ([]byte, error) {
+	if sv.encoding == SignatureEncodingDER && !sv.lowS {
+		return signature, nil
+	}
+
+	info, err := sv.getKeyInfo()
+	if err != nil {
+		return nil, fmt.Errorf(`failed to retrieve public key to encode signature: %w`, err)
+	}
+	pubkey, ok := info.publicKey.(*ecdsa.PublicKey)
+	if !ok {
+		return signature, nil
+	}
+
+	return encodeECDSASignature(pubkey, signature, sv.encoding, sv.lowS)
+}
+
+// CheckAccess makes sure that the key can be used for signing, by calling
+// the KMS Sign API with DryRun enabled. Use it at startup to find out
+// about missing permissions or disabled keys before the first signature
+// is actually required.
+func (sv *Signer) CheckAccess(ctx context.Context) error {
+	info, err := sv.WithContext(ctx).getKeyInfo()
+	if err != nil {
+		return fmt.Errorf(`aws.Signer.CheckAccess() failed to retrieve key information: %w`, err)
+	}
+	if len(info.algs) == 0 {
+		return fmt.Errorf(`aws.Signer.CheckAccess() found no signing algorithms for key spec %q`, info.spec)
+	}
+
+	if err := sv.key().checkSign(ctx, info.algs[0]); err != nil {
+		return fmt.Errorf(`aws.Signer.CheckAccess() %w`, err)
+	}
+	return nil
+}
+
+// KeyARN returns the ARN of the key, resolving the alias (if any) in the
+// same way as RSA.KeyARN().
+//
+// Use it as the "kid" header of JWS messages, so that verifiers can pick
+// the right public key after the alias has been rotated to a new key.
+func (sv *Signer) KeyARN() (string, error) {
+	if sv.kid == "" {
+		return "", fmt.Errorf(`aws.Signer.KeyARN() requires the key ID`)
+	}
+
+	// sv.ctx is NOT required, but we will use context.Background here
+	// which means there will not be a (clean) way to interrupt this
+	// operation
+	ctx := sv.getContext()
+
+	return sv.key().arn(ctx)
+}
+
+// Public returns the corresponding public key.
+//
+// Because the crypto.Signer API does not allow for an error to be returned,
+// the return value from this function cannot describe what kind of error
+// occurred.
+func (sv *Signer) Public() crypto.PublicKey {
+	pubkey, _ := sv.GetPublicKey()
+	return pubkey
+}
+
+// This method is an escape hatch for those cases where the user needs
+// to debug what went wrong during the GetPublicKey operation.
+func (sv *Signer) GetPublicKey() (crypto.PublicKey, error) {
+	info, err := sv.getKeyInfo()
+	if err != nil {
+		return nil, err
+	}
+	return info.publicKey, nil
+}
+
+// KeySpec returns the key spec of the KMS key, such as types.KeySpecRsa2048
+func (sv *Signer) KeySpec() (types.KeySpec, error) {
+	info, err := sv.getKeyInfo()
+	if err != nil {
+		return "", err
+	}
+	return info.spec, nil
+}
+
+// SigningAlgorithms returns the list of signing algorithms that
+// the KMS key supports.
+func (sv *Signer) SigningAlgorithms() ([]types.SigningAlgorithmSpec, error) {
+	info, err := sv.getKeyInfo()
+	if err != nil {
+		return nil, err
+	}
+	return slices.Clone(info.algs), nil
+}
+
+// signingAlgorithm determines the signing algorithm and the message type
+// to use for the key, based on the key spec and opts.
+func (info *keyInfo) signingAlgorithm(digest []byte, opts crypto.SignerOpts) (types.SigningAlgorithmSpec, types.MessageType, error) {
+	var alg types.SigningAlgorithmSpec
+	var err error
+	mt := types.MessageTypeDigest
+	switch info.spec {
+	case types.KeySpecRsa2048, types.KeySpecRsa3072, types.KeySpecRsa4096:
+		alg, err = chooseSigningAlgorithm("", rsaSigningAlgorithm, digest, opts)
+	case types.KeySpecEccNistP256, types.KeySpecEccNistP384, types.KeySpecEccNistP521, types.KeySpecEccSecgP256k1:
+		alg, err = chooseSigningAlgorithm("", ecdsaSigningAlgorithm, digest, opts)
+	case types.KeySpecEccNistEdwards25519:
+		alg, mt, err = eddsaSigningAlgorithm(digest, opts)
+	default:
+		return "", "", fmt.Errorf(`does not support key spec %q`, info.spec)
+	}
+	if err != nil {
+		return "", "", fmt.Errorf(`failed to determine signing algorithm: %w`, err)
+	}
+
+	if !slices.Contains(info.algs, alg) {
+		return "", "", fmt.Errorf(`cannot use signing algorithm %q with key spec %q (allowed: %v): %w`, alg, info.spec, info.algs, ErrWrongKeyUsage)
+	}
+	return alg, mt, nil
+}
+
+// messageSigningAlgorithm works like signingAlgorithm, but for signing
+// whole messages. Along with the signing algorithm, it returns the hash
+// function to use if the message is hashed locally, or zero if the
+// algorithm requires the message itself.
+func (info *keyInfo) messageSigningAlgorithm(opts crypto.SignerOpts) (types.SigningAlgorithmSpec, crypto.Hash, error) {
+	var alg types.SigningAlgorithmSpec
+	var err error
+	switch info.spec {
+	case types.KeySpecRsa2048, types.KeySpecRsa3072, types.KeySpecRsa4096:
+		alg, err = selectSigningAlgorithm("", rsaSigningAlgorithm, opts)
+	case types.KeySpecEccNistP256, types.KeySpecEccNistP384, types.KeySpecEccNistP521, types.KeySpecEccSecgP256k1:
+		alg, err = selectSigningAlgorithm("", ecdsaSigningAlgorithm, opts)
+	case types.KeySpecEccNistEdwards25519:
+		alg, _, err = eddsaSelectSigningAlgorithm(opts)
+	default:
+		return "", 0, fmt.Errorf(`does not support key spec %q`, info.spec)
+	}
+	if err != nil {
+		return "", 0, fmt.Errorf(`failed to determine signing algorithm: %w`, err)
+	}
+
+	if !slices.Contains(info.algs, alg) {
+		return "", 0, fmt.Errorf(`cannot use signing algorithm %q with key spec %q (allowed: %v): %w`, alg, info.spec, info.algs, ErrWrongKeyUsage)
+	}
+
+	// Ed25519ph signs a SHA-512 digest of the message, while plain
+	// Ed25519 requires the message itself
+	if alg == types.SigningAlgorithmSpecEd25519PhSha512 {
+		return alg, crypto.SHA512, nil
+	}
+	return alg, signingAlgorithmHash(alg), nil
+}
+
+func (sv *Signer) getKeyInfo() (*keyInfo, error) {
+	if sv.kid == "" {
+		return nil, fmt.Errorf(`aws.Signer requires the key ID`)
+	}
+
+	// sv.ctx is NOT required, but we will use context.Background here
+	// which means there will not be a (clean) way to interrupt this
+	// operation
+	ctx := sv.getContext()
+
+	// GetPublicKey returns the key spec and the signing algorithms
+	// as well as the public key, so there is no need to call DescribeKey
+	info, err := sv.key().info(ctx, types.KeyUsageTypeSignVerify)
+	if err != nil {
+		return nil, fmt.Errorf(`aws.Signer %w`, err)
+	}
+	return info, nil
+}
@@ -0,0 +1,152 @@
+package awssigner_test
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/sha256"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+	awssigner "github.com/jwx-go/crypto-signer/v2/aws"
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jws"
+)
+
+var _ crypto.Signer = &awssigner.Signer{}
+
+func TestSigner(t *testing.T) {
+	testcases := []struct {
+		Name      string
+		Spec      types.KeySpec
+		Algorithm jwa.SignatureAlgorithm
+		Expected  types.SigningAlgorithmSpec
+		Error     bool
+	}{
+		{
+			Name:      "RSA PKCS1-v1_5",
+			Spec:      types.KeySpecRsa2048,
+			Algorithm: jwa.RS256,
+			Expected:  types.SigningAlgorithmSpecRsassaPkcs1V15Sha256,
+		},
+		{
+			Name:      "RSA PSS",
+			Spec:      types.KeySpecRsa2048,
+			Algorithm: jwa.PS256,
+			Expected:  types.SigningAlgorithmSpecRsassaPssSha256,
+		},
+		{
+			Name:      "ECC_NIST_P256",
+			Spec:      types.KeySpecEccNistP256,
+			Algorithm: jwa.ES256,
+			Expected:  types.SigningAlgorithmSpecEcdsaSha256,
+		},
+		{
+			Name:      "ECC_NIST_P384",
+			Spec:      types.KeySpecEccNistP384,
+			Algorithm: jwa.ES384,
+			Expected:  types.SigningAlgorithmSpecEcdsaSha384,
+		},
+		{
+			Name:      "ECC_SECG_P256K1",
+			Spec:      types.KeySpecEccSecgP256k1,
+			Algorithm: jwa.ES256K,
+			Expected:  types.SigningAlgorithmSpecEcdsaSha256,
+		},
+		{
+			Name:      "ECC_NIST_EDWARDS25519",
+			Spec:      types.KeySpecEccNistEdwards25519,
+			Algorithm: jwa.EdDSA,
+			Expected:  types.SigningAlgorithmSpecEd25519Sha512,
+		},
+		{
+			Name:      "algorithm not allowed for key",
+			Spec:      types.KeySpecEccNistP256,
+			Algorithm: jwa.ES384,
+			Error:     true,
+		},
+	}
+
+	payload := []byte("obla-di-obla-da")
+	for _, tc := range testcases {
+		t.Run(tc.Name, func(t *testing.T) {
+			client, kid := newTestKey(t, tc.Spec)
+			sv := awssigner.New(client).
+				WithKeyID(kid).
+				WithCache(NewDumbCache())
+
+			spec, err := sv.KeySpec()
+			if err != nil {
+				t.Fatalf("failed to get key spec: %s", err)
+			}
+			if spec != tc.Spec {
+				t.Fatalf("expected key spec %q, got %q", tc.Spec, spec)
+			}
+
+			signed, err := jws.Sign(payload, jws.WithKey(tc.Algorithm, sv))
+			if tc.Error {
+				if err == nil {
+					t.Fatalf("signing should have failed")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to sign: %s", err)
+			}
+			if client.lastAlgorithm != tc.Expected {
+				t.Fatalf("expected %q, got %q", tc.Expected, client.lastAlgorithm)
+			}
+
+			verified, err := jws.Verify(signed, jws.WithKey(tc.Algorithm, sv))
+			if err != nil {
+				t.Fatalf("failed to verify: %s", err)
+			}
+			if !bytes.Equal(payload, verified) {
+				t.Fatalf("payload and verified does not match")
+			}
+		})
+	}
+}
+
+func TestSignerKeyInfo(t *testing.T) {
+	digest := sha256.Sum256([]byte("obla-di-obla-da"))
+
+	t.Run("without cache", func(t *testing.T) {
+		client, kid := newTestKey(t, types.KeySpecEccNistP256)
+		sv := awssigner.New(client).
+			WithKeyID(kid)
+
+		for range 3 {
+			if _, err := sv.Sign(rand.Reader, digest[:], crypto.SHA256); err != nil {
+				t.Fatalf("failed to sign: %s", err)
+			}
+		}
+		if err := sv.CheckAccess(context.Background()); err != nil {
+			t.Fatalf("failed to check access: %s", err)
+		}
+		if client.publicKeyCalls != 1 {
+			t.Fatalf("expected the public key to be retrieved once, got %d", client.publicKeyCalls)
+		}
+	})
+	t.Run("shared cache", func(t *testing.T) {
+		client, kid := newTestKey(t, types.KeySpecRsa2048)
+		cache := NewDumbCache()
+		rsasv := awssigner.NewRSA(client).
+			WithKeyID(kid).
+			WithCache(cache)
+
+		for range 3 {
+			if _, err := rsasv.GetPublicKey(); err != nil {
+				t.Fatalf("failed to get public key: %s", err)
+			}
+			if _, err := awssigner.New(client).WithKeyID(kid).WithCache(cache).GetPublicKey(); err != nil {
+				t.Fatalf("failed to get public key: %s", err)
+			}
+		}
+		// RSA and Signer share the same cache entry
+		if client.publicKeyCalls != 1 {
+			t.Fatalf("expected the public key to be retrieved once, got %d", client.publicKeyCalls)
+		}
+	})
+}
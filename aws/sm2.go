@@ -0,0 +1,279 @@
+package awssigner
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+	"github.com/emmansun/gmsm/sm2"
+	"github.com/emmansun/gmsm/sm3"
+)
+
+// sm2DefaultUID is the distinguishing identifier that AWS KMS uses
+// when it computes the SM2 digest for messages signed with the RAW
+// message type.
+var sm2DefaultUID = []byte("1234567812345678")
+
+// SM2 is a crypto.Signer for AWS KMS keys with the SM2 key spec, which
+// are only available in the AWS China Regions.
+//
+// Sign() expects the full message, and computes the SM2 digest
+// (SM3(Z || M)) according to GB/T 32918. Use SignDigest() if you have
+// already computed the digest yourself.
+type SM2 struct {
+	aliasRefresh time.Duration
+	checkState   bool
+	client       Client
+	cache        Cache
+	ctx          context.Context
+	grantTokens  []string
+	kid          string
+	limiter      *Limiter
+	memo         *keyMemo
+	uid          []byte
+	mismatchHook func(string, error)
+	selfVerify   bool
+}
+
+// NewSM2 creates a new SM2 object. This object isnot complete by itself -- it
+// needs to be setup with a key ID, and a context.Context object to use while
+// the AWS SDK makes network requests.
+//
+// The signing algorithm is always SM2DSA, so there is no need to specify it.
+func NewSM2(client Client) *SM2 {
+	return &SM2{
+		client: client,
+		memo:   &keyMemo{},
+	}
+}
+
+func (sv *SM2) getContext() context.Context {
+	ctx := sv.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return ctx
+}
+
+func (sv *SM2) key() kmsKey {
+	return kmsKey{
+		aliasRefresh: sv.aliasRefresh,
+		cache:        sv.cache,
+		client:       sv.client,
+		grantTokens:  sv.grantTokens,
+		kid:          sv.kid,
+		memo:         sv.memo,
+	}
+}
+
+func (sv *SM2) getUID() []byte {
+	if len(sv.uid) == 0 {
+		return sm2DefaultUID
+	}
+	return sv.uid
+}
+
+// SM2SignerOpts can be passed as opts to SM2.Sign(), to specify the
+// distinguishing identifier for a single signature.
+type SM2SignerOpts struct {
+	// UID is the distinguishing identifier used to compute the digest. If
+	// it is empty, the one specified via WithUID() (or the default one) is
+	// used.
+	UID []byte
+}
+
+// HashFunc returns zero, as the SM2 digest is computed from the message
+// itself.
+func (*SM2SignerOpts) HashFunc() crypto.Hash {
+	return crypto.Hash(0)
+}
+
+// Sign generates a signature for the given message.
+//
+// opts.HashFunc() must return zero, as the SM2 digest is computed from
+// the message itself. The distinguishing identifier specified via
+// WithUID() is used, unless opts is an *SM2SignerOpts that specifies one.
+// It is an error for the two to differ.
+//
+// github.com/emmansun/gmsm does not export the distinguishing identifier
+// of an *sm2.SM2SignerOption, so sm2.DefaultSM2SignerOpts is the only one
+// that is accepted: use WithUID() or *SM2SignerOpts to specify the
+// identifier, and SignDigest() to sign a digest that you have computed
+// yourself.
+//
+// If the default distinguishing identifier is used and the message
+// is small enough, KMS computes the digest (types.MessageTypeRaw).
+// Otherwise the digest is computed locally, and then signed by KMS.
+func (sv *SM2) Sign(_ io.Reader, message []byte, opts crypto.SignerOpts) ([]byte, error) {
+	if sv.kid == "" {
+		return nil, fmt.Errorf(`aws.SM2.Sign() requires the key ID`)
+	}
+
+	if opts != nil && opts.HashFunc() != crypto.Hash(0) {
+		return nil, fmt.Errorf(`aws.SM2.Sign() expected opts.HashFunc() to be zero, got %s`, opts.HashFunc())
+	}
+
+	uid := sv.getUID()
+	switch opts := opts.(type) {
+	case *SM2SignerOpts:
+		if len(opts.UID) > 0 {
+			if len(sv.uid) > 0 && !bytes.Equal(opts.UID, sv.uid) {
+				return nil, fmt.Errorf(`aws.SM2.Sign() the distinguishing identifier in opts does not match the one specified via WithUID()`)
+			}
+			uid = opts.UID
+		}
+	case *sm2.SM2SignerOption:
+		if opts != sm2.DefaultSM2SignerOpts {
+			return nil, fmt.Errorf(`aws.SM2.Sign() cannot honor the distinguishing identifier of an *sm2.SM2SignerOption other than sm2.DefaultSM2SignerOpts: use WithUID() or *SM2SignerOpts instead`)
+		}
+	}
+	if bytes.Equal(uid, sm2DefaultUID) && len(message) <= maxRawMessageSize {
+		return sv.sign(message, types.MessageTypeRaw)
+	}
+
+	pubkey, err := sv.GetPublicKey()
+	if err != nil {
+		return nil, fmt.Errorf(`failed to retrieve public key to compute digest: %w`, err)
+	}
+	digest, err := sm2.CalculateSM2Hash(pubkey.(*ecdsa.PublicKey), message, uid)
+	if err != nil {
+		return nil, fmt.Errorf(`failed to compute SM2 digest: %w`, err)
+	}
+	return sv.sign(digest, types.MessageTypeDigest)
+}
+
+// SignDigest generates a signature for the given SM2 digest, which must
+// have been computed as SM3(Z || M) using the public key of this signer.
+func (sv *SM2) SignDigest(digest []byte) ([]byte, error) {
+	if sv.kid == "" {
+		return nil, fmt.Errorf(`aws.SM2.SignDigest() requires the key ID`)
+	}
+
+	if len(digest) != sm3.Size {
+		return nil, fmt.Errorf(`aws.SM2.SignDigest() expected a digest of length %d, got %d`, sm3.Size, len(digest))
+	}
+	return sv.sign(digest, types.MessageTypeDigest)
+}
+
+func (sv *SM2) sign(message []byte, mt types.MessageType) ([]byte, error) {
+	// sv.ctx is NOT required, but we will use context.Background here
+	// which means there will not be a (clean) way to interrupt this
+	// operation
+	ctx := sv.getContext()
+
+	kid, err := sv.key().resolve(ctx)
+	if err != nil {
+		return nil, fmt.Errorf(`aws.SM2.sign() %w`, err)
+	}
+
+	if sv.checkState {
+		if err := kmsCheckKeyState(ctx, sv.client, kid, sv.grantTokens, types.KeyUsageTypeSignVerify); err != nil {
+			return nil, fmt.Errorf(`aws.SM2.Sign() refused to use the key: %w`, err)
+		}
+	}
+
+	signed, err := kmsSignLimited(ctx, sv.limiter, sv.client, kid, sv.grantTokens, message, mt, types.SigningAlgorithmSpecSm2dsa)
+	if err != nil {
+		return nil, err
+	}
+	if sv.selfVerify {
+		if err := sv.key().selfVerify(ctx, kid, sv.mismatchHook, message, mt, types.SigningAlgorithmSpecSm2dsa, signed); err != nil {
+			return nil, fmt.Errorf(`aws.SM2.Sign() %w`, err)
+		}
+	}
+	return signed, nil
+}
+
+// SignMessage implements crypto.MessageSigner. Because Sign() already
+// receives the message rather than a digest, it is the same as Sign().
+func (sv *SM2) SignMessage(rand io.Reader, message []byte, opts crypto.SignerOpts) ([]byte, error) {
+	return sv.Sign(rand, message, opts)
+}
+
+// CheckAccess makes sure that the key can be used for signing, by calling
+// the KMS Sign API with DryRun enabled. Use it at startup to find out
+// about missing permissions or disabled keys before the first signature
+// is actually required.
+func (sv *SM2) CheckAccess(ctx context.Context) error {
+	if sv.kid == "" {
+		return fmt.Errorf(`aws.SM2.CheckAccess() requires the key ID`)
+	}
+	if err := sv.key().checkSign(ctx, types.SigningAlgorithmSpecSm2dsa); err != nil {
+		return fmt.Errorf(`aws.SM2.CheckAccess() %w`, err)
+	}
+	return nil
+}
+
+// KeyARN returns the ARN of the key, resolving the alias (if any) in the
+// same way as RSA.KeyARN(). It identifies the key that produced a
+// signature even after the alias has been pointed to a new key.
+func (sv *SM2) KeyARN() (string, error) {
+	if sv.kid == "" {
+		return "", fmt.Errorf(`aws.SM2.KeyARN() requires the key ID`)
+	}
+
+	// sv.ctx is NOT required, but we will use context.Background here
+	// which means there will not be a (clean) way to interrupt this
+	// operation
+	ctx := sv.getContext()
+
+	return sv.key().arn(ctx)
+}
+
+// Public returns the corresponding public key.
+//
+// Because the crypto.Signer API does not allow for an error to be returned,
+// the return value from this function cannot describe what kind of error
+// occurred.
+func (sv *SM2) Public() crypto.PublicKey {
+	pubkey, _ := sv.GetPublicKey()
+	return pubkey
+}
+
+// This method is an escape hatch for those cases where the user needs
+// to debug what went wrong during the GetPublicKey operation.
+//
+// The returned value is an *ecdsa.PublicKey using the SM2 curve from
+// github.com/emmansun/gmsm/sm2
+func (sv *SM2) GetPublicKey() (crypto.PublicKey, error) {
+	if sv.kid == "" {
+		return nil, fmt.Errorf(`aws.SM2.GetPublicKey() requires the key ID`)
+	}
+
+	// sv.ctx is NOT required, but we will use context.Background here
+	// which means there will not be a (clean) way to interrupt this
+	// operation
+	ctx := sv.getContext()
+
+	info, err := sv.key().info(ctx, types.KeyUsageTypeSignVerify)
+	if err != nil {
+		return nil, fmt.Errorf(`aws.SM2.GetPublicKey() %w`, err)
+	}
+
+	pubkey, ok := info.publicKey.(*ecdsa.PublicKey)
+	if !ok || !sm2.IsSM2PublicKey(pubkey) {
+		return nil, fmt.Errorf(`aws.SM2.GetPublicKey() expected SM2 public key, got %T`, info.publicKey)
+	}
+	return pubkey, nil
+}
+
+// VerifySM2 verifies an SM2 signature over message using the given SM2
+// public key, such as the one returned by SM2.GetPublicKey.
+//
+// uid is the distinguishing identifier used to compute the digest. If it is
+// empty, the default identifier ("1234567812345678") is used.
+func VerifySM2(key crypto.PublicKey, uid, message, signature []byte) error {
+	pubkey, ok := key.(*ecdsa.PublicKey)
+	if !ok || !sm2.IsSM2PublicKey(pubkey) {
+		return fmt.Errorf(`expected SM2 public key, got %T`, key)
+	}
+	if !sm2.VerifyASN1WithSM2(pubkey, uid, message, signature) {
+		return fmt.Errorf(`failed to verify SM2 signature`)
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package awssigner_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+	"github.com/emmansun/gmsm/sm2"
+	awssigner "github.com/jwx-go/crypto-signer/v2/aws"
+)
+
+var _ crypto.Signer = &awssigner.SM2{}
+
+func TestSM2(t *testing.T) {
+	client, kid := newTestKey(t, types.KeySpecSm2)
+
+	sv := awssigner.NewSM2(client).
+		WithKeyID(kid).
+		WithCache(NewDumbCache())
+
+	large := make([]byte, 8192)
+	uid := []byte("alice@example.com")
+	testcases := []struct {
+		Name        string
+		Signer      *awssigner.SM2
+		UID         []byte
+		Message     []byte
+		MessageType types.MessageType
+	}{
+		{
+			Name:        "RAW",
+			Signer:      sv,
+			Message:     []byte("obla-di-obla-da"),
+			MessageType: types.MessageTypeRaw,
+		},
+		{
+			Name:        "large message",
+			Signer:      sv,
+			Message:     large,
+			MessageType: types.MessageTypeDigest,
+		},
+		{
+			Name:        "custom UID",
+			Signer:      sv.WithUID(uid),
+			UID:         uid,
+			Message:     []byte("obla-di-obla-da"),
+			MessageType: types.MessageTypeDigest,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.Name, func(t *testing.T) {
+			signature, err := tc.Signer.Sign(rand.Reader, tc.Message, sm2.DefaultSM2SignerOpts)
+			if err != nil {
+				t.Fatalf("failed to sign: %s", err)
+			}
+			if client.lastMessageType != tc.MessageType {
+				t.Fatalf("expected message type %q, got %q", tc.MessageType, client.lastMessageType)
+			}
+			if err := awssigner.VerifySM2(sv.Public(), tc.UID, tc.Message, signature); err != nil {
+				t.Fatalf("failed to verify: %s", err)
+			}
+			if err := awssigner.VerifySM2(sv.Public(), tc.UID, []byte("wrong payload"), signature); err == nil {
+				t.Fatalf("verification should have failed")
+			}
+		})
+	}
+
+	t.Run("UID in opts", func(t *testing.T) {
+		message := []byte("obla-di-obla-da")
+		opts := &awssigner.SM2SignerOpts{UID: uid}
+
+		signature, err := sv.Sign(rand.Reader, message, opts)
+		if err != nil {
+			t.Fatalf("failed to sign: %s", err)
+		}
+		if client.lastMessageType != types.MessageTypeDigest {
+			t.Fatalf("expected message type %q, got %q", types.MessageTypeDigest, client.lastMessageType)
+		}
+		if err := awssigner.VerifySM2(sv.Public(), uid, message, signature); err != nil {
+			t.Fatalf("failed to verify: %s", err)
+		}
+
+		// the same UID specified both ways is fine, but conflicting ones are not
+		if _, err := sv.WithUID(uid).Sign(rand.Reader, message, opts); err != nil {
+			t.Fatalf("failed to sign: %s", err)
+		}
+		if _, err := sv.WithUID([]byte("bob@example.com")).Sign(rand.Reader, message, opts); err == nil {
+			t.Fatalf("expected conflicting UIDs to be rejected")
+		}
+	})
+
+	t.Run("gmsm options", func(t *testing.T) {
+		// the UID of these cannot be read, so they must not be silently
+		// signed with the wrong one
+		for _, opts := range []*sm2.SM2SignerOption{
+			sm2.NewSM2SignerOption(true, uid),
+			sm2.NewSM2SignerOption(true, nil),
+			sm2.NewSM2SignerOption(false, nil),
+		} {
+			if _, err := sv.Sign(rand.Reader, []byte("obla-di-obla-da"), opts); err == nil {
+				t.Fatalf("expected *sm2.SM2SignerOption to be rejected")
+			}
+		}
+	})
+
+	t.Run("digest", func(t *testing.T) {
+		message := []byte("obla-di-obla-da")
+		pubkey, err := sv.GetPublicKey()
+		if err != nil {
+			t.Fatalf("failed to get public key: %s", err)
+		}
+		digest, err := sm2.CalculateSM2Hash(pubkey.(*ecdsa.PublicKey), message, nil)
+		if err != nil {
+			t.Fatalf("failed to compute digest: %s", err)
+		}
+		signature, err := sv.SignDigest(digest)
+		if err != nil {
+			t.Fatalf("failed to sign: %s", err)
+		}
+		if err := awssigner.VerifySM2(sv.Public(), nil, message, signature); err != nil {
+			t.Fatalf("failed to verify: %s", err)
+		}
+		if _, err := sv.SignDigest(message); err == nil {
+			t.Fatalf("signing a digest of the wrong length should fail")
+		}
+	})
+}
@@ -0,0 +1,185 @@
+package awssigner
+
+import (
+	"context"
+	"crypto"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+)
+
+// Verifier verifies signatures using the AWS KMS Verify API, so that the
+// public key never has to be retrieved. Use it in services whose key
+// policy does not allow kms:GetPublicKey.
+//
+// It verifies signatures created by RSA, ECDSA, EdDSA, and Signer, and
+// derives the signing algorithm from crypto.SignerOpts using the same
+// rules. See the jwxadapter package for using it to verify JWS messages.
+type Verifier struct {
+	alg          types.SigningAlgorithmSpec
+	aliasRefresh time.Duration
+	cache        Cache
+	client       Client
+	ctx          context.Context
+	grantTokens  []string
+	kid          string
+}
+
+// describedKeyCacheKey is the key under which the key spec and the signing
+// algorithms retrieved by Verifier are stored in the Cache. It is distinct
+// from keyInfoCacheKey, under which the other objects store the same
+// information along with the public key, which Verifier does not have
+// access to.
+type describedKeyCacheKey string
+
+// NewVerifier creates a new Verifier object. This object isnot complete by itself -- it
+// needs to be setup with a key ID, and a context.Context object to use while
+// the AWS SDK makes network requests.
+//
+// The key spec and the signing algorithms that the key supports are
+// retrieved using DescribeKey, unless the algorithm is fixed using
+// WithAlgorithm().
+func NewVerifier(client Client) *Verifier {
+	return &Verifier{
+		client: client,
+	}
+}
+
+func (sv *Verifier) getContext() context.Context {
+	ctx := sv.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return ctx
+}
+
+// Verify verifies the signature of the given digest, or of the given
+// message in the case of Ed25519 keys. digest and opts must be the same
+// as those passed to Sign(), and ECDSA signatures must be ASN.1 DER
+// encoded, as returned by Sign().
+//
+// A nil error is returned only if KMS reports that the signature is valid.
+// Invalid signatures result in an error wrapping
+// *types.KMSInvalidSignatureException.
+func (sv *Verifier) Verify(digest, signature []byte, opts crypto.SignerOpts) error {
+	if sv.kid == "" {
+		return fmt.Errorf(`aws.Verifier.Verify() requires the key ID`)
+	}
+
+	// sv.ctx is NOT required, but we will use context.Background here
+	// which means there will not be a (clean) way to interrupt this
+	// operation
+	ctx := sv.getContext()
+
+	kid, err := resolveKeyID(ctx, sv.client, sv.kid, sv.grantTokens, sv.cache, sv.aliasRefresh)
+	if err != nil {
+		return fmt.Errorf(`aws.Verifier.Verify() failed to resolve key ID: %w`, err)
+	}
+
+	alg, mt, err := sv.signingAlgorithm(ctx, kid, digest, opts)
+	if err != nil {
+		return fmt.Errorf(`aws.Verifier.Verify() %w`, err)
+	}
+
+	return kmsVerify(ctx, sv.client, kid, sv.grantTokens, digest, signature, mt, alg)
+}
+
+// signingAlgorithm determines the signing algorithm and the message type.
+// If the algorithm was specified via WithAlgorithm(), the kind of key is
+// derived from it. Otherwise the key is described using KMS DescribeKey.
+func (sv *Verifier) signingAlgorithm(ctx context.Context, kid string, digest []byte, opts crypto.SignerOpts) (types.SigningAlgorithmSpec, types.MessageType, error) {
+	if alg := sv.alg; alg != "" {
+		switch {
+		case strings.HasPrefix(string(alg), `RSASSA_`):
+			alg, err := chooseSigningAlgorithm(alg, rsaSigningAlgorithm, digest, opts)
+			if err != nil {
+				return "", "", fmt.Errorf(`failed to determine signing algorithm: %w`, err)
+			}
+			return alg, types.MessageTypeDigest, nil
+		case strings.HasPrefix(string(alg), `ECDSA_`):
+			alg, err := chooseSigningAlgorithm(alg, ecdsaSigningAlgorithm, digest, opts)
+			if err != nil {
+				return "", "", fmt.Errorf(`failed to determine signing algorithm: %w`, err)
+			}
+			return alg, types.MessageTypeDigest, nil
+		case strings.HasPrefix(string(alg), `ED25519_`):
+			derived, mt, err := eddsaSigningAlgorithm(digest, opts)
+			if err != nil {
+				return "", "", fmt.Errorf(`failed to determine signing algorithm: %w`, err)
+			}
+			if derived != alg {
+				return "", "", fmt.Errorf(`signing algorithm %q was explicitly configured, but opts requires %q`, alg, derived)
+			}
+			return alg, mt, nil
+		default:
+			return "", "", fmt.Errorf(`does not support signing algorithm %q`, alg)
+		}
+	}
+
+	info, err := sv.describeKey(ctx, kid)
+	if err != nil {
+		return "", "", fmt.Errorf(`failed to describe key: %w`, err)
+	}
+	return info.signingAlgorithm(digest, opts)
+}
+
+// describeKey retrieves the key spec and the signing algorithms of the key.
+// The public key is left empty.
+func (sv *Verifier) describeKey(ctx context.Context, kid string) (*keyInfo, error) {
+	if cache := sv.cache; cache != nil {
+		v, ok := cache.Get(describedKeyCacheKey(kid))
+		if ok {
+			if info, ok := v.(*keyInfo); ok {
+				return info, nil
+			}
+		}
+	}
+
+	output, err := sv.client.DescribeKey(ctx, &kms.DescribeKeyInput{
+		KeyId:       aws.String(kid),
+		GrantTokens: sv.grantTokens,
+	})
+	if err != nil {
+		return nil, fmt.Errorf(`failed to describe key via KMS: %w`, classifyError(err))
+	}
+
+	metadata := output.KeyMetadata
+	if metadata == nil {
+		return nil, fmt.Errorf(`KMS returned no metadata for key %q`, kid)
+	}
+	if metadata.KeyUsage != types.KeyUsageTypeSignVerify {
+		return nil, fmt.Errorf(`invalid key usage. expected %s, got %q: %w`, types.KeyUsageTypeSignVerify, metadata.KeyUsage, ErrWrongKeyUsage)
+	}
+
+	info := &keyInfo{
+		spec: metadata.KeySpec,
+		algs: metadata.SigningAlgorithms,
+	}
+
+	if cache := sv.cache; cache != nil {
+		cache.Set(describedKeyCacheKey(kid), info)
+	}
+
+	return info, nil
+}
+
+// KeyARN returns the ARN of the key. If the key ID is an alias, it is
+// resolved using KMS DescribeKey. When a Cache is provided, the result
+// is cached, and the alias is resolved again after the interval
+// specified via WithAliasRefreshInterval() has elapsed.
+func (sv *Verifier) KeyARN() (string, error) {
+	if sv.kid == "" {
+		return "", fmt.Errorf(`aws.Verifier.KeyARN() requires the key ID`)
+	}
+
+	// sv.ctx is NOT required, but we will use context.Background here
+	// which means there will not be a (clean) way to interrupt this
+	// operation
+	ctx := sv.getContext()
+
+	return resolveKeyARN(ctx, sv.client, sv.kid, sv.grantTokens, sv.cache, sv.aliasRefresh)
+}
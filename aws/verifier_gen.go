@@ -0,0 +1,104 @@
+package awssigner
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+)
+
+// WithAlgorithm associates a new types.SigningAlgorithmSpec with the object, which will be used for Verify().
+//
+// If it is not specified, the algorithm is derived from the key spec
+// retrieved using KMS DescribeKey, and the crypto.SignerOpts passed to
+// Verify(). If it is specified, DescribeKey is not called, and the
+// crypto.SignerOpts passed to Verify() must agree with it.
+func (cs *Verifier) WithAlgorithm(v types.SigningAlgorithmSpec) *Verifier {
+	return &Verifier{
+		client:       cs.client,
+		alg:          v,
+		aliasRefresh: cs.aliasRefresh,
+		cache:        cs.cache,
+		ctx:          cs.ctx,
+		grantTokens:  cs.grantTokens,
+		kid:          cs.kid,
+	}
+}
+
+// WithAliasRefreshInterval specifies how long an alias is considered to
+// point to the same key. This only has an effect when a Cache is provided:
+// aliases are then resolved to the key ARN using KMS DescribeKey, and the
+// cached items are stored under the key ARN, so that they do not become
+// stale when the alias is updated to point to a different key.
+//
+// If it is not specified, DefaultAliasRefreshInterval is used.
+func (cs *Verifier) WithAliasRefreshInterval(v time.Duration) *Verifier {
+	return &Verifier{
+		client:       cs.client,
+		alg:          cs.alg,
+		aliasRefresh: v,
+		cache:        cs.cache,
+		ctx:          cs.ctx,
+		grantTokens:  cs.grantTokens,
+		kid:          cs.kid,
+	}
+}
+
+// WithCache specifies the cache storage for frequently used items.
+// Currently the key spec and the signing algorithms are cached.
+//
+// If it is not specified, nothing will be cached.
+func (cs *Verifier) WithCache(v Cache) *Verifier {
+	return &Verifier{
+		client:       cs.client,
+		alg:          cs.alg,
+		aliasRefresh: cs.aliasRefresh,
+		cache:        v,
+		ctx:          cs.ctx,
+		grantTokens:  cs.grantTokens,
+		kid:          cs.kid,
+	}
+}
+
+// WithContext associates a new context.Context with the object, which will be used for Verify()
+func (cs *Verifier) WithContext(v context.Context) *Verifier {
+	return &Verifier{
+		client:       cs.client,
+		alg:          cs.alg,
+		aliasRefresh: cs.aliasRefresh,
+		cache:        cs.cache,
+		ctx:          v,
+		grantTokens:  cs.grantTokens,
+		kid:          cs.kid,
+	}
+}
+
+// WithGrantTokens specifies the grant tokens to send along with each request
+// to KMS. Grant tokens allow you to use the permissions granted by a
+// grant before the grant has achieved eventual consistency.
+//
+// See https://docs.aws.amazon.com/kms/latest/developerguide/grant-manage.html#using-grant-token
+func (cs *Verifier) WithGrantTokens(v []string) *Verifier {
+	return &Verifier{
+		client:       cs.client,
+		alg:          cs.alg,
+		aliasRefresh: cs.aliasRefresh,
+		cache:        cs.cache,
+		ctx:          cs.ctx,
+		grantTokens:  v,
+		kid:          cs.kid,
+	}
+}
+
+// WithKeyID associates a new string with the object, which will be used for Verify()
+func (cs *Verifier) WithKeyID(v string) *Verifier {
+	return &Verifier{
+		client:       cs.client,
+		alg:          cs.alg,
+		aliasRefresh: cs.aliasRefresh,
+		cache:        cs.cache,
+		ctx:          cs.ctx,
+		grantTokens:  cs.grantTokens,
+		kid:          v,
+	}
+}
@@ -0,0 +1,57 @@
+package awssigner_test
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha512"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+	awssigner "github.com/jwx-go/crypto-signer/v2/aws"
+)
+
+func TestVerifier(t *testing.T) {
+	client, kid := newTestKey(t, types.KeySpecRsa2048)
+	digest := sha512.Sum384([]byte("obla-di-obla-da"))
+	opts := &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA384}
+
+	signed, err := awssigner.NewRSA(client).WithKeyID(kid).Sign(rand.Reader, digest[:], opts)
+	if err != nil {
+		t.Fatalf("failed to sign: %s", err)
+	}
+
+	t.Run("algorithm from DescribeKey", func(t *testing.T) {
+		v := awssigner.NewVerifier(client).
+			WithKeyID(kid).
+			WithCache(NewDumbCache())
+		if err := v.Verify(digest[:], signed, opts); err != nil {
+			t.Fatalf("failed to verify: %s", err)
+		}
+
+		// PKCS #1 v1.5 is a different algorithm
+		var invalid *types.KMSInvalidSignatureException
+		if err := v.Verify(digest[:], signed, crypto.SHA384); !errors.As(err, &invalid) {
+			t.Fatalf("expected KMSInvalidSignatureException, got %v", err)
+		}
+	})
+	t.Run("configured algorithm", func(t *testing.T) {
+		v := awssigner.NewVerifier(client).
+			WithKeyID(kid).
+			WithAlgorithm(types.SigningAlgorithmSpecRsassaPssSha384)
+		if err := v.Verify(digest[:], signed, nil); err != nil {
+			t.Fatalf("failed to verify: %s", err)
+		}
+		if err := v.Verify(digest[:], signed, crypto.SHA384); err == nil {
+			t.Fatalf("opts that disagree with the configured algorithm should fail")
+		}
+
+		tampered := append([]byte(nil), digest[:]...)
+		tampered[0] ^= 0xff
+		var invalid *types.KMSInvalidSignatureException
+		if err := v.Verify(tampered, signed, opts); !errors.As(err, &invalid) {
+			t.Fatalf("expected KMSInvalidSignatureException, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,150 @@
+package gcpsigner
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"fmt"
+
+	"cloud.google.com/go/kms/apiv1/kmspb"
+)
+
+// signingAlgorithm describes how a CryptoKeyVersionAlgorithm signs digests
+type signingAlgorithm struct {
+	hash crypto.Hash
+	pss  bool
+}
+
+// signingAlgorithms lists the CryptoKeyVersionAlgorithms that sign digests
+var signingAlgorithms = map[kmspb.CryptoKeyVersion_CryptoKeyVersionAlgorithm]signingAlgorithm{
+	kmspb.CryptoKeyVersion_RSA_SIGN_PSS_2048_SHA256:   {hash: crypto.SHA256, pss: true},
+	kmspb.CryptoKeyVersion_RSA_SIGN_PSS_3072_SHA256:   {hash: crypto.SHA256, pss: true},
+	kmspb.CryptoKeyVersion_RSA_SIGN_PSS_4096_SHA256:   {hash: crypto.SHA256, pss: true},
+	kmspb.CryptoKeyVersion_RSA_SIGN_PSS_4096_SHA512:   {hash: crypto.SHA512, pss: true},
+	kmspb.CryptoKeyVersion_RSA_SIGN_PKCS1_2048_SHA256: {hash: crypto.SHA256},
+	kmspb.CryptoKeyVersion_RSA_SIGN_PKCS1_3072_SHA256: {hash: crypto.SHA256},
+	kmspb.CryptoKeyVersion_RSA_SIGN_PKCS1_4096_SHA256: {hash: crypto.SHA256},
+	kmspb.CryptoKeyVersion_RSA_SIGN_PKCS1_4096_SHA512: {hash: crypto.SHA512},
+	kmspb.CryptoKeyVersion_EC_SIGN_P256_SHA256:        {hash: crypto.SHA256},
+	kmspb.CryptoKeyVersion_EC_SIGN_P384_SHA384:        {hash: crypto.SHA384},
+	kmspb.CryptoKeyVersion_EC_SIGN_SECP256K1_SHA256:   {hash: crypto.SHA256},
+}
+
+// decryptionAlgorithms lists the RSAES-OAEP CryptoKeyVersionAlgorithms,
+// along with the hash function that each of them uses (for both OAEP and MGF1)
+var decryptionAlgorithms = map[kmspb.CryptoKeyVersion_CryptoKeyVersionAlgorithm]crypto.Hash{
+	kmspb.CryptoKeyVersion_RSA_DECRYPT_OAEP_2048_SHA256: crypto.SHA256,
+	kmspb.CryptoKeyVersion_RSA_DECRYPT_OAEP_3072_SHA256: crypto.SHA256,
+	kmspb.CryptoKeyVersion_RSA_DECRYPT_OAEP_4096_SHA256: crypto.SHA256,
+	kmspb.CryptoKeyVersion_RSA_DECRYPT_OAEP_4096_SHA512: crypto.SHA512,
+	kmspb.CryptoKeyVersion_RSA_DECRYPT_OAEP_2048_SHA1:   crypto.SHA1,
+	kmspb.CryptoKeyVersion_RSA_DECRYPT_OAEP_3072_SHA1:   crypto.SHA1,
+	kmspb.CryptoKeyVersion_RSA_DECRYPT_OAEP_4096_SHA1:   crypto.SHA1,
+}
+
+// checkDecrypterOpts checks that opts agree with the key version algorithm.
+// opts must be either nil, in which case the hash function of the algorithm
+// is assumed, or an *rsa.OAEPOptions whose hash function (and MGF1 hash
+// function, if specified) is the one that the algorithm uses. Cloud KMS
+// does not support labels.
+func checkDecrypterOpts(alg kmspb.CryptoKeyVersion_CryptoKeyVersionAlgorithm, opts crypto.DecrypterOpts) error {
+	hash, ok := decryptionAlgorithms[alg]
+	if !ok {
+		return fmt.Errorf(`unsupported key version algorithm %s`, alg)
+	}
+
+	switch opts := opts.(type) {
+	case nil:
+		return nil
+	case *rsa.OAEPOptions:
+		if opts.Hash != hash {
+			return fmt.Errorf(`key version algorithm %s requires %s, but opts specifies %s`, alg, hash, opts.Hash)
+		}
+		if opts.MGFHash != crypto.Hash(0) && opts.MGFHash != hash {
+			return fmt.Errorf(`key version algorithm %s requires %s for MGF1, but opts specifies %s`, alg, hash, opts.MGFHash)
+		}
+		if len(opts.Label) > 0 {
+			return fmt.Errorf(`OAEP labels are not supported by Cloud KMS`)
+		}
+		return nil
+	default:
+		return fmt.Errorf(`key version algorithm %s requires *rsa.OAEPOptions, got %T`, alg, opts)
+	}
+}
+
+// dataSigningAlgorithms lists the CryptoKeyVersionAlgorithms that sign
+// the message itself, which is sent using the data field of the request
+var dataSigningAlgorithms = map[kmspb.CryptoKeyVersion_CryptoKeyVersionAlgorithm]bool{
+	kmspb.CryptoKeyVersion_EC_SIGN_ED25519:           true,
+	kmspb.CryptoKeyVersion_PQ_SIGN_ML_DSA_65:         true,
+	kmspb.CryptoKeyVersion_PQ_SIGN_SLH_DSA_SHA2_128S: true,
+}
+
+// checkDataSigningOpts checks that opts agree with a key version algorithm
+// that signs the message itself. As with ed25519.PrivateKey,
+// opts.HashFunc() must return zero. Ed25519ph and Ed25519 contexts are
+// not supported by Cloud KMS, nor are pre-hashed ML-DSA and SLH-DSA.
+func checkDataSigningOpts(alg kmspb.CryptoKeyVersion_CryptoKeyVersionAlgorithm, opts crypto.SignerOpts) error {
+	if edopts, ok := opts.(*ed25519.Options); ok && edopts.Context != "" {
+		return fmt.Errorf(`Ed25519 contexts are not supported by Cloud KMS`)
+	}
+	if opts != nil && opts.HashFunc() != crypto.Hash(0) {
+		return fmt.Errorf(`key version algorithm %s signs the message itself, but opts specifies %s`, alg, opts.HashFunc())
+	}
+	return nil
+}
+
+// digestForSigning checks that digest and opts agree with the key version
+// algorithm, and returns the digest in the form that AsymmetricSign expects.
+//
+// The hash function is taken from opts.HashFunc(), and must be the one
+// that the algorithm uses. RSASSA-PSS algorithms require an
+// *rsa.PSSOptions whose salt length is rsa.PSSSaltLengthEqualsHash or the
+// length of the digest (which Cloud KMS always uses), and RSASSA-PKCS1-v1_5
+// algorithms require anything else.
+func digestForSigning(alg kmspb.CryptoKeyVersion_CryptoKeyVersionAlgorithm, digest []byte, opts crypto.SignerOpts) (*kmspb.Digest, error) {
+	params, ok := signingAlgorithms[alg]
+	if !ok {
+		return nil, fmt.Errorf(`key version algorithm %s cannot be used to sign digests`, alg)
+	}
+
+	var hash crypto.Hash
+	if opts != nil {
+		hash = opts.HashFunc()
+	}
+	if hash == crypto.Hash(0) {
+		return nil, fmt.Errorf(`opts.HashFunc() must specify the hash function used to compute the digest`)
+	}
+	if hash != params.hash {
+		return nil, fmt.Errorf(`key version algorithm %s requires %s, but opts specifies %s`, alg, params.hash, hash)
+	}
+
+	pss, isPSS := opts.(*rsa.PSSOptions)
+	switch {
+	case params.pss && !isPSS:
+		return nil, fmt.Errorf(`key version algorithm %s requires *rsa.PSSOptions`, alg)
+	case !params.pss && isPSS:
+		return nil, fmt.Errorf(`key version algorithm %s does not support RSASSA-PSS`, alg)
+	case isPSS:
+		switch pss.SaltLength {
+		case rsa.PSSSaltLengthEqualsHash, hash.Size():
+		default:
+			return nil, fmt.Errorf(`key version algorithm %s requires a salt length of %d, got %d`, alg, hash.Size(), pss.SaltLength)
+		}
+	}
+
+	if len(digest) != hash.Size() {
+		return nil, fmt.Errorf(`invalid digest length for %s: expected %d, got %d`, hash, hash.Size(), len(digest))
+	}
+
+	switch hash {
+	case crypto.SHA256:
+		return &kmspb.Digest{Digest: &kmspb.Digest_Sha256{Sha256: digest}}, nil
+	case crypto.SHA384:
+		return &kmspb.Digest{Digest: &kmspb.Digest_Sha384{Sha384: digest}}, nil
+	case crypto.SHA512:
+		return &kmspb.Digest{Digest: &kmspb.Digest_Sha512{Sha512: digest}}, nil
+	default:
+		return nil, fmt.Errorf(`unsupported hash function %s`, hash)
+	}
+}
@@ -0,0 +1,96 @@
+package gcpsigner_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"testing"
+
+	"cloud.google.com/go/kms/apiv1/kmspb"
+	gcpsigner "github.com/jwx-go/crypto-signer/v2/gcp"
+)
+
+func TestSigningAlgorithm(t *testing.T) {
+	client := newFakeKMS()
+	digest256 := sha256.Sum256([]byte("obla-di-obla-da"))
+	digest512 := sha512.Sum512([]byte("obla-di-obla-da"))
+	pss256 := &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA256}
+	pss512 := &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA512}
+
+	testcases := []struct {
+		Name      string
+		Algorithm kmspb.CryptoKeyVersion_CryptoKeyVersionAlgorithm
+		Digest    []byte
+		Opts      crypto.SignerOpts
+		Error     bool
+	}{
+		{Name: "RSA-3072 with SHA-256", Algorithm: kmspb.CryptoKeyVersion_RSA_SIGN_PKCS1_3072_SHA256, Digest: digest256[:], Opts: crypto.SHA256},
+		{Name: "RSA-4096 with SHA-512", Algorithm: kmspb.CryptoKeyVersion_RSA_SIGN_PKCS1_4096_SHA512, Digest: digest512[:], Opts: crypto.SHA512},
+		{Name: "RSA-4096 PSS with SHA-512", Algorithm: kmspb.CryptoKeyVersion_RSA_SIGN_PSS_4096_SHA512, Digest: digest512[:], Opts: pss512},
+		{Name: "PSS salt length equal to hash size", Algorithm: kmspb.CryptoKeyVersion_RSA_SIGN_PSS_2048_SHA256, Digest: digest256[:], Opts: &rsa.PSSOptions{SaltLength: sha256.Size, Hash: crypto.SHA256}},
+		{Name: "ECDSA", Algorithm: kmspb.CryptoKeyVersion_EC_SIGN_P256_SHA256, Digest: digest256[:], Opts: crypto.SHA256},
+		{Name: "wrong hash", Algorithm: kmspb.CryptoKeyVersion_RSA_SIGN_PKCS1_4096_SHA512, Digest: digest256[:], Opts: crypto.SHA256, Error: true},
+		{Name: "no hash", Algorithm: kmspb.CryptoKeyVersion_EC_SIGN_P256_SHA256, Digest: digest256[:], Opts: crypto.Hash(0), Error: true},
+		{Name: "PSS options for PKCS1 key", Algorithm: kmspb.CryptoKeyVersion_RSA_SIGN_PKCS1_2048_SHA256, Digest: digest256[:], Opts: pss256, Error: true},
+		{Name: "PKCS1 options for PSS key", Algorithm: kmspb.CryptoKeyVersion_RSA_SIGN_PSS_2048_SHA256, Digest: digest256[:], Opts: crypto.SHA256, Error: true},
+		{Name: "PSS salt length auto", Algorithm: kmspb.CryptoKeyVersion_RSA_SIGN_PSS_2048_SHA256, Digest: digest256[:], Opts: &rsa.PSSOptions{Hash: crypto.SHA256}, Error: true},
+		{Name: "PSS salt length", Algorithm: kmspb.CryptoKeyVersion_RSA_SIGN_PSS_2048_SHA256, Digest: digest256[:], Opts: &rsa.PSSOptions{SaltLength: 20, Hash: crypto.SHA256}, Error: true},
+		{Name: "digest length", Algorithm: kmspb.CryptoKeyVersion_EC_SIGN_P256_SHA256, Digest: digest256[:20], Opts: crypto.SHA256, Error: true},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.Name, func(t *testing.T) {
+			sv := gcpsigner.New(client).
+				WithName(client.addKey(t, tc.Algorithm)).
+				WithCache(NewDumbCache())
+
+			alg, err := sv.Algorithm()
+			if err != nil {
+				t.Fatalf("failed to get algorithm: %s", err)
+			}
+			if alg != tc.Algorithm {
+				t.Fatalf("expected algorithm %s, got %s", tc.Algorithm, alg)
+			}
+
+			calls := client.signCalls
+			signed, err := sv.Sign(rand.Reader, tc.Digest, tc.Opts)
+			if tc.Error {
+				if err == nil {
+					t.Fatalf("expected an error")
+				}
+				if client.signCalls != calls {
+					t.Fatalf("expected the request to be rejected before calling AsymmetricSign")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to sign: %s", err)
+			}
+
+			switch pubkey := sv.Public().(type) {
+			case *rsa.PublicKey:
+				if pss, ok := tc.Opts.(*rsa.PSSOptions); ok {
+					err = rsa.VerifyPSS(pubkey, tc.Opts.HashFunc(), tc.Digest, signed, pss)
+				} else {
+					err = rsa.VerifyPKCS1v15(pubkey, tc.Opts.HashFunc(), tc.Digest, signed)
+				}
+				if err != nil {
+					t.Fatalf("failed to verify signature: %s", err)
+				}
+			case *ecdsa.PublicKey:
+				if !ecdsa.VerifyASN1(pubkey, tc.Digest, signed) {
+					t.Fatalf("failed to verify signature")
+				}
+			}
+		})
+	}
+
+	t.Run("unknown key", func(t *testing.T) {
+		sv := gcpsigner.New(client).WithName(`projects/test/locations/global/keyRings/test/cryptoKeys/missing/cryptoKeyVersions/1`)
+		if _, err := sv.Sign(rand.Reader, digest256[:], crypto.SHA256); err == nil {
+			t.Fatalf("expected the GetPublicKey error to be returned")
+		}
+	})
+}
@@ -0,0 +1,126 @@
+package gcpsigner
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"fmt"
+	"io"
+
+	"cloud.google.com/go/kms/apiv1/kmspb"
+)
+
+// Decrypter is a crypto.Decrypter for Cloud KMS key versions with the
+// ASYMMETRIC_DECRYPT purpose (RSA_DECRYPT_OAEP_*). The private key never
+// leaves Cloud KMS: the ciphertext is sent to AsymmetricDecrypt, and the
+// plaintext is returned.
+type Decrypter struct {
+	cache              Cache
+	client             Client
+	ctx                context.Context
+	name               string
+	skipIntegrityCheck bool
+}
+
+// NewDecrypter creates a new Decrypter object. This object is not complete
+// by itself -- it needs to be setup with the name of the key version, and
+// a context.Context object to use while the Cloud KMS client makes network
+// requests.
+func NewDecrypter(client Client) *Decrypter {
+	return &Decrypter{
+		client: client,
+	}
+}
+
+func (sv *Decrypter) getContext() context.Context {
+	ctx := sv.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return ctx
+}
+
+// Decrypt decrypts the given ciphertext using the Cloud KMS
+// AsymmetricDecrypt API.
+//
+// opts must be either nil or an *rsa.OAEPOptions whose hash function
+// matches the algorithm of the key version (e.g. crypto.SHA256 for
+// RSA_DECRYPT_OAEP_3072_SHA256). The algorithm is retrieved along with the
+// public key, so use WithCache() to avoid retrieving it for every call.
+func (cs *Decrypter) Decrypt(_ io.Reader, ciphertext []byte, opts crypto.DecrypterOpts) ([]byte, error) {
+	info, err := cs.getPublicKeyInfo()
+	if err != nil {
+		return nil, fmt.Errorf(`failed to retrieve public key: %w`, err)
+	}
+
+	if err := checkDecrypterOpts(info.algorithm, opts); err != nil {
+		return nil, fmt.Errorf(`failed to decrypt: %w`, err)
+	}
+
+	req := &kmspb.AsymmetricDecryptRequest{
+		Name:       cs.name,
+		Ciphertext: ciphertext,
+	}
+	if !cs.skipIntegrityCheck {
+		req.CiphertextCrc32C = crc32c(ciphertext)
+	}
+
+	// cs.ctx is NOT required, but we will use context.Background here
+	// which means there will not be a (clean) way to interrupt this
+	// operation
+	ctx := cs.getContext()
+
+	res, err := cs.client.AsymmetricDecrypt(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf(`failed to decrypt: %w`, err)
+	}
+
+	if !cs.skipIntegrityCheck {
+		if err := checkDecryptResponse(req, res); err != nil {
+			return nil, fmt.Errorf(`failed to decrypt: %w`, err)
+		}
+	}
+
+	return res.Plaintext, nil
+}
+
+// Public returns the corresponding public key.
+//
+// Because the crypto.Decrypter API does not allow for an error to be returned,
+// the return value from this function cannot describe what kind of error
+// occurred.
+func (cs *Decrypter) Public() crypto.PublicKey {
+	key, _ := cs.GetPublicKey()
+	return key
+}
+
+// This method is an escape hatch for those cases where the user needs
+// to debug what went wrong during the GetPublicKey operation.
+func (cs *Decrypter) GetPublicKey() (crypto.PublicKey, error) {
+	info, err := cs.getPublicKeyInfo()
+	if err != nil {
+		return nil, err
+	}
+	return info.key, nil
+}
+
+// Algorithm returns the algorithm of the key version, such as
+// kmspb.CryptoKeyVersion_RSA_DECRYPT_OAEP_3072_SHA256
+func (cs *Decrypter) Algorithm() (kmspb.CryptoKeyVersion_CryptoKeyVersionAlgorithm, error) {
+	info, err := cs.getPublicKeyInfo()
+	if err != nil {
+		return kmspb.CryptoKeyVersion_CRYPTO_KEY_VERSION_ALGORITHM_UNSPECIFIED, err
+	}
+	return info.algorithm, nil
+}
+
+func (cs *Decrypter) getPublicKeyInfo() (*publicKeyInfo, error) {
+	info, err := fetchPublicKeyInfo(cs.getContext(), cs.client, cs.cache, cs.name, kmspb.PublicKey_PUBLIC_KEY_FORMAT_UNSPECIFIED, cs.skipIntegrityCheck)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := info.key.(*rsa.PublicKey); !ok {
+		return nil, fmt.Errorf(`expected *rsa.PublicKey, got %T`, info.key)
+	}
+	return info, nil
+}
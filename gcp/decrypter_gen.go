@@ -0,0 +1,61 @@
+package gcpsigner
+
+import "context"
+
+// WithCache specifies the cache storage for frequently used items.
+// Currently only the public key (along with the key version algorithm)
+// is cached. The cache can be shared with Signer objects.
+//
+// If it is not specified, nothing will be cached, and the key version
+// algorithm will be retrieved from Cloud KMS for every decryption.
+func (cs *Decrypter) WithCache(v Cache) *Decrypter {
+	return &Decrypter{
+		client:             cs.client,
+		cache:              v,
+		ctx:                cs.ctx,
+		name:               cs.name,
+		skipIntegrityCheck: cs.skipIntegrityCheck,
+	}
+}
+
+// WithContext associates a new context.Context with the object, which
+// will be used for Decrypt() and Public()
+func (cs *Decrypter) WithContext(v context.Context) *Decrypter {
+	return &Decrypter{
+		client:             cs.client,
+		cache:              cs.cache,
+		ctx:                v,
+		name:               cs.name,
+		skipIntegrityCheck: cs.skipIntegrityCheck,
+	}
+}
+
+// WithName associates a new string with the object, which will be used for Sign() and Public()
+func (cs *Decrypter) WithName(v string) *Decrypter {
+	return &Decrypter{
+		client:             cs.client,
+		cache:              cs.cache,
+		ctx:                cs.ctx,
+		name:               v,
+		skipIntegrityCheck: cs.skipIntegrityCheck,
+	}
+}
+
+// WithSkipIntegrityCheck specifies whether to skip the CRC32C based
+// end-to-end integrity checks that are performed by default. When they
+// are enabled, the CRC32C checksum of the ciphertext is sent along with
+// each request, and the checksums in the responses of AsymmetricDecrypt
+// and GetPublicKey are checked. A failed check is reported as an
+// *IntegrityError.
+//
+// Only skip the checks when talking to something other than Cloud KMS
+// that does not support them.
+func (cs *Decrypter) WithSkipIntegrityCheck(v bool) *Decrypter {
+	return &Decrypter{
+		client:             cs.client,
+		cache:              cs.cache,
+		ctx:                cs.ctx,
+		name:               cs.name,
+		skipIntegrityCheck: v,
+	}
+}
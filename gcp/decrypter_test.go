@@ -0,0 +1,116 @@
+package gcpsigner_test
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"testing"
+
+	"cloud.google.com/go/kms/apiv1/kmspb"
+	gcpsigner "github.com/jwx-go/crypto-signer/v2/gcp"
+	"github.com/jwx-go/crypto-signer/v2/gcp/jwxadapter"
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwe"
+	"google.golang.org/protobuf/proto"
+)
+
+var _ crypto.Decrypter = &gcpsigner.Decrypter{}
+
+func TestDecrypter(t *testing.T) {
+	client := newFakeKMS()
+	dec := gcpsigner.NewDecrypter(client).
+		WithName(client.addKey(t, kmspb.CryptoKeyVersion_RSA_DECRYPT_OAEP_2048_SHA256)).
+		WithCache(NewDumbCache())
+
+	key, err := dec.GetPublicKey()
+	if err != nil {
+		t.Fatalf("failed to get public key: %s", err)
+	}
+	pubkey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		t.Fatalf("expected *rsa.PublicKey, got %T", key)
+	}
+
+	payload := []byte("obla-di-obla-da")
+	ciphertext, err := rsa.EncryptOAEP(crypto.SHA256.New(), rand.Reader, pubkey, payload, nil)
+	if err != nil {
+		t.Fatalf("failed to encrypt: %s", err)
+	}
+
+	for _, opts := range []crypto.DecrypterOpts{nil, &rsa.OAEPOptions{Hash: crypto.SHA256}, &rsa.OAEPOptions{Hash: crypto.SHA256, MGFHash: crypto.SHA256}} {
+		decrypted, err := dec.Decrypt(rand.Reader, ciphertext, opts)
+		if err != nil {
+			t.Fatalf("failed to decrypt with %#v: %s", opts, err)
+		}
+		if !bytes.Equal(payload, decrypted) {
+			t.Fatalf("payload and decrypted does not match")
+		}
+	}
+
+	t.Run("Rejected options", func(t *testing.T) {
+		testcases := []struct {
+			Name string
+			Opts crypto.DecrypterOpts
+		}{
+			{Name: "wrong hash", Opts: &rsa.OAEPOptions{Hash: crypto.SHA1}},
+			{Name: "MGF1 hash mismatch", Opts: &rsa.OAEPOptions{Hash: crypto.SHA256, MGFHash: crypto.SHA1}},
+			{Name: "label", Opts: &rsa.OAEPOptions{Hash: crypto.SHA256, Label: []byte("label")}},
+			{Name: "PKCS1v15", Opts: &rsa.PKCS1v15DecryptOptions{}},
+		}
+		for _, tc := range testcases {
+			t.Run(tc.Name, func(t *testing.T) {
+				if _, err := dec.Decrypt(rand.Reader, ciphertext, tc.Opts); err == nil {
+					t.Fatalf("expected an error")
+				}
+			})
+		}
+	})
+
+	t.Run("Integrity check", func(t *testing.T) {
+		client.corrupt = func(msg proto.Message) {
+			if res, ok := msg.(*kmspb.AsymmetricDecryptResponse); ok {
+				res.Plaintext[0] ^= 0xff
+			}
+		}
+		defer func() { client.corrupt = nil }()
+
+		_, err := dec.Decrypt(rand.Reader, ciphertext, nil)
+		var ierr *gcpsigner.IntegrityError
+		if !errors.As(err, &ierr) || ierr.Field != `plaintext_crc32c` {
+			t.Fatalf("expected an integrity error on plaintext_crc32c, got %v", err)
+		}
+	})
+
+	t.Run("JWE", func(t *testing.T) {
+		encrypted, err := jwe.Encrypt(payload, jwe.WithKey(jwa.RSA_OAEP_256, pubkey))
+		if err != nil {
+			t.Fatalf("failed to encrypt: %s", err)
+		}
+
+		decrypted, err := jwe.Decrypt(encrypted, jwe.WithKey(jwa.RSA_OAEP_256, jwxadapter.KeyDecrypter(dec)))
+		if err != nil {
+			t.Fatalf("failed to decrypt: %s", err)
+		}
+		if !bytes.Equal(payload, decrypted) {
+			t.Fatalf("payload and decrypted does not match")
+		}
+
+		encrypted, err = jwe.Encrypt(payload, jwe.WithKey(jwa.RSA_OAEP, pubkey))
+		if err != nil {
+			t.Fatalf("failed to encrypt: %s", err)
+		}
+		if _, err := jwe.Decrypt(encrypted, jwe.WithKey(jwa.RSA_OAEP, jwxadapter.KeyDecrypter(dec))); err == nil {
+			t.Fatalf("RSA-OAEP does not match RSA_DECRYPT_OAEP_2048_SHA256, and should fail")
+		}
+	})
+
+	t.Run("Signing key", func(t *testing.T) {
+		dec := gcpsigner.NewDecrypter(client).
+			WithName(client.addKey(t, kmspb.CryptoKeyVersion_EC_SIGN_P256_SHA256))
+		if _, err := dec.Decrypt(rand.Reader, ciphertext, nil); err == nil {
+			t.Fatalf("expected an error")
+		}
+	})
+}
@@ -0,0 +1,104 @@
+package gcpsigner_test
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha512"
+	"errors"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/kms/apiv1/kmspb"
+	gcpsigner "github.com/jwx-go/crypto-signer/v2/gcp"
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jws"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestEd25519(t *testing.T) {
+	client := newFakeKMS()
+	sv := gcpsigner.New(client).
+		WithName(client.addKey(t, kmspb.CryptoKeyVersion_EC_SIGN_ED25519)).
+		WithCache(NewDumbCache()).
+		WithSelfVerify(true)
+
+	pubkey, ok := sv.Public().(ed25519.PublicKey)
+	if !ok {
+		t.Fatalf("expected ed25519.PublicKey, got %T", sv.Public())
+	}
+
+	t.Run("Sign", func(t *testing.T) {
+		message := []byte("obla-di-obla-da")
+		signed, err := sv.Sign(rand.Reader, message, crypto.Hash(0))
+		if err != nil {
+			t.Fatalf("failed to sign: %s", err)
+		}
+		if !ed25519.Verify(pubkey, message, signed) {
+			t.Fatalf("failed to verify signature")
+		}
+
+		signed, err = sv.Sign(rand.Reader, message, &ed25519.Options{})
+		if err != nil {
+			t.Fatalf("failed to sign with *ed25519.Options: %s", err)
+		}
+		if !ed25519.Verify(pubkey, message, signed) {
+			t.Fatalf("failed to verify signature")
+		}
+	})
+	t.Run("Empty message", func(t *testing.T) {
+		// the data field of the request is nil, which must not be
+		// mistaken for a digest by the integrity checks
+		signed, err := sv.Sign(rand.Reader, nil, crypto.Hash(0))
+		if err != nil {
+			t.Fatalf("failed to sign: %s", err)
+		}
+		if !ed25519.Verify(pubkey, nil, signed) {
+			t.Fatalf("failed to verify signature")
+		}
+	})
+	t.Run("Integrity check", func(t *testing.T) {
+		client.corrupt = func(msg proto.Message) {
+			if res, ok := msg.(*kmspb.AsymmetricSignResponse); ok {
+				res.VerifiedDataCrc32C = false
+			}
+		}
+		defer func() { client.corrupt = nil }()
+
+		_, err := sv.Sign(rand.Reader, []byte("obla-di-obla-da"), crypto.Hash(0))
+		var ierr *gcpsigner.IntegrityError
+		if !errors.As(err, &ierr) || ierr.Field != `verified_data_crc32c` {
+			t.Fatalf("expected an integrity error on verified_data_crc32c, got %v", err)
+		}
+		if !strings.HasPrefix(err.Error(), `failed to sign data: `) {
+			t.Fatalf("expected the error to say that the data was being signed, got %q", err)
+		}
+	})
+	t.Run("Rejected options", func(t *testing.T) {
+		digest := sha512.Sum512([]byte("obla-di-obla-da"))
+		for _, opts := range []crypto.SignerOpts{crypto.SHA512, &ed25519.Options{Hash: crypto.SHA512}, &ed25519.Options{Context: "ctx"}} {
+			calls := client.signCalls
+			if _, err := sv.Sign(rand.Reader, digest[:], opts); err == nil {
+				t.Fatalf("expected an error for %#v", opts)
+			}
+			if client.signCalls != calls {
+				t.Fatalf("expected the request to be rejected before calling AsymmetricSign")
+			}
+		}
+	})
+	t.Run("JWS", func(t *testing.T) {
+		payload := []byte("obla-di-obla-da")
+		signed, err := jws.Sign(payload, jws.WithKey(jwa.EdDSA, sv))
+		if err != nil {
+			t.Fatalf("failed to sign JWS: %s", err)
+		}
+
+		verified, err := jws.Verify(signed, jws.WithKey(jwa.EdDSA, pubkey))
+		if err != nil {
+			t.Fatalf("failed to verify JWS: %s", err)
+		}
+		if string(verified) != string(payload) {
+			t.Fatalf("expected payload %q, got %q", payload, verified)
+		}
+	})
+}
@@ -0,0 +1,44 @@
+package gcpsigner_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"math/big"
+	"testing"
+
+	"cloud.google.com/go/kms/apiv1/kmspb"
+	gcpsigner "github.com/jwx-go/crypto-signer/v2/gcp"
+)
+
+// The encoding itself is tested in internal/ecdsaenc. This test makes
+// sure that the options are applied to the signatures returned by Cloud
+// KMS.
+func TestSignatureEncoding(t *testing.T) {
+	client := newFakeKMS()
+	digest := sha256.Sum256([]byte("obla-di-obla-da"))
+
+	sv := gcpsigner.New(client).
+		WithName(client.addKey(t, kmspb.CryptoKeyVersion_EC_SIGN_SECP256K1_SHA256)).
+		WithCache(NewDumbCache()).
+		WithSignatureEncoding(gcpsigner.SignatureEncodingRS).
+		WithLowS(true)
+	pubkey := sv.Public().(*ecdsa.PublicKey)
+
+	signed, err := sv.Sign(rand.Reader, digest[:], crypto.SHA256)
+	if err != nil {
+		t.Fatalf("failed to sign: %s", err)
+	}
+	if len(signed) != 64 {
+		t.Fatalf("expected a 64 byte signature, got %d bytes", len(signed))
+	}
+	r := new(big.Int).SetBytes(signed[:32])
+	s := new(big.Int).SetBytes(signed[32:])
+	if s.Cmp(new(big.Int).Rsh(pubkey.Curve.Params().N, 1)) > 0 {
+		t.Fatalf("expected a low-S signature")
+	}
+	if !ecdsa.Verify(pubkey, digest[:], r, s) {
+		t.Fatalf("failed to verify signature")
+	}
+}
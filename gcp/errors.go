@@ -0,0 +1,8 @@
+package gcpsigner
+
+import "errors"
+
+// ErrSignatureMismatch means that a signature returned by Cloud KMS did
+// not verify against the public key of the key version that was used. It
+// is only reported when self-verification is enabled using WithSelfVerify().
+var ErrSignatureMismatch = errors.New(`signature does not verify against the public key`)
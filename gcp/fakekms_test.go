@@ -0,0 +1,331 @@
+package gcpsigner_test
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"fmt"
+	"hash/crc32"
+	"strings"
+	"sync"
+	"testing"
+
+	"cloud.google.com/go/kms/apiv1/kmspb"
+	"github.com/cloudflare/circl/sign"
+	"github.com/cloudflare/circl/sign/mldsa/mldsa65"
+	"github.com/cloudflare/circl/sign/slhdsa"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/googleapis/gax-go/v2"
+	gcpsigner "github.com/jwx-go/crypto-signer/v2/gcp"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// fakeKMS is a minimal in-memory stand-in for Cloud KMS, which signs
+// using real keys so that the signatures can be verified
+type fakeKMS struct {
+	mu        sync.Mutex
+	keys      map[string]*fakeKey
+	tamper    func([]byte) []byte
+	corrupt   func(proto.Message)
+	signCalls int
+}
+
+type fakeKey struct {
+	alg  kmspb.CryptoKeyVersion_CryptoKeyVersionAlgorithm
+	priv crypto.Signer
+}
+
+var _ gcpsigner.Client = &fakeKMS{}
+
+func newFakeKMS() *fakeKMS {
+	return &fakeKMS{
+		keys: make(map[string]*fakeKey),
+	}
+}
+
+// addKey generates a key for the given algorithm, and returns its name
+func (c *fakeKMS) addKey(t *testing.T, alg kmspb.CryptoKeyVersion_CryptoKeyVersionAlgorithm) string {
+	t.Helper()
+
+	var priv crypto.Signer
+	var err error
+	switch alg {
+	case kmspb.CryptoKeyVersion_RSA_SIGN_PKCS1_2048_SHA256, kmspb.CryptoKeyVersion_RSA_SIGN_PSS_2048_SHA256:
+		priv, err = rsa.GenerateKey(rand.Reader, 2048)
+	case kmspb.CryptoKeyVersion_RSA_DECRYPT_OAEP_2048_SHA256, kmspb.CryptoKeyVersion_RSA_DECRYPT_OAEP_2048_SHA1:
+		priv, err = rsa.GenerateKey(rand.Reader, 2048)
+	case kmspb.CryptoKeyVersion_RSA_SIGN_PKCS1_3072_SHA256:
+		priv, err = rsa.GenerateKey(rand.Reader, 3072)
+	case kmspb.CryptoKeyVersion_RSA_SIGN_PKCS1_4096_SHA512, kmspb.CryptoKeyVersion_RSA_SIGN_PSS_4096_SHA512, kmspb.CryptoKeyVersion_RSA_DECRYPT_OAEP_4096_SHA512:
+		priv, err = rsa.GenerateKey(rand.Reader, 4096)
+	case kmspb.CryptoKeyVersion_EC_SIGN_P256_SHA256:
+		priv, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case kmspb.CryptoKeyVersion_EC_SIGN_P384_SHA384:
+		priv, err = ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	case kmspb.CryptoKeyVersion_EC_SIGN_SECP256K1_SHA256:
+		var key *secp256k1.PrivateKey
+		key, err = secp256k1.GeneratePrivateKey()
+		if err == nil {
+			priv = key.ToECDSA()
+		}
+	case kmspb.CryptoKeyVersion_EC_SIGN_ED25519:
+		_, priv, err = ed25519.GenerateKey(rand.Reader)
+	case kmspb.CryptoKeyVersion_PQ_SIGN_ML_DSA_65:
+		_, priv, err = mldsa65.Scheme().GenerateKey()
+	case kmspb.CryptoKeyVersion_PQ_SIGN_SLH_DSA_SHA2_128S:
+		_, priv, err = slhdsa.SHA2_128s.Scheme().GenerateKey()
+	default:
+		t.Fatalf("unsupported algorithm %s", alg)
+	}
+	if err != nil {
+		t.Fatalf("failed to generate key: %s", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	name := fmt.Sprintf(`projects/test/locations/global/keyRings/test/cryptoKeys/key-%d/cryptoKeyVersions/1`, len(c.keys)+1)
+	c.keys[name] = &fakeKey{alg: alg, priv: priv}
+	return name
+}
+
+func (c *fakeKMS) lookup(name string) (*fakeKey, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key, ok := c.keys[name]
+	if !ok {
+		return nil, fmt.Errorf(`key %q not found`, name)
+	}
+	return key, nil
+}
+
+func (c *fakeKMS) AsymmetricSign(_ context.Context, req *kmspb.AsymmetricSignRequest, _ ...gax.CallOption) (*kmspb.AsymmetricSignResponse, error) {
+	key, err := c.lookup(req.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.signCalls++
+	c.mu.Unlock()
+
+	if req.Digest == nil {
+		return c.signData(key, req)
+	}
+
+	var digest []byte
+	var hash crypto.Hash
+	switch d := req.Digest.GetDigest().(type) {
+	case *kmspb.Digest_Sha256:
+		digest, hash = d.Sha256, crypto.SHA256
+	case *kmspb.Digest_Sha384:
+		digest, hash = d.Sha384, crypto.SHA384
+	case *kmspb.Digest_Sha512:
+		digest, hash = d.Sha512, crypto.SHA512
+	default:
+		return nil, fmt.Errorf(`missing digest`)
+	}
+
+	if hash != fakeAlgorithmHash(key.alg) {
+		return nil, fmt.Errorf(`the digest type does not match the algorithm %s`, key.alg)
+	}
+
+	var opts crypto.SignerOpts = hash
+	if strings.HasPrefix(key.alg.String(), `RSA_SIGN_PSS_`) {
+		opts = &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: hash}
+	}
+	signature, err := key.priv.Sign(rand.Reader, digest, opts)
+	if err != nil {
+		return nil, err
+	}
+	signature = c.tamperSignature(signature)
+
+	res := &kmspb.AsymmetricSignResponse{
+		Signature:            signature,
+		SignatureCrc32C:      fakeCRC32C(signature),
+		VerifiedDigestCrc32C: req.DigestCrc32C != nil,
+		Name:                 req.Name,
+	}
+	if req.DigestCrc32C != nil && req.DigestCrc32C.Value != fakeCRC32C(digest).Value {
+		return nil, fmt.Errorf(`digest_crc32c does not match the digest`)
+	}
+	c.corruptResponse(res)
+	return res, nil
+}
+
+func (c *fakeKMS) AsymmetricDecrypt(_ context.Context, req *kmspb.AsymmetricDecryptRequest, _ ...gax.CallOption) (*kmspb.AsymmetricDecryptResponse, error) {
+	key, err := c.lookup(req.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	priv, ok := key.priv.(*rsa.PrivateKey)
+	if !ok || !strings.HasPrefix(key.alg.String(), `RSA_DECRYPT_OAEP_`) {
+		return nil, fmt.Errorf(`algorithm %s does not decrypt`, key.alg)
+	}
+	if req.CiphertextCrc32C != nil && req.CiphertextCrc32C.Value != fakeCRC32C(req.Ciphertext).Value {
+		return nil, fmt.Errorf(`ciphertext_crc32c does not match the ciphertext`)
+	}
+
+	plaintext, err := priv.Decrypt(rand.Reader, req.Ciphertext, &rsa.OAEPOptions{Hash: fakeAlgorithmHash(key.alg)})
+	if err != nil {
+		return nil, err
+	}
+
+	res := &kmspb.AsymmetricDecryptResponse{
+		Plaintext:                plaintext,
+		PlaintextCrc32C:          fakeCRC32C(plaintext),
+		VerifiedCiphertextCrc32C: req.CiphertextCrc32C != nil,
+	}
+	c.corruptResponse(res)
+	return res, nil
+}
+
+// signData handles requests that carry the message itself, rather than
+// a digest
+func (c *fakeKMS) signData(key *fakeKey, req *kmspb.AsymmetricSignRequest) (*kmspb.AsymmetricSignResponse, error) {
+	if fakeAlgorithmHash(key.alg) != crypto.Hash(0) {
+		return nil, fmt.Errorf(`algorithm %s does not sign data`, key.alg)
+	}
+	if req.DataCrc32C != nil && req.DataCrc32C.Value != fakeCRC32C(req.Data).Value {
+		return nil, fmt.Errorf(`data_crc32c does not match the data`)
+	}
+
+	signature, err := key.priv.Sign(rand.Reader, req.Data, crypto.Hash(0))
+	if err != nil {
+		return nil, err
+	}
+	signature = c.tamperSignature(signature)
+
+	res := &kmspb.AsymmetricSignResponse{
+		Signature:          signature,
+		SignatureCrc32C:    fakeCRC32C(signature),
+		VerifiedDataCrc32C: req.DataCrc32C != nil,
+		Name:               req.Name,
+	}
+	c.corruptResponse(res)
+	return res, nil
+}
+
+// tamperSignature applies tamper to signature, if set
+func (c *fakeKMS) tamperSignature(signature []byte) []byte {
+	c.mu.Lock()
+	tamper := c.tamper
+	c.mu.Unlock()
+	if tamper != nil {
+		return tamper(signature)
+	}
+	return signature
+}
+
+// corruptResponse applies corrupt to res, if set
+func (c *fakeKMS) corruptResponse(res proto.Message) {
+	c.mu.Lock()
+	corrupt := c.corrupt
+	c.mu.Unlock()
+	if corrupt != nil {
+		corrupt(res)
+	}
+}
+
+func (c *fakeKMS) GetPublicKey(_ context.Context, req *kmspb.GetPublicKeyRequest, _ ...gax.CallOption) (*kmspb.PublicKey, error) {
+	key, err := c.lookup(req.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	// Cloud KMS requires NIST_PQC for post-quantum keys, and rejects it
+	// for all others
+	pqc := strings.HasPrefix(key.alg.String(), `PQ_SIGN_`)
+	if pqc != (req.PublicKeyFormat == kmspb.PublicKey_NIST_PQC) {
+		return nil, status.Errorf(codes.InvalidArgument, `public key format %s is not supported for %s`, req.PublicKeyFormat, key.alg)
+	}
+	if pqc {
+		data, err := key.priv.Public().(sign.PublicKey).MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		res := &kmspb.PublicKey{
+			PublicKey: &kmspb.ChecksummedData{
+				Data:           data,
+				Crc32CChecksum: fakeCRC32C(data),
+			},
+			PublicKeyFormat: kmspb.PublicKey_NIST_PQC,
+			Algorithm:       key.alg,
+			Name:            req.Name,
+		}
+		c.corruptResponse(res)
+		return res, nil
+	}
+
+	der, err := fakeMarshalPublicKey(key.priv.Public())
+	if err != nil {
+		return nil, err
+	}
+
+	pemBytes := pem.EncodeToMemory(&pem.Block{Type: `PUBLIC KEY`, Bytes: der})
+	res := &kmspb.PublicKey{
+		Pem:       string(pemBytes),
+		PemCrc32C: fakeCRC32C(pemBytes),
+		Algorithm: key.alg,
+		Name:      req.Name,
+	}
+	c.corruptResponse(res)
+	return res, nil
+}
+
+// fakeMarshalPublicKey is x509.MarshalPKIXPublicKey, plus support for
+// secp256k1 keys
+func fakeMarshalPublicKey(pubkey crypto.PublicKey) ([]byte, error) {
+	if ecpub, ok := pubkey.(*ecdsa.PublicKey); ok && ecpub.Curve == secp256k1.S256() {
+		var x, y secp256k1.FieldVal
+		x.SetByteSlice(ecpub.X.Bytes())
+		y.SetByteSlice(ecpub.Y.Bytes())
+		params, err := asn1.Marshal(asn1.ObjectIdentifier{1, 3, 132, 0, 10})
+		if err != nil {
+			return nil, err
+		}
+		return asn1.Marshal(struct {
+			Algorithm pkix.AlgorithmIdentifier
+			PublicKey asn1.BitString
+		}{
+			Algorithm: pkix.AlgorithmIdentifier{
+				Algorithm:  asn1.ObjectIdentifier{1, 2, 840, 10045, 2, 1},
+				Parameters: asn1.RawValue{FullBytes: params},
+			},
+			PublicKey: asn1.BitString{Bytes: secp256k1.NewPublicKey(&x, &y).SerializeUncompressed(), BitLength: 65 * 8},
+		})
+	}
+	return x509.MarshalPKIXPublicKey(pubkey)
+}
+
+func fakeCRC32C(data []byte) *wrapperspb.Int64Value {
+	return wrapperspb.Int64(int64(crc32.Checksum(data, crc32.MakeTable(crc32.Castagnoli))))
+}
+
+// fakeAlgorithmHash returns the hash function used by alg
+func fakeAlgorithmHash(alg kmspb.CryptoKeyVersion_CryptoKeyVersionAlgorithm) crypto.Hash {
+	name := alg.String()
+	switch {
+	case strings.HasSuffix(name, `_SHA1`):
+		return crypto.SHA1
+	case strings.HasSuffix(name, `_SHA256`):
+		return crypto.SHA256
+	case strings.HasSuffix(name, `_SHA384`):
+		return crypto.SHA384
+	case strings.HasSuffix(name, `_SHA512`):
+		return crypto.SHA512
+	default:
+		return crypto.Hash(0)
+	}
+}
@@ -2,6 +2,14 @@
 
 package gcpsigner
 
+import (
+	"context"
+
+	kms "cloud.google.com/go/kms/apiv1"
+	"cloud.google.com/go/kms/apiv1/kmspb"
+	"github.com/googleapis/gax-go/v2"
+)
+
 // Cache is used internally to store items that are frequently
 // accessed. In particular, the public key is accessed for both
 // signing _and_ verifying, and is cached if you provide storage for it.
@@ -9,3 +17,16 @@ type Cache interface {
 	Get(interface{}) (interface{}, bool)
 	Set(interface{}, interface{})
 }
+
+// Client is the subset of the Cloud KMS API that is used by the objects
+// in this package. *kms.KeyManagementClient satisfies this interface.
+//
+// Accepting an interface allows you to wrap the client (e.g. to add
+// instrumentation), or to replace it altogether in tests.
+type Client interface {
+	AsymmetricDecrypt(context.Context, *kmspb.AsymmetricDecryptRequest, ...gax.CallOption) (*kmspb.AsymmetricDecryptResponse, error)
+	AsymmetricSign(context.Context, *kmspb.AsymmetricSignRequest, ...gax.CallOption) (*kmspb.AsymmetricSignResponse, error)
+	GetPublicKey(context.Context, *kmspb.GetPublicKeyRequest, ...gax.CallOption) (*kmspb.PublicKey, error)
+}
+
+var _ Client = (*kms.KeyManagementClient)(nil)
@@ -0,0 +1,93 @@
+package gcpsigner
+
+import (
+	"fmt"
+	"hash/crc32"
+
+	"cloud.google.com/go/kms/apiv1/kmspb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+// IntegrityError is returned when the data exchanged with Cloud KMS fails
+// one of the end-to-end integrity checks: a CRC32C checksum does not match
+// the data, Cloud KMS did not verify the checksum that was sent along with
+// the request, or the response is for a different key version. See
+// https://cloud.google.com/kms/docs/data-integrity-guidelines
+//
+// Use errors.As to tell these errors apart from others, e.g. to retry
+// the request.
+type IntegrityError struct {
+	// Method is the Cloud KMS method that was called, such as "AsymmetricSign"
+	Method string
+	// Field is the field of the response that failed the check, such as
+	// "signature_crc32c"
+	Field string
+	// Name is the name of the key version
+	Name string
+}
+
+func (e *IntegrityError) Error() string {
+	return fmt.Sprintf(`integrity check of %s response for %q failed on %s`, e.Method, e.Name, e.Field)
+}
+
+// crc32c computes the CRC32C checksum of data, as expected by Cloud KMS
+func crc32c(data []byte) *wrapperspb.Int64Value {
+	return wrapperspb.Int64(int64(crc32.Checksum(data, crc32cTable)))
+}
+
+// checkCRC32C returns true if checksum matches data. A missing checksum
+// does not match.
+func checkCRC32C(data []byte, checksum *wrapperspb.Int64Value) bool {
+	return checksum != nil && checksum.Value == int64(crc32.Checksum(data, crc32cTable))
+}
+
+// checkSignResponse performs the integrity checks on the response to an
+// AsymmetricSign request, which must have been sent with a data_crc32c if
+// signsData is true, and with a digest_crc32c otherwise. The mode is not
+// inferred from the request, as an empty message leaves the data field
+// nil.
+func checkSignResponse(req *kmspb.AsymmetricSignRequest, res *kmspb.AsymmetricSignResponse, signsData bool) error {
+	switch {
+	case signsData && !res.VerifiedDataCrc32C:
+		return &IntegrityError{Method: `AsymmetricSign`, Field: `verified_data_crc32c`, Name: req.Name}
+	case !signsData && !res.VerifiedDigestCrc32C:
+		return &IntegrityError{Method: `AsymmetricSign`, Field: `verified_digest_crc32c`, Name: req.Name}
+	case res.Name != req.Name:
+		return &IntegrityError{Method: `AsymmetricSign`, Field: `name`, Name: req.Name}
+	case !checkCRC32C(res.Signature, res.SignatureCrc32C):
+		return &IntegrityError{Method: `AsymmetricSign`, Field: `signature_crc32c`, Name: req.Name}
+	}
+	return nil
+}
+
+// checkDecryptResponse performs the integrity checks on the response to an
+// AsymmetricDecrypt request, which must have been sent with a
+// ciphertext_crc32c
+func checkDecryptResponse(req *kmspb.AsymmetricDecryptRequest, res *kmspb.AsymmetricDecryptResponse) error {
+	switch {
+	case !res.VerifiedCiphertextCrc32C:
+		return &IntegrityError{Method: `AsymmetricDecrypt`, Field: `verified_ciphertext_crc32c`, Name: req.Name}
+	case !checkCRC32C(res.Plaintext, res.PlaintextCrc32C):
+		return &IntegrityError{Method: `AsymmetricDecrypt`, Field: `plaintext_crc32c`, Name: req.Name}
+	}
+	return nil
+}
+
+// checkPublicKeyResponse performs the integrity checks on the response to
+// a GetPublicKey request. Public keys in the NIST_PQC format are checked
+// using public_key.crc32c_checksum, and all others using pem_crc32c.
+func checkPublicKeyResponse(req *kmspb.GetPublicKeyRequest, res *kmspb.PublicKey) error {
+	switch {
+	case res.Name != req.Name:
+		return &IntegrityError{Method: `GetPublicKey`, Field: `name`, Name: req.Name}
+	case req.PublicKeyFormat == kmspb.PublicKey_NIST_PQC:
+		if !checkCRC32C(res.PublicKey.GetData(), res.PublicKey.GetCrc32CChecksum()) {
+			return &IntegrityError{Method: `GetPublicKey`, Field: `public_key.crc32c_checksum`, Name: req.Name}
+		}
+	case !checkCRC32C([]byte(res.Pem), res.PemCrc32C):
+		return &IntegrityError{Method: `GetPublicKey`, Field: `pem_crc32c`, Name: req.Name}
+	}
+	return nil
+}
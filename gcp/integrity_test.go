@@ -0,0 +1,109 @@
+package gcpsigner_test
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"testing"
+
+	"cloud.google.com/go/kms/apiv1/kmspb"
+	gcpsigner "github.com/jwx-go/crypto-signer/v2/gcp"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestIntegrityCheck(t *testing.T) {
+	client := newFakeKMS()
+	digest := sha256.Sum256([]byte("obla-di-obla-da"))
+	name := client.addKey(t, kmspb.CryptoKeyVersion_EC_SIGN_P256_SHA256)
+
+	testcases := []struct {
+		Name    string
+		Corrupt func(proto.Message)
+		Method  string
+		Field   string
+	}{
+		{
+			Name: "signature",
+			Corrupt: func(msg proto.Message) {
+				if res, ok := msg.(*kmspb.AsymmetricSignResponse); ok {
+					res.Signature[0] ^= 0x01
+				}
+			},
+			Method: `AsymmetricSign`,
+			Field:  `signature_crc32c`,
+		},
+		{
+			Name: "unverified digest",
+			Corrupt: func(msg proto.Message) {
+				if res, ok := msg.(*kmspb.AsymmetricSignResponse); ok {
+					res.VerifiedDigestCrc32C = false
+				}
+			},
+			Method: `AsymmetricSign`,
+			Field:  `verified_digest_crc32c`,
+		},
+		{
+			Name: "signature name",
+			Corrupt: func(msg proto.Message) {
+				if res, ok := msg.(*kmspb.AsymmetricSignResponse); ok {
+					res.Name += `0`
+				}
+			},
+			Method: `AsymmetricSign`,
+			Field:  `name`,
+		},
+		{
+			Name: "public key",
+			Corrupt: func(msg proto.Message) {
+				if res, ok := msg.(*kmspb.PublicKey); ok {
+					res.Pem = res.Pem[:len(res.Pem)-2] + "\n"
+				}
+			},
+			Method: `GetPublicKey`,
+			Field:  `pem_crc32c`,
+		},
+		{
+			Name: "public key name",
+			Corrupt: func(msg proto.Message) {
+				if res, ok := msg.(*kmspb.PublicKey); ok {
+					res.Name = ``
+				}
+			},
+			Method: `GetPublicKey`,
+			Field:  `name`,
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.Name, func(t *testing.T) {
+			cache := NewDumbCache()
+			sv := gcpsigner.New(client).
+				WithName(name).
+				WithCache(cache)
+
+			client.corrupt = tc.Corrupt
+			defer func() { client.corrupt = nil }()
+
+			_, err := sv.Sign(rand.Reader, digest[:], crypto.SHA256)
+			var ierr *gcpsigner.IntegrityError
+			if !errors.As(err, &ierr) {
+				t.Fatalf("expected *gcpsigner.IntegrityError, got %v", err)
+			}
+			if ierr.Method != tc.Method || ierr.Field != tc.Field || ierr.Name != name {
+				t.Fatalf("unexpected integrity error: %#v", ierr)
+			}
+			if _, ok := cache.Get(name); ok && tc.Method == `GetPublicKey` {
+				t.Fatalf("expected the corrupted public key not to be cached")
+			}
+
+			if _, err := sv.WithSkipIntegrityCheck(true).Sign(rand.Reader, digest[:], crypto.SHA256); tc.Field != `pem_crc32c` && err != nil {
+				t.Fatalf("expected no error when skipping the integrity checks, got %s", err)
+			}
+
+			client.corrupt = nil
+			if _, err := sv.Sign(rand.Reader, digest[:], crypto.SHA256); err != nil {
+				t.Fatalf("failed to sign: %s", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+// Package jwxadapter contains adapters that allow the objects in gcpsigner
+// to be used with github.com/lestrrat-go/jwx/v2 in places where jwx does not
+// accept the standard crypto interfaces.
+//
+// For example, jwx expects an *rsa.PrivateKey to decrypt RSA-OAEP encrypted
+// content encryption keys, so a gcpsigner.Decrypter has to be wrapped
+// using KeyDecrypter before it can be passed to jwe.Decrypt:
+//
+//	dec := gcpsigner.NewDecrypter(client).
+//	  WithName(name)
+//	payload, err := jwe.Decrypt(msg, jwe.WithKey(jwa.RSA_OAEP_256, jwxadapter.KeyDecrypter(dec)))
+package jwxadapter
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"fmt"
+
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwe"
+)
+
+type keyDecrypter struct {
+	decrypter crypto.Decrypter
+}
+
+// KeyDecrypter wraps a crypto.Decrypter for RSA keys (such as
+// gcpsigner.Decrypter) so that it can be used as a jwe.KeyDecrypter.
+// The RSA-OAEP key encryption algorithms are supported, and the
+// corresponding *rsa.OAEPOptions are passed to the crypto.Decrypter.
+func KeyDecrypter(decrypter crypto.Decrypter) jwe.KeyDecrypter {
+	return &keyDecrypter{
+		decrypter: decrypter,
+	}
+}
+
+func (kd *keyDecrypter) DecryptKey(alg jwa.KeyEncryptionAlgorithm, encryptedKey []byte, _ jwe.Recipient, _ *jwe.Message) ([]byte, error) {
+	var hash crypto.Hash
+	switch alg {
+	case jwa.RSA_OAEP:
+		hash = crypto.SHA1
+	case jwa.RSA_OAEP_256:
+		hash = crypto.SHA256
+	case jwa.RSA_OAEP_384:
+		hash = crypto.SHA384
+	case jwa.RSA_OAEP_512:
+		hash = crypto.SHA512
+	default:
+		return nil, fmt.Errorf(`jwxadapter.KeyDecrypter does not support key encryption algorithm %s`, alg)
+	}
+
+	cek, err := kd.decrypter.Decrypt(rand.Reader, encryptedKey, &rsa.OAEPOptions{Hash: hash})
+	if err != nil {
+		return nil, fmt.Errorf(`failed to decrypt content encryption key: %w`, err)
+	}
+	return cek, nil
+}
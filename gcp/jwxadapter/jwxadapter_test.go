@@ -0,0 +1,112 @@
+package jwxadapter_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"hash/crc32"
+	"testing"
+
+	"cloud.google.com/go/kms/apiv1/kmspb"
+	"github.com/googleapis/gax-go/v2"
+	gcpsigner "github.com/jwx-go/crypto-signer/v2/gcp"
+	"github.com/jwx-go/crypto-signer/v2/gcp/jwxadapter"
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwe"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+const keyName = `projects/p/locations/l/keyRings/r/cryptoKeys/k/cryptoKeyVersions/1`
+
+// fakeKMS is a Cloud KMS with a single RSA_DECRYPT_OAEP_2048_SHA256 key
+// version, which computes the checksums that gcpsigner checks
+type fakeKMS struct {
+	priv *rsa.PrivateKey
+}
+
+func crc32c(data []byte) *wrapperspb.Int64Value {
+	return wrapperspb.Int64(int64(crc32.Checksum(data, crc32.MakeTable(crc32.Castagnoli))))
+}
+
+func (c *fakeKMS) AsymmetricDecrypt(_ context.Context, req *kmspb.AsymmetricDecryptRequest, _ ...gax.CallOption) (*kmspb.AsymmetricDecryptResponse, error) {
+	plaintext, err := rsa.DecryptOAEP(sha256.New(), rand.Reader, c.priv, req.Ciphertext, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &kmspb.AsymmetricDecryptResponse{
+		Plaintext:                plaintext,
+		PlaintextCrc32C:          crc32c(plaintext),
+		VerifiedCiphertextCrc32C: req.CiphertextCrc32C != nil,
+	}, nil
+}
+
+func (c *fakeKMS) AsymmetricSign(context.Context, *kmspb.AsymmetricSignRequest, ...gax.CallOption) (*kmspb.AsymmetricSignResponse, error) {
+	return nil, fmt.Errorf(`algorithm RSA_DECRYPT_OAEP_2048_SHA256 does not sign`)
+}
+
+func (c *fakeKMS) GetPublicKey(_ context.Context, req *kmspb.GetPublicKeyRequest, _ ...gax.CallOption) (*kmspb.PublicKey, error) {
+	der, err := x509.MarshalPKIXPublicKey(&c.priv.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	pemBytes := pem.EncodeToMemory(&pem.Block{Type: `PUBLIC KEY`, Bytes: der})
+	return &kmspb.PublicKey{
+		Pem:       string(pemBytes),
+		PemCrc32C: crc32c(pemBytes),
+		Algorithm: kmspb.CryptoKeyVersion_RSA_DECRYPT_OAEP_2048_SHA256,
+		Name:      req.Name,
+	}, nil
+}
+
+func TestKeyDecrypter(t *testing.T) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %s", err)
+	}
+
+	dec := gcpsigner.NewDecrypter(&fakeKMS{priv: priv}).
+		WithName(keyName)
+	pubkey, err := dec.GetPublicKey()
+	if err != nil {
+		t.Fatalf("failed to get public key: %s", err)
+	}
+
+	payload := []byte("obla-di-obla-da")
+	t.Run(jwa.RSA_OAEP_256.String(), func(t *testing.T) {
+		encrypted, err := jwe.Encrypt(payload, jwe.WithKey(jwa.RSA_OAEP_256, pubkey))
+		if err != nil {
+			t.Fatalf("failed to encrypt: %s", err)
+		}
+
+		decrypted, err := jwe.Decrypt(encrypted, jwe.WithKey(jwa.RSA_OAEP_256, jwxadapter.KeyDecrypter(dec)))
+		if err != nil {
+			t.Fatalf("failed to decrypt: %s", err)
+		}
+		if !bytes.Equal(payload, decrypted) {
+			t.Fatalf("payload and decrypted does not match")
+		}
+	})
+
+	for _, alg := range []jwa.KeyEncryptionAlgorithm{jwa.RSA_OAEP, jwa.RSA_OAEP_512} {
+		t.Run(alg.String(), func(t *testing.T) {
+			encrypted, err := jwe.Encrypt(payload, jwe.WithKey(alg, pubkey))
+			if err != nil {
+				t.Fatalf("failed to encrypt: %s", err)
+			}
+			if _, err := jwe.Decrypt(encrypted, jwe.WithKey(alg, jwxadapter.KeyDecrypter(dec))); err == nil {
+				t.Fatalf("%s does not match the key version algorithm, and should fail", alg)
+			}
+		})
+	}
+
+	t.Run(jwa.A256KW.String(), func(t *testing.T) {
+		if _, err := jwxadapter.KeyDecrypter(dec).DecryptKey(jwa.A256KW, nil, nil, nil); err == nil {
+			t.Fatalf("A256KW is not an RSA-OAEP algorithm, and should fail")
+		}
+	})
+}
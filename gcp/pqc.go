@@ -0,0 +1,49 @@
+package gcpsigner
+
+import (
+	"crypto"
+	"fmt"
+
+	"cloud.google.com/go/kms/apiv1/kmspb"
+	"github.com/cloudflare/circl/sign"
+	"github.com/cloudflare/circl/sign/mldsa/mldsa65"
+	"github.com/cloudflare/circl/sign/slhdsa"
+)
+
+// pqcSchemes lists the post-quantum CryptoKeyVersionAlgorithms, along with
+// the schemes used to parse their public keys and verify their signatures
+var pqcSchemes = map[kmspb.CryptoKeyVersion_CryptoKeyVersionAlgorithm]sign.Scheme{
+	kmspb.CryptoKeyVersion_PQ_SIGN_ML_DSA_65:         mldsa65.Scheme(),
+	kmspb.CryptoKeyVersion_PQ_SIGN_SLH_DSA_SHA2_128S: slhdsa.SHA2_128s.Scheme(),
+}
+
+// ParsePQCPublicKey parses a public key in the NIST_PQC format, such as
+// the one returned by the Cloud KMS GetPublicKey API for post-quantum key
+// versions when kmspb.PublicKey_NIST_PQC is requested. alg must be one of
+// PQ_SIGN_ML_DSA_65 or PQ_SIGN_SLH_DSA_SHA2_128S.
+func ParsePQCPublicKey(alg kmspb.CryptoKeyVersion_CryptoKeyVersionAlgorithm, data []byte) (sign.PublicKey, error) {
+	scheme, ok := pqcSchemes[alg]
+	if !ok {
+		return nil, fmt.Errorf(`unsupported post-quantum algorithm %s`, alg)
+	}
+	pubkey, err := scheme.UnmarshalBinaryPublicKey(data)
+	if err != nil {
+		return nil, fmt.Errorf(`failed to parse %s public key: %w`, alg, err)
+	}
+	return pubkey, nil
+}
+
+// VerifyPQC verifies an ML-DSA or SLH-DSA signature over message using
+// the given public key, which must be one of the keys returned by
+// ParsePQCPublicKey (or Signer.GetPublicKey). An empty context string is
+// assumed, as Cloud KMS does not support others.
+func VerifyPQC(key crypto.PublicKey, message, signature []byte) error {
+	pubkey, ok := key.(sign.PublicKey)
+	if !ok {
+		return fmt.Errorf(`expected sign.PublicKey, got %T`, key)
+	}
+	if !pubkey.Scheme().Verify(pubkey, message, signature, nil) {
+		return fmt.Errorf(`failed to verify %s signature`, pubkey.Scheme().Name())
+	}
+	return nil
+}
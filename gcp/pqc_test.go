@@ -0,0 +1,91 @@
+package gcpsigner_test
+
+import (
+	"crypto"
+	"crypto/rand"
+	"errors"
+	"testing"
+
+	"cloud.google.com/go/kms/apiv1/kmspb"
+	"github.com/cloudflare/circl/sign"
+	gcpsigner "github.com/jwx-go/crypto-signer/v2/gcp"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestPQC(t *testing.T) {
+	client := newFakeKMS()
+
+	algorithms := []kmspb.CryptoKeyVersion_CryptoKeyVersionAlgorithm{
+		kmspb.CryptoKeyVersion_PQ_SIGN_ML_DSA_65,
+		kmspb.CryptoKeyVersion_PQ_SIGN_SLH_DSA_SHA2_128S,
+	}
+	for _, alg := range algorithms {
+		alg := alg
+		t.Run(alg.String(), func(t *testing.T) {
+			name := client.addKey(t, alg)
+			sv := gcpsigner.New(client).
+				WithName(name).
+				WithCache(NewDumbCache()).
+				WithSelfVerify(true)
+
+			key, err := sv.GetPublicKey()
+			if err != nil {
+				t.Fatalf("failed to get public key: %s", err)
+			}
+			if _, ok := key.(sign.PublicKey); !ok {
+				t.Fatalf("expected sign.PublicKey, got %T", key)
+			}
+
+			message := []byte("obla-di-obla-da")
+			signed, err := sv.Sign(rand.Reader, message, crypto.Hash(0))
+			if err != nil {
+				t.Fatalf("failed to sign: %s", err)
+			}
+			if err := gcpsigner.VerifyPQC(key, message, signed); err != nil {
+				t.Fatalf("failed to verify: %s", err)
+			}
+			if err := gcpsigner.VerifyPQC(key, []byte("life goes on"), signed); err == nil {
+				t.Fatalf("expected verification of a different message to fail")
+			}
+
+			if _, err := sv.Sign(rand.Reader, message, crypto.SHA256); err == nil {
+				t.Fatalf("expected pre-hashed signing to be rejected")
+			}
+		})
+	}
+
+	t.Run("Explicit public key format", func(t *testing.T) {
+		name := client.addKey(t, kmspb.CryptoKeyVersion_PQ_SIGN_ML_DSA_65)
+		sv := gcpsigner.New(client).
+			WithName(name).
+			WithPublicKeyFormat(kmspb.PublicKey_NIST_PQC)
+		if _, err := sv.GetPublicKey(); err != nil {
+			t.Fatalf("failed to get public key: %s", err)
+		}
+
+		// PEM is never retried using NIST_PQC when it is requested explicitly
+		sv = sv.WithPublicKeyFormat(kmspb.PublicKey_PEM)
+		if _, err := sv.GetPublicKey(); err == nil {
+			t.Fatalf("expected PEM to be rejected for %s", kmspb.CryptoKeyVersion_PQ_SIGN_ML_DSA_65)
+		}
+	})
+
+	t.Run("Corrupted public key", func(t *testing.T) {
+		name := client.addKey(t, kmspb.CryptoKeyVersion_PQ_SIGN_ML_DSA_65)
+		client.corrupt = func(msg proto.Message) {
+			if res, ok := msg.(*kmspb.PublicKey); ok {
+				res.PublicKey.Data[0] ^= 0xff
+			}
+		}
+		defer func() { client.corrupt = nil }()
+
+		sv := gcpsigner.New(client).
+			WithName(name).
+			WithPublicKeyFormat(kmspb.PublicKey_NIST_PQC)
+		_, err := sv.GetPublicKey()
+		var ierr *gcpsigner.IntegrityError
+		if !errors.As(err, &ierr) || ierr.Field != `public_key.crc32c_checksum` {
+			t.Fatalf("expected an integrity error on public_key.crc32c_checksum, got %v", err)
+		}
+	})
+}
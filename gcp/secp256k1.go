@@ -0,0 +1,38 @@
+package gcpsigner
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"fmt"
+
+	"cloud.google.com/go/kms/apiv1/kmspb"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+)
+
+// ecdsaCurves lists the curves used by the ECDSA CryptoKeyVersionAlgorithms
+var ecdsaCurves = map[kmspb.CryptoKeyVersion_CryptoKeyVersionAlgorithm]elliptic.Curve{
+	kmspb.CryptoKeyVersion_EC_SIGN_P256_SHA256:      elliptic.P256(),
+	kmspb.CryptoKeyVersion_EC_SIGN_P384_SHA384:      elliptic.P384(),
+	kmspb.CryptoKeyVersion_EC_SIGN_SECP256K1_SHA256: secp256k1.S256(),
+}
+
+// checkKeyAlgorithm makes sure that the curve of an ECDSA public key
+// matches the key version algorithm that was returned along with it, so
+// that (for example) a secp256k1 key is never used as if it were a P-256
+// key, which is the same size
+func checkKeyAlgorithm(alg kmspb.CryptoKeyVersion_CryptoKeyVersionAlgorithm, key crypto.PublicKey) error {
+	pubkey, ok := key.(*ecdsa.PublicKey)
+	if !ok {
+		return nil
+	}
+
+	curve, ok := ecdsaCurves[alg]
+	if !ok {
+		return fmt.Errorf(`key version algorithm %s does not use ECDSA keys`, alg)
+	}
+	if pubkey.Curve != curve {
+		return fmt.Errorf(`key version algorithm %s expects a key on %s, got %s`, alg, curve.Params().Name, pubkey.Curve.Params().Name)
+	}
+	return nil
+}
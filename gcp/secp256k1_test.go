@@ -0,0 +1,66 @@
+package gcpsigner_test
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"testing"
+
+	"cloud.google.com/go/kms/apiv1/kmspb"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	gcpsigner "github.com/jwx-go/crypto-signer/v2/gcp"
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jws"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestSecp256k1(t *testing.T) {
+	client := newFakeKMS()
+	name := client.addKey(t, kmspb.CryptoKeyVersion_EC_SIGN_SECP256K1_SHA256)
+
+	sv := gcpsigner.New(client).
+		WithName(name).
+		WithCache(NewDumbCache()).
+		WithSelfVerify(true)
+
+	key, err := sv.GetPublicKey()
+	if err != nil {
+		t.Fatalf("failed to get public key: %s", err)
+	}
+	pubkey, ok := key.(*ecdsa.PublicKey)
+	if !ok {
+		t.Fatalf("expected *ecdsa.PublicKey, got %T", key)
+	}
+	if pubkey.Curve != secp256k1.S256() {
+		t.Fatalf("expected secp256k1 curve, got %s", pubkey.Curve.Params().Name)
+	}
+
+	payload := []byte("obla-di-obla-da")
+	signed, err := jws.Sign(payload, jws.WithKey(jwa.ES256K, sv))
+	if err != nil {
+		t.Fatalf("failed to sign: %s", err)
+	}
+
+	verified, err := jws.Verify(signed, jws.WithKey(jwa.ES256K, sv))
+	if err != nil {
+		t.Fatalf("failed to verify: %s", err)
+	}
+	if !bytes.Equal(payload, verified) {
+		t.Fatalf("payload and verified does not match")
+	}
+
+	t.Run("Algorithm mismatch", func(t *testing.T) {
+		client.corrupt = func(msg proto.Message) {
+			if res, ok := msg.(*kmspb.PublicKey); ok {
+				res.Algorithm = kmspb.CryptoKeyVersion_EC_SIGN_P256_SHA256
+			}
+		}
+		defer func() { client.corrupt = nil }()
+
+		sv := gcpsigner.New(client).
+			WithName(name).
+			WithCache(NewDumbCache())
+		if _, err := sv.GetPublicKey(); err == nil {
+			t.Fatalf("expected a secp256k1 key to be rejected for EC_SIGN_P256_SHA256")
+		}
+	})
+}
@@ -4,24 +4,49 @@ import (
 	"context"
 	"crypto"
 	"crypto/ecdsa"
-	"crypto/rsa"
-	"crypto/x509"
+	"crypto/ed25519"
 	"encoding/pem"
 	"fmt"
 	"io"
 
-	kms "cloud.google.com/go/kms/apiv1"
-	kmspb "google.golang.org/genproto/googleapis/cloud/kms/v1"
+	"cloud.google.com/go/kms/apiv1/kmspb"
+	"github.com/cloudflare/circl/sign"
+	"github.com/jwx-go/crypto-signer/v2/internal/ecdsaenc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type Signer struct {
-	cache  Cache
-	client *kms.KeyManagementClient
-	ctx    context.Context
-	name   string
+	cache              Cache
+	client             Client
+	ctx                context.Context
+	encoding           SignatureEncoding
+	lowS               bool
+	mismatchHook       func(string, error)
+	name               string
+	publicKeyFormat    kmspb.PublicKey_PublicKeyFormat
+	selfVerify         bool
+	skipIntegrityCheck bool
 }
 
-func New(client *kms.KeyManagementClient) *Signer {
+// publicKeyInfo holds the public key of a key version, along with the
+// algorithm that it uses
+type publicKeyInfo struct {
+	algorithm kmspb.CryptoKeyVersion_CryptoKeyVersionAlgorithm
+	key       crypto.PublicKey
+}
+
+// signsData returns true if the key version signs the message itself,
+// rather than a digest
+func (info *publicKeyInfo) signsData() bool {
+	switch info.key.(type) {
+	case ed25519.PublicKey, sign.PublicKey:
+		return true
+	}
+	return dataSigningAlgorithms[info.algorithm]
+}
+
+func New(client Client) *Signer {
 	return &Signer{
 		client: client,
 	}
@@ -35,58 +60,74 @@ func (sv *Signer) getContext() context.Context {
 	return ctx
 }
 
+// Sign generates a signature from the given digest.
+//
+// opts.HashFunc() must specify the hash function that was used to compute
+// the digest, and it must match the algorithm of the key version (e.g.
+// crypto.SHA256 for RSA_SIGN_PKCS1_3072_SHA256). For RSA_SIGN_PSS_*
+// key versions, opts must be an *rsa.PSSOptions.
+//
+// EC_SIGN_ED25519, PQ_SIGN_ML_DSA_65 and PQ_SIGN_SLH_DSA_SHA2_128S key
+// versions sign the message itself, which is sent using the data field of
+// the request. As with ed25519.PrivateKey, the message must be passed in
+// place of the digest, and opts.HashFunc() must return zero.
 func (cs *Signer) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
-	// We need to get the public key, otherwise we have no way of knowing
-	// hints about the private key when signing
-	key, err := cs.GetPublicKey()
-	var pbdigest kmspb.Digest
-	switch key := key.(type) {
-	case *rsa.PublicKey:
-		switch key.Size() {
-		case 256:
-			pbdigest.Digest = &kmspb.Digest_Sha256{
-				Sha256: digest,
-			}
-		case 384:
-			pbdigest.Digest = &kmspb.Digest_Sha384{
-				Sha384: digest,
-			}
-		case 512:
-			pbdigest.Digest = &kmspb.Digest_Sha512{
-				Sha512: digest,
-			}
-		default:
-			return nil, fmt.Errorf(`unsupported digest size: %d`, key.Size())
-		}
-	case *ecdsa.PublicKey:
-		switch size := key.Curve.Params().BitSize; size {
-		case 256:
-			pbdigest.Digest = &kmspb.Digest_Sha256{
-				Sha256: digest,
-			}
-		case 384:
-			pbdigest.Digest = &kmspb.Digest_Sha384{
-				Sha384: digest,
-			}
-		case 512:
-			pbdigest.Digest = &kmspb.Digest_Sha512{
-				Sha512: digest,
-			}
-		default:
-			return nil, fmt.Errorf(`unsupported digest size: %d`, size)
-		}
+	// We need the key version algorithm (and the public key, if the
+	// signature is to be verified or re-encoded) before signing
+	info, err := cs.getPublicKeyInfo()
+	if err != nil {
+		return nil, fmt.Errorf(`failed to retrieve public key: %w`, err)
 	}
 
 	req := &kmspb.AsymmetricSignRequest{
-		Name:   cs.name,
-		Digest: &pbdigest,
+		Name: cs.name,
+	}
+	failed := `failed to sign digest`
+	if info.signsData() {
+		failed = `failed to sign data`
+		if err := checkDataSigningOpts(info.algorithm, opts); err != nil {
+			return nil, fmt.Errorf(`%s: %w`, failed, err)
+		}
+		req.Data = digest
+		if !cs.skipIntegrityCheck {
+			req.DataCrc32C = crc32c(digest)
+		}
+	} else {
+		pbdigest, err := digestForSigning(info.algorithm, digest, opts)
+		if err != nil {
+			return nil, fmt.Errorf(`%s: %w`, failed, err)
+		}
+		req.Digest = pbdigest
+		if !cs.skipIntegrityCheck {
+			req.DigestCrc32C = crc32c(digest)
+		}
 	}
 
 	ctx := cs.getContext()
 
 	res, err := cs.client.AsymmetricSign(ctx, req)
 	if err != nil {
-		return nil, fmt.Errorf(`failed to sign digest: %w`, err)
+		return nil, fmt.Errorf(`%s: %w`, failed, err)
+	}
+
+	if !cs.skipIntegrityCheck {
+		if err := checkSignResponse(req, res, info.signsData()); err != nil {
+			return nil, fmt.Errorf(`%s: %w`, failed, err)
+		}
+	}
+
+	if cs.selfVerify {
+		if err := reportMismatch(cs.name, cs.mismatchHook, verifySignature(info.key, digest, res.Signature, opts)); err != nil {
+			return nil, err
+		}
+	}
+
+	if key, ok := info.key.(*ecdsa.PublicKey); ok && (cs.encoding != SignatureEncodingDER || cs.lowS) {
+		encoded, err := encodeECDSASignature(key, res.Signature, cs.encoding, cs.lowS)
+		if err != nil {
+			return nil, fmt.Errorf(`failed to encode signature: %w`, err)
+		}
+		return encoded, nil
 	}
 
 	return res.Signature, nil
@@ -98,29 +139,114 @@ func (cs *Signer) Public() crypto.PublicKey {
 }
 
 func (cs *Signer) GetPublicKey() (crypto.PublicKey, error) {
-	if cache := cs.cache; cache != nil {
-		pubkey, ok := cache.Get(cs.name)
+	info, err := cs.getPublicKeyInfo()
+	if err != nil {
+		return nil, err
+	}
+	return info.key, nil
+}
+
+// Algorithm returns the algorithm of the key version, such as
+// kmspb.CryptoKeyVersion_EC_SIGN_P256_SHA256
+func (cs *Signer) Algorithm() (kmspb.CryptoKeyVersion_CryptoKeyVersionAlgorithm, error) {
+	info, err := cs.getPublicKeyInfo()
+	if err != nil {
+		return kmspb.CryptoKeyVersion_CRYPTO_KEY_VERSION_ALGORITHM_UNSPECIFIED, err
+	}
+	return info.algorithm, nil
+}
+
+func (cs *Signer) getPublicKeyInfo() (*publicKeyInfo, error) {
+	return fetchPublicKeyInfo(cs.getContext(), cs.client, cs.cache, cs.name, cs.publicKeyFormat, cs.skipIntegrityCheck)
+}
+
+// fetchPublicKeyInfo retrieves the public key of the key version name,
+// along with its algorithm, using the cache if available.
+//
+// Cloud KMS only returns the public keys of post-quantum key versions in
+// the NIST_PQC format, and rejects requests for the default PEM format. So
+// unless format is specified, a rejected request (or a response for a
+// post-quantum algorithm) is retried using NIST_PQC.
+func fetchPublicKeyInfo(ctx context.Context, client Client, cache Cache, name string, format kmspb.PublicKey_PublicKeyFormat, skipIntegrityCheck bool) (*publicKeyInfo, error) {
+	if cache != nil {
+		v, ok := cache.Get(name)
 		if ok {
-			return pubkey, nil
+			if info, ok := v.(*publicKeyInfo); ok {
+				return info, nil
+			}
 		}
 	}
 
-	ctx := cs.getContext()
-
-	res, err := cs.client.GetPublicKey(ctx, &kmspb.GetPublicKeyRequest{Name: cs.name})
+	req, res, err := requestPublicKey(ctx, client, name, format, skipIntegrityCheck)
+	if format == kmspb.PublicKey_PUBLIC_KEY_FORMAT_UNSPECIFIED && (isFormatRejected(err) || (err == nil && pqcSchemes[res.Algorithm] != nil)) {
+		pqreq, pqres, pqerr := requestPublicKey(ctx, client, name, kmspb.PublicKey_NIST_PQC, skipIntegrityCheck)
+		if pqerr == nil && pqcSchemes[pqres.Algorithm] != nil {
+			req, res, err = pqreq, pqres, nil
+		}
+	}
 	if err != nil {
-		return nil, fmt.Errorf(`failed to get public key: %w`, err)
+		return nil, err
 	}
 
-	block, _ := pem.Decode([]byte(res.Pem))
-	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	var key crypto.PublicKey
+	if req.PublicKeyFormat == kmspb.PublicKey_NIST_PQC {
+		key, err = ParsePQCPublicKey(res.Algorithm, res.PublicKey.GetData())
+		if err != nil {
+			return nil, fmt.Errorf(`failed to parse key: %w`, err)
+		}
+	} else {
+		block, _ := pem.Decode([]byte(res.Pem))
+		if block == nil {
+			return nil, fmt.Errorf(`failed to decode PEM encoded public key`)
+		}
+		key, err = ecdsaenc.ParsePublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf(`failed to parse key: %w`, err)
+		}
+		if err := checkKeyAlgorithm(res.Algorithm, key); err != nil {
+			return nil, fmt.Errorf(`failed to parse key: %w`, err)
+		}
+	}
+
+	info := &publicKeyInfo{
+		algorithm: res.Algorithm,
+		key:       key,
+	}
+	if cache != nil {
+		cache.Set(name, info)
+	}
+
+	return info, nil
+}
+
+// requestPublicKey calls the GetPublicKey API, and performs the integrity
+// checks on the response
+func requestPublicKey(ctx context.Context, client Client, name string, format kmspb.PublicKey_PublicKeyFormat, skipIntegrityCheck bool) (*kmspb.GetPublicKeyRequest, *kmspb.PublicKey, error) {
+	req := &kmspb.GetPublicKeyRequest{
+		Name:            name,
+		PublicKeyFormat: format,
+	}
+	res, err := client.GetPublicKey(ctx, req)
 	if err != nil {
-		return nil, fmt.Errorf(`failed to parse key: %w`, err)
+		return nil, nil, fmt.Errorf(`failed to get public key: %w`, err)
 	}
 
-	if cache := cs.cache; cache != nil {
-		cache.Set(cs.name, key)
+	// a corrupted public key must never make it into the cache
+	if !skipIntegrityCheck {
+		if err := checkPublicKeyResponse(req, res); err != nil {
+			return nil, nil, fmt.Errorf(`failed to get public key: %w`, err)
+		}
 	}
+	return req, res, nil
+}
 
-	return key, nil
+// isFormatRejected returns true if err is the error that Cloud KMS returns
+// when the requested public key format is not supported by the key version
+func isFormatRejected(err error) bool {
+	switch status.Code(err) {
+	case codes.InvalidArgument, codes.FailedPrecondition:
+		return true
+	default:
+		return false
+	}
 }
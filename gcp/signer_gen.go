@@ -1,6 +1,10 @@
 package gcpsigner
 
-import "context"
+import (
+	"context"
+
+	"cloud.google.com/go/kms/apiv1/kmspb"
+)
 
 // WithCache specifies the cache storage for frequently used items.
 // Currently only the public key is cached.
@@ -12,29 +16,178 @@ import "context"
 // or use a cache with some sort of auto-eviction mechanism.
 func (cs *Signer) WithCache(v Cache) *Signer {
 	return &Signer{
-		client: cs.client,
-		cache:  v,
-		ctx:    cs.ctx,
-		name:   cs.name,
+		client:             cs.client,
+		cache:              v,
+		ctx:                cs.ctx,
+		encoding:           cs.encoding,
+		lowS:               cs.lowS,
+		mismatchHook:       cs.mismatchHook,
+		name:               cs.name,
+		publicKeyFormat:    cs.publicKeyFormat,
+		selfVerify:         cs.selfVerify,
+		skipIntegrityCheck: cs.skipIntegrityCheck,
 	}
 }
 
 // WithContext associates a new context.Context with the object, which will be used for Sign() and Public()
 func (cs *Signer) WithContext(v context.Context) *Signer {
 	return &Signer{
-		client: cs.client,
-		cache:  cs.cache,
-		ctx:    v,
-		name:   cs.name,
+		client:             cs.client,
+		cache:              cs.cache,
+		ctx:                v,
+		encoding:           cs.encoding,
+		lowS:               cs.lowS,
+		mismatchHook:       cs.mismatchHook,
+		name:               cs.name,
+		publicKeyFormat:    cs.publicKeyFormat,
+		selfVerify:         cs.selfVerify,
+		skipIntegrityCheck: cs.skipIntegrityCheck,
+	}
+}
+
+// WithSignatureEncoding specifies how ECDSA signatures are encoded. By
+// default (SignatureEncodingDER), the ASN.1 DER encoded signature returned
+// by Cloud KMS is used as is. SignatureEncodingRS produces the fixed size
+// r||s encoding, padded to the size of the curve. Signatures created with
+// RSA keys are not affected.
+//
+// Do not use SignatureEncodingRS with jwx, which expects crypto.Signer
+// implementations to return ASN.1 DER.
+func (cs *Signer) WithSignatureEncoding(v SignatureEncoding) *Signer {
+	return &Signer{
+		client:             cs.client,
+		cache:              cs.cache,
+		ctx:                cs.ctx,
+		encoding:           v,
+		lowS:               cs.lowS,
+		mismatchHook:       cs.mismatchHook,
+		name:               cs.name,
+		publicKeyFormat:    cs.publicKeyFormat,
+		selfVerify:         cs.selfVerify,
+		skipIntegrityCheck: cs.skipIntegrityCheck,
+	}
+}
+
+// WithLowS specifies whether ECDSA signatures are normalized to low-S
+// form, where s is at most half the order of the curve. Some verifiers
+// (e.g. for secp256k1 signatures) reject signatures that are not.
+func (cs *Signer) WithLowS(v bool) *Signer {
+	return &Signer{
+		client:             cs.client,
+		cache:              cs.cache,
+		ctx:                cs.ctx,
+		encoding:           cs.encoding,
+		lowS:               v,
+		mismatchHook:       cs.mismatchHook,
+		name:               cs.name,
+		publicKeyFormat:    cs.publicKeyFormat,
+		selfVerify:         cs.selfVerify,
+		skipIntegrityCheck: cs.skipIntegrityCheck,
+	}
+}
+
+// WithMismatchHook specifies a function that is called with the key name
+// and the error whenever self-verification (see WithSelfVerify()) fails.
+// Use it to alert on signatures that do not match the public key.
+func (cs *Signer) WithMismatchHook(v func(string, error)) *Signer {
+	return &Signer{
+		client:             cs.client,
+		cache:              cs.cache,
+		ctx:                cs.ctx,
+		encoding:           cs.encoding,
+		lowS:               cs.lowS,
+		mismatchHook:       v,
+		name:               cs.name,
+		publicKeyFormat:    cs.publicKeyFormat,
+		selfVerify:         cs.selfVerify,
+		skipIntegrityCheck: cs.skipIntegrityCheck,
 	}
 }
 
 // WithName associates a new string with the object, which will be used for Sign() and Public()
 func (cs *Signer) WithName(v string) *Signer {
 	return &Signer{
-		client: cs.client,
-		cache:  cs.cache,
-		ctx:    cs.ctx,
-		name:   v,
+		client:             cs.client,
+		cache:              cs.cache,
+		ctx:                cs.ctx,
+		encoding:           cs.encoding,
+		lowS:               cs.lowS,
+		mismatchHook:       cs.mismatchHook,
+		name:               v,
+		publicKeyFormat:    cs.publicKeyFormat,
+		selfVerify:         cs.selfVerify,
+		skipIntegrityCheck: cs.skipIntegrityCheck,
+	}
+}
+
+// WithPublicKeyFormat specifies the format in which Cloud KMS returns
+// the public key. By default, the PEM format is requested, and
+// kmspb.PublicKey_NIST_PQC is requested instead if Cloud KMS rejects
+// it, as it does for the post-quantum key versions (PQ_SIGN_ML_DSA_65
+// and PQ_SIGN_SLH_DSA_SHA2_128S).
+//
+// Specifying the format overrides this detection, and saves a request
+// to Cloud KMS for post-quantum key versions.
+func (cs *Signer) WithPublicKeyFormat(v kmspb.PublicKey_PublicKeyFormat) *Signer {
+	return &Signer{
+		client:             cs.client,
+		cache:              cs.cache,
+		ctx:                cs.ctx,
+		encoding:           cs.encoding,
+		lowS:               cs.lowS,
+		mismatchHook:       cs.mismatchHook,
+		name:               cs.name,
+		publicKeyFormat:    v,
+		selfVerify:         cs.selfVerify,
+		skipIntegrityCheck: cs.skipIntegrityCheck,
+	}
+}
+
+// WithSelfVerify specifies whether each signature returned by Cloud KMS
+// is verified locally against the public key before it is returned.
+// When the verification fails, the signature is discarded, an error
+// wrapping ErrSignatureMismatch is returned, and the function specified
+// via WithMismatchHook() is called.
+//
+// RSA signatures are verified using RSASSA-PSS if opts is an
+// *rsa.PSSOptions, and RSASSA-PKCS1-v1_5 otherwise. Use it along with
+// WithCache(), as the public key would otherwise be retrieved from
+// Cloud KMS for every signature.
+func (cs *Signer) WithSelfVerify(v bool) *Signer {
+	return &Signer{
+		client:             cs.client,
+		cache:              cs.cache,
+		ctx:                cs.ctx,
+		encoding:           cs.encoding,
+		lowS:               cs.lowS,
+		mismatchHook:       cs.mismatchHook,
+		name:               cs.name,
+		publicKeyFormat:    cs.publicKeyFormat,
+		selfVerify:         v,
+		skipIntegrityCheck: cs.skipIntegrityCheck,
+	}
+}
+
+// WithSkipIntegrityCheck specifies whether to skip the CRC32C based
+// end-to-end integrity checks that are performed by default. When they
+// are enabled, the CRC32C checksum of the digest is sent along with each
+// request, and the checksums and the key version name in the responses
+// of AsymmetricSign and GetPublicKey are checked. A failed check is
+// reported as an *IntegrityError.
+//
+// Only skip the checks when talking to something other than Cloud KMS
+// that does not support them.
+func (cs *Signer) WithSkipIntegrityCheck(v bool) *Signer {
+	return &Signer{
+		client:             cs.client,
+		cache:              cs.cache,
+		ctx:                cs.ctx,
+		encoding:           cs.encoding,
+		lowS:               cs.lowS,
+		mismatchHook:       cs.mismatchHook,
+		name:               cs.name,
+		publicKeyFormat:    cs.publicKeyFormat,
+		selfVerify:         cs.selfVerify,
+		skipIntegrityCheck: v,
 	}
 }
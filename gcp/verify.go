@@ -0,0 +1,74 @@
+package gcpsigner
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"fmt"
+
+	"github.com/cloudflare/circl/sign"
+)
+
+// verifySignature verifies a signature returned by Cloud KMS locally,
+// using pubkey. The hash function is taken from opts, or derived from the
+// length of the digest if opts does not specify one. For Ed25519 and
+// post-quantum keys, digest is the message itself.
+func verifySignature(pubkey crypto.PublicKey, digest, signature []byte, opts crypto.SignerOpts) error {
+	if pubkey, ok := pubkey.(ed25519.PublicKey); ok {
+		if !ed25519.Verify(pubkey, digest, signature) {
+			return fmt.Errorf(`failed to verify Ed25519 signature`)
+		}
+		return nil
+	}
+	if pubkey, ok := pubkey.(sign.PublicKey); ok {
+		return VerifyPQC(pubkey, digest, signature)
+	}
+
+	var hash crypto.Hash
+	if opts != nil {
+		hash = opts.HashFunc()
+	}
+	if hash == crypto.Hash(0) {
+		switch len(digest) {
+		case crypto.SHA256.Size():
+			hash = crypto.SHA256
+		case crypto.SHA384.Size():
+			hash = crypto.SHA384
+		case crypto.SHA512.Size():
+			hash = crypto.SHA512
+		default:
+			return fmt.Errorf(`cannot determine hash function for a digest of length %d`, len(digest))
+		}
+	}
+
+	switch pubkey := pubkey.(type) {
+	case *rsa.PublicKey:
+		if pss, ok := opts.(*rsa.PSSOptions); ok {
+			return rsa.VerifyPSS(pubkey, hash, digest, signature, pss)
+		}
+		return rsa.VerifyPKCS1v15(pubkey, hash, digest, signature)
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(pubkey, digest, signature) {
+			return fmt.Errorf(`failed to verify ECDSA signature`)
+		}
+		return nil
+	default:
+		return fmt.Errorf(`unsupported public key type %T`, pubkey)
+	}
+}
+
+// reportMismatch turns a failed self-verification into an error that
+// wraps ErrSignatureMismatch, and passes it to hook, if any. It returns
+// nil if err is nil.
+func reportMismatch(name string, hook func(string, error), err error) error {
+	if err == nil {
+		return nil
+	}
+
+	err = fmt.Errorf(`signature from key %q failed self-verification: %w (%s)`, name, ErrSignatureMismatch, err)
+	if hook != nil {
+		hook(name, err)
+	}
+	return err
+}
@@ -0,0 +1,64 @@
+package gcpsigner_test
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"testing"
+
+	"cloud.google.com/go/kms/apiv1/kmspb"
+	gcpsigner "github.com/jwx-go/crypto-signer/v2/gcp"
+)
+
+func TestSelfVerify(t *testing.T) {
+	client := newFakeKMS()
+	digest := sha256.Sum256([]byte("obla-di-obla-da"))
+
+	testcases := []struct {
+		Name      string
+		Algorithm kmspb.CryptoKeyVersion_CryptoKeyVersionAlgorithm
+		Opts      crypto.SignerOpts
+	}{
+		{Name: "RSA PKCS1", Algorithm: kmspb.CryptoKeyVersion_RSA_SIGN_PKCS1_2048_SHA256, Opts: crypto.SHA256},
+		{Name: "RSA PSS", Algorithm: kmspb.CryptoKeyVersion_RSA_SIGN_PSS_2048_SHA256, Opts: &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA256}},
+		{Name: "ECDSA", Algorithm: kmspb.CryptoKeyVersion_EC_SIGN_P256_SHA256, Opts: crypto.SHA256},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.Name, func(t *testing.T) {
+			var mismatches []error
+			sv := gcpsigner.New(client).
+				WithName(client.addKey(t, tc.Algorithm)).
+				WithCache(NewDumbCache()).
+				WithSelfVerify(true).
+				WithMismatchHook(func(_ string, err error) {
+					mismatches = append(mismatches, err)
+				})
+
+			if _, err := sv.Sign(rand.Reader, digest[:], tc.Opts); err != nil {
+				t.Fatalf("failed to sign: %s", err)
+			}
+			if len(mismatches) != 0 {
+				t.Fatalf("expected the hook not to be called, got %v", mismatches)
+			}
+
+			client.tamper = func(signature []byte) []byte {
+				signature[len(signature)-1] ^= 0x01
+				return signature
+			}
+			defer func() { client.tamper = nil }()
+
+			signed, err := sv.Sign(rand.Reader, digest[:], tc.Opts)
+			if !errors.Is(err, gcpsigner.ErrSignatureMismatch) {
+				t.Fatalf("expected ErrSignatureMismatch, got %v", err)
+			}
+			if signed != nil {
+				t.Fatalf("expected no signature to be returned")
+			}
+			if len(mismatches) != 1 || !errors.Is(mismatches[0], gcpsigner.ErrSignatureMismatch) {
+				t.Fatalf("expected the hook to be called once with ErrSignatureMismatch, got %v", mismatches)
+			}
+		})
+	}
+}
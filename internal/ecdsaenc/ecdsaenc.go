@@ -0,0 +1,113 @@
+// Package ecdsaenc contains the handling of ECDSA keys and signatures
+// that is shared by awssigner and gcpsigner: re-encoding the ASN.1 DER
+// signatures returned by the cloud KMS services, and parsing public keys
+// on secp256k1, which crypto/x509 does not support.
+package ecdsaenc
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"math/big"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+)
+
+// Encoding specifies how a signature is encoded. The values match those
+// of awssigner.SignatureEncoding and gcpsigner.SignatureEncoding, which
+// can be converted to it.
+type Encoding int
+
+const (
+	// DER encodes signatures as an ASN.1 DER SEQUENCE of r and s
+	DER Encoding = iota
+
+	// RS encodes signatures as the concatenation of r and s, each
+	// left-padded with zeros to the size of the curve
+	RS
+)
+
+var (
+	oidPublicKeyECDSA      = asn1.ObjectIdentifier{1, 2, 840, 10045, 2, 1}
+	oidNamedCurveSecp256k1 = asn1.ObjectIdentifier{1, 3, 132, 0, 10}
+)
+
+// signature is the ASN.1 structure of an ECDSA signature
+type signature struct {
+	R, S *big.Int
+}
+
+// subjectPublicKeyInfo is the ASN.1 structure of a DER encoded public key
+type subjectPublicKeyInfo struct {
+	Algorithm pkix.AlgorithmIdentifier
+	PublicKey asn1.BitString
+}
+
+// Encode re-encodes a DER encoded ECDSA signature, as returned by KMS.
+// If lowS is true, s is replaced by n - s when it is larger than n / 2,
+// which yields an equally valid signature that verifiers which require
+// low-S signatures (e.g. for secp256k1) accept.
+func Encode(pubkey *ecdsa.PublicKey, der []byte, encoding Encoding, lowS bool) ([]byte, error) {
+	var sig signature
+	rest, err := asn1.Unmarshal(der, &sig)
+	if err != nil {
+		return nil, fmt.Errorf(`failed to parse ECDSA signature: %w`, err)
+	}
+	if len(rest) > 0 {
+		return nil, fmt.Errorf(`trailing data after ECDSA signature`)
+	}
+
+	params := pubkey.Curve.Params()
+	if lowS {
+		halfOrder := new(big.Int).Rsh(params.N, 1)
+		if sig.S.Cmp(halfOrder) > 0 {
+			sig.S = new(big.Int).Sub(params.N, sig.S)
+		}
+	}
+
+	switch encoding {
+	case DER:
+		if !lowS {
+			return der, nil
+		}
+		encoded, err := asn1.Marshal(sig)
+		if err != nil {
+			return nil, fmt.Errorf(`failed to encode ECDSA signature: %w`, err)
+		}
+		return encoded, nil
+	case RS:
+		size := (params.BitSize + 7) / 8
+		if sig.R.Sign() <= 0 || sig.S.Sign() <= 0 || len(sig.R.Bytes()) > size || len(sig.S.Bytes()) > size {
+			return nil, fmt.Errorf(`ECDSA signature does not fit the curve`)
+		}
+		rs := make([]byte, 2*size)
+		sig.R.FillBytes(rs[:size])
+		sig.S.FillBytes(rs[size:])
+		return rs, nil
+	default:
+		return nil, fmt.Errorf(`unsupported signature encoding %d`, encoding)
+	}
+}
+
+// ParsePublicKey parses a DER encoded SubjectPublicKeyInfo. In addition to
+// the keys supported by crypto/x509, this function can handle keys on
+// secp256k1, which are returned as *ecdsa.PublicKey using the curve from
+// github.com/decred/dcrd/dcrec/secp256k1/v4
+func ParsePublicKey(der []byte) (crypto.PublicKey, error) {
+	var spki subjectPublicKeyInfo
+	if _, err := asn1.Unmarshal(der, &spki); err == nil && spki.Algorithm.Algorithm.Equal(oidPublicKeyECDSA) {
+		var namedCurve asn1.ObjectIdentifier
+		if _, err := asn1.Unmarshal(spki.Algorithm.Parameters.FullBytes, &namedCurve); err == nil && namedCurve.Equal(oidNamedCurveSecp256k1) {
+			pubkey, err := secp256k1.ParsePubKey(spki.PublicKey.RightAlign())
+			if err != nil {
+				return nil, fmt.Errorf(`failed to parse secp256k1 public key: %w`, err)
+			}
+			return pubkey.ToECDSA(), nil
+		}
+	}
+
+	return x509.ParsePKIXPublicKey(der)
+}
@@ -0,0 +1,227 @@
+package ecdsaenc_test
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"testing"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/jwx-go/crypto-signer/v2/internal/ecdsaenc"
+)
+
+type signature struct {
+	R, S *big.Int
+}
+
+func generateKey(t *testing.T, curve elliptic.Curve) *ecdsa.PrivateKey {
+	t.Helper()
+	if curve == secp256k1.S256() {
+		priv, err := secp256k1.GeneratePrivateKey()
+		if err != nil {
+			t.Fatalf("failed to generate key: %s", err)
+		}
+		return priv.ToECDSA()
+	}
+	priv, err := ecdsa.GenerateKey(curve, rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %s", err)
+	}
+	return priv
+}
+
+// sign returns a DER encoded signature with a high S if high is true,
+// and with a low S otherwise
+func sign(t *testing.T, priv *ecdsa.PrivateKey, digest []byte, high bool) []byte {
+	t.Helper()
+	r, s, err := ecdsa.Sign(rand.Reader, priv, digest)
+	if err != nil {
+		t.Fatalf("failed to sign: %s", err)
+	}
+	n := priv.Curve.Params().N
+	if isHigh := s.Cmp(new(big.Int).Rsh(n, 1)) > 0; isHigh != high {
+		s = new(big.Int).Sub(n, s)
+	}
+	der, err := asn1.Marshal(signature{R: r, S: s})
+	if err != nil {
+		t.Fatalf("failed to encode signature: %s", err)
+	}
+	return der
+}
+
+func TestEncode(t *testing.T) {
+	digest := sha256.Sum256([]byte("obla-di-obla-da"))
+
+	testcases := []struct {
+		Name  string
+		Curve elliptic.Curve
+		Size  int
+	}{
+		{Name: "P-256", Curve: elliptic.P256(), Size: 32},
+		{Name: "P-384", Curve: elliptic.P384(), Size: 48},
+		{Name: "P-521", Curve: elliptic.P521(), Size: 66},
+		{Name: "secp256k1", Curve: secp256k1.S256(), Size: 32},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.Name, func(t *testing.T) {
+			priv := generateKey(t, tc.Curve)
+			pubkey := &priv.PublicKey
+			halfOrder := new(big.Int).Rsh(tc.Curve.Params().N, 1)
+
+			t.Run("DER", func(t *testing.T) {
+				der := sign(t, priv, digest[:], true)
+				encoded, err := ecdsaenc.Encode(pubkey, der, ecdsaenc.DER, false)
+				if err != nil {
+					t.Fatalf("failed to encode: %s", err)
+				}
+				if !bytes.Equal(encoded, der) {
+					t.Fatalf("expected the signature to be left alone")
+				}
+			})
+			t.Run("r||s", func(t *testing.T) {
+				// signatures with leading zeros in r or s are likely to
+				// show up in a few dozen attempts
+				for range 32 {
+					encoded, err := ecdsaenc.Encode(pubkey, sign(t, priv, digest[:], false), ecdsaenc.RS, false)
+					if err != nil {
+						t.Fatalf("failed to encode: %s", err)
+					}
+					if len(encoded) != 2*tc.Size {
+						t.Fatalf("expected a %d byte signature, got %d bytes", 2*tc.Size, len(encoded))
+					}
+					r := new(big.Int).SetBytes(encoded[:tc.Size])
+					s := new(big.Int).SetBytes(encoded[tc.Size:])
+					if !ecdsa.Verify(pubkey, digest[:], r, s) {
+						t.Fatalf("failed to verify signature")
+					}
+				}
+			})
+			t.Run("low-S", func(t *testing.T) {
+				for _, encoding := range []ecdsaenc.Encoding{ecdsaenc.DER, ecdsaenc.RS} {
+					encoded, err := ecdsaenc.Encode(pubkey, sign(t, priv, digest[:], true), encoding, true)
+					if err != nil {
+						t.Fatalf("failed to encode: %s", err)
+					}
+					var sig signature
+					if encoding == ecdsaenc.DER {
+						if _, err := asn1.Unmarshal(encoded, &sig); err != nil {
+							t.Fatalf("failed to parse signature: %s", err)
+						}
+					} else {
+						sig.R = new(big.Int).SetBytes(encoded[:tc.Size])
+						sig.S = new(big.Int).SetBytes(encoded[tc.Size:])
+					}
+					if sig.S.Cmp(halfOrder) > 0 {
+						t.Fatalf("expected a low-S signature")
+					}
+					if !ecdsa.Verify(pubkey, digest[:], sig.R, sig.S) {
+						t.Fatalf("failed to verify signature")
+					}
+				}
+
+				// low-S signatures are left alone
+				der := sign(t, priv, digest[:], false)
+				encoded, err := ecdsaenc.Encode(pubkey, der, ecdsaenc.DER, true)
+				if err != nil {
+					t.Fatalf("failed to encode: %s", err)
+				}
+				if !bytes.Equal(encoded, der) {
+					t.Fatalf("expected a low-S signature to be left alone")
+				}
+			})
+		})
+	}
+
+	t.Run("Errors", func(t *testing.T) {
+		priv := generateKey(t, elliptic.P256())
+		der := sign(t, priv, digest[:], false)
+		tooLarge, _ := asn1.Marshal(signature{R: big.NewInt(1), S: new(big.Int).Lsh(big.NewInt(1), 256)})
+
+		testcases := map[string]struct {
+			Signature []byte
+			Encoding  ecdsaenc.Encoding
+		}{
+			"malformed":            {Signature: der[:len(der)-1], Encoding: ecdsaenc.RS},
+			"trailing data":        {Signature: append(append([]byte{}, der...), 0), Encoding: ecdsaenc.RS},
+			"does not fit":         {Signature: tooLarge, Encoding: ecdsaenc.RS},
+			"unsupported encoding": {Signature: der, Encoding: ecdsaenc.Encoding(42)},
+		}
+		for name, tc := range testcases {
+			t.Run(name, func(t *testing.T) {
+				if _, err := ecdsaenc.Encode(&priv.PublicKey, tc.Signature, tc.Encoding, false); err == nil {
+					t.Fatalf("expected an error")
+				}
+			})
+		}
+	})
+}
+
+// marshalSecp256k1 encodes an uncompressed point on secp256k1 as a
+// SubjectPublicKeyInfo, which crypto/x509 cannot do
+func marshalSecp256k1(t *testing.T, point []byte) []byte {
+	t.Helper()
+	params, err := asn1.Marshal(asn1.ObjectIdentifier{1, 3, 132, 0, 10})
+	if err != nil {
+		t.Fatalf("failed to marshal curve: %s", err)
+	}
+	der, err := asn1.Marshal(struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}{
+		Algorithm: pkix.AlgorithmIdentifier{
+			Algorithm:  asn1.ObjectIdentifier{1, 2, 840, 10045, 2, 1},
+			Parameters: asn1.RawValue{FullBytes: params},
+		},
+		PublicKey: asn1.BitString{Bytes: point, BitLength: 8 * len(point)},
+	})
+	if err != nil {
+		t.Fatalf("failed to marshal public key: %s", err)
+	}
+	return der
+}
+
+func TestParsePublicKey(t *testing.T) {
+	t.Run("P-256", func(t *testing.T) {
+		priv := generateKey(t, elliptic.P256())
+		der, err := x509.MarshalPKIXPublicKey(&priv.PublicKey)
+		if err != nil {
+			t.Fatalf("failed to marshal public key: %s", err)
+		}
+		key, err := ecdsaenc.ParsePublicKey(der)
+		if err != nil {
+			t.Fatalf("failed to parse public key: %s", err)
+		}
+		if !priv.PublicKey.Equal(key) {
+			t.Fatalf("parsed public key does not match")
+		}
+	})
+	t.Run("secp256k1", func(t *testing.T) {
+		priv, err := secp256k1.GeneratePrivateKey()
+		if err != nil {
+			t.Fatalf("failed to generate key: %s", err)
+		}
+		point := priv.PubKey().SerializeUncompressed()
+		der := marshalSecp256k1(t, point)
+
+		key, err := ecdsaenc.ParsePublicKey(der)
+		if err != nil {
+			t.Fatalf("failed to parse public key: %s", err)
+		}
+		pubkey, ok := key.(*ecdsa.PublicKey)
+		if !ok || pubkey.Curve != secp256k1.S256() || !pubkey.Equal(priv.PubKey().ToECDSA()) {
+			t.Fatalf("expected the secp256k1 public key, got %#v", key)
+		}
+
+		// a point that is not on the curve
+		point[len(point)-1] ^= 0x01
+		if _, err := ecdsaenc.ParsePublicKey(marshalSecp256k1(t, point)); err == nil {
+			t.Fatalf("expected an error for a point that is not on the curve")
+		}
+	})
+}
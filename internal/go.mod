@@ -0,0 +1,5 @@
+module github.com/jwx-go/crypto-signer/v2/internal
+
+go 1.23
+
+require github.com/decred/dcrd/dcrec/secp256k1/v4 v4.3.0